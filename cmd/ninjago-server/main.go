@@ -0,0 +1,126 @@
+// Package main provides the dedicated game server, which hosts multiplayer matches without opening a window.
+// It does not link ebiten, so it builds with CGO_ENABLED=0 and runs on machines without a display.
+//
+// Usage:
+//
+//	ninjago-server [-addr host] [-port port] [-level path] [-level-dir dir] [-max-players n] [-max-spectators n] [-tick-rate n] [-start-players n] [-resume-grace duration] [-heartbeat duration] [-heartbeat-timeout duration] [-discoverable=false] [-admin-token token] [-password password] [-rooms n] [-max-rooms n] [-tls] [-cert file -key file]
+//
+// With -tls, clients join the server at wss://host:port. Without -cert and -key, the server creates a self-signed
+// certificate on its first start and keeps using it, and the clients pin its fingerprint when they first join.
+//
+// With -rooms, the server hosts several matches in rooms named 1 to n, which clients join at host:port/1 and so on.
+// The rooms are listed at /rooms/, and more can be created through the admin API with a POST to /admin/rooms.
+//
+// The admin API under /admin is only served with an admin token, which can also be given in the NINJAGO_ADMIN_TOKEN
+// environment variable to keep it out of the process list. Requests pass it as a bearer token, for example:
+//
+//	curl -H "Authorization: Bearer $NINJAGO_ADMIN_TOKEN" http://localhost:8080/admin/status
+//
+// The levels chosen through the admin API are named relative to -level-dir, for example "level2" for
+// assets/levels/level2.txt, and cannot be read from anywhere else, as the server hands them out to every client.
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/multiplayer"
+)
+
+func main() {
+	addr := flag.String("addr", "", "the host or IP address to listen on, every interface if empty")
+	port := flag.Int("port", multiplayer.DefaultPort, "the port to listen on")
+	level := flag.String("level", "assets/levels/level1.txt", "the path to the level file the matches are played on")
+	levelDir := flag.String("level-dir", "", "the directory the levels chosen through the admin API are read from, the directory of -level if empty")
+	maxPlayers := flag.Int("max-players", multiplayer.MaxPlayers, "the number of players the server accepts")
+	maxSpectators := flag.Int("max-spectators", multiplayer.DefaultMaxSpectators, "the number of spectators the server accepts besides the players")
+	tickRate := flag.Int("tick-rate", multiplayer.DefaultTickRate, "the number of snapshots sent per second, dividing the tick rate of the game")
+	startPlayers := flag.Int("start-players", 2, "the number of connected players starting a match, 0 to never start one")
+	resumeGrace := flag.Duration("resume-grace", multiplayer.DefaultResumeGrace, "how long the player of a dropped connection is kept for the client to reconnect")
+	heartbeat := flag.Duration("heartbeat", multiplayer.DefaultHeartbeatInterval, "how often the server and the clients ping each other")
+	heartbeatTimeout := flag.Duration("heartbeat-timeout", multiplayer.DefaultHeartbeatTimeout, "how long a connection may stay silent before it is dropped")
+	discoverable := flag.Bool("discoverable", true, "whether clients on the local network can find the server")
+	adminToken := flag.String("admin-token", os.Getenv("NINJAGO_ADMIN_TOKEN"), "the token authorizing the requests to the admin API, which is disabled if empty")
+	password := flag.String("password", os.Getenv("NINJAGO_PASSWORD"), "the password players have to give to join, open to everyone if empty")
+	rooms := flag.Int("rooms", 0, "the number of rooms to host at once, 0 to host a single match without rooms")
+	maxRooms := flag.Int("max-rooms", multiplayer.DefaultMaxRooms, "the number of rooms the server hosts at most")
+	useTLS := flag.Bool("tls", false, "whether to only accept secure connections, with a self-signed certificate unless -cert and -key are given")
+	certFile := flag.String("cert", "", "the path to the certificate of the server, enabling TLS")
+	keyFile := flag.String("key", "", "the path to the private key of the certificate")
+	flag.Parse()
+
+	config := multiplayer.DefaultServerConfig(*level)
+	config.Address = net.JoinHostPort(*addr, strconv.Itoa(*port))
+	if *levelDir != "" {
+		config.LevelDir = *levelDir
+	}
+	config.MaxPlayers = *maxPlayers
+	config.MaxSpectators = *maxSpectators
+	config.TickRate = *tickRate
+	config.StartPlayers = *startPlayers
+	config.Discoverable = *discoverable
+	config.ResumeGrace = *resumeGrace
+	config.HeartbeatInterval = *heartbeat
+	config.HeartbeatTimeout = *heartbeatTimeout
+	config.AdminToken = *adminToken
+	config.Password = *password
+	config.TLS = *useTLS || *certFile != ""
+	config.CertFile = *certFile
+	config.KeyFile = *keyFile
+
+	var closeServer func()
+	if *rooms > 0 {
+		closeServer = runRooms(config, *rooms, *maxRooms)
+	} else {
+		closeServer = runServer(config)
+	}
+
+	// run until the process is interrupted
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+
+	log.Println("Shutting down server")
+	closeServer()
+}
+
+// runServer starts a server hosting a single match.
+func runServer(config multiplayer.ServerConfig) func() {
+	server, err := multiplayer.NewGameServer(config)
+	if err != nil {
+		log.Fatalln("Invalid server settings:", err)
+	}
+
+	closeServer, err := server.Run()
+	if err != nil {
+		log.Fatalln("Failed to start server:", err)
+	}
+
+	return closeServer
+}
+
+// runRooms starts a room manager hosting the given number of rooms.
+func runRooms(config multiplayer.ServerConfig, rooms int, maxRooms int) func() {
+	manager, err := multiplayer.NewRoomManager(config, max(rooms, maxRooms))
+	if err != nil {
+		log.Fatalln("Invalid server settings:", err)
+	}
+
+	for i := 1; i <= rooms; i++ {
+		if _, err := manager.CreateRoom(multiplayer.RoomRequest{ID: strconv.Itoa(i)}); err != nil {
+			log.Fatalln("Failed to create room:", err)
+		}
+	}
+
+	closeServer, err := manager.Run()
+	if err != nil {
+		log.Fatalln("Failed to start server:", err)
+	}
+
+	return closeServer
+}
@@ -0,0 +1,46 @@
+package collider
+
+import "math"
+
+// Vector represents a point in space
+type Vector struct {
+	X, Y float64
+}
+
+// NewVector returns a new *Vector
+func NewVector(x, y float64) *Vector {
+	return &Vector{x, y}
+}
+
+// Mult multiplies v by scaler and returns a new Vector for chaining
+func (v *Vector) Mult(scalar float64) *Vector {
+	return &Vector{
+		v.X * scalar,
+		v.Y * scalar,
+	}
+}
+
+// Sub subtracts o from v and returns a new Vector
+func (v *Vector) Sub(o *Vector) *Vector {
+	return &Vector{
+		v.X - o.X,
+		v.Y - o.Y,
+	}
+}
+
+// Length returns the length of the vector
+func (v *Vector) Length() float64 {
+	return math.Sqrt(v.X*v.X + v.Y*v.Y)
+}
+
+// Normalize returns a new Vector representing the normal of v
+func (v *Vector) Normalize() *Vector {
+	l := v.Length()
+	if l > 0 {
+		return &Vector{
+			v.X / l,
+			v.Y / l,
+		}
+	}
+	return NewVector(v.X, v.Y)
+}
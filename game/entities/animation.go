@@ -2,27 +2,13 @@
 // and their animations within the game world.
 package entities
 
-import (
-	"image"
-	"log"
-
-	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
-	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/assets"
-
-	"github.com/hajimehoshi/ebiten/v2"
-)
-
 // Animation represents a sprite animation with multiple frames.
-// It includes the sprite image, frame dimensions, and animation speed.
+// It keeps track of the frame to show, the sprite itself is loaded and drawn by the scenes.
 type Animation struct {
-	sprite         *ebiten.Image // The sprite image containing all frames of the animation.
-	frameOX        int           // The x offset of the first frame in the sprite.
-	frameOY        int           // The y offset of the first frame in the sprite.
-	frameWidth     int           // The width of each frame.
-	frameHeight    int           // The height of each frame.
-	frameCount     int           // The total number of frames in the animation.
-	currentFrame   int           // The current frame index in the animation.
-	animationSpeed int           // The speed of the animation (frames per update).
+	spritePath     string // The asset path of the sprite containing the frames of the animation side by side.
+	frameCount     int    // The total number of frames in the animation.
+	currentFrame   int    // The current frame index in the animation.
+	animationSpeed int    // The speed of the animation (frames per update).
 }
 
 // Update advances the animation to the next frame based on the animation speed.
@@ -30,14 +16,14 @@ func (a *Animation) Update() {
 	a.currentFrame++
 }
 
-// Draw renders the current frame of the animation at the specified position on the screen.
-// It takes into account the screen width and height for positioning.
-func (a *Animation) Draw(screen *ebiten.Image, x, y float64, screenWidth float64, screenHeight float64) {
-	op := &ebiten.DrawImageOptions{}
-	op.GeoM.Translate(x, y)
-	i := (a.currentFrame / a.animationSpeed) % a.frameCount
-	sx, sy := a.frameOX+i*a.frameWidth, a.frameOY
-	screen.DrawImage(a.sprite.SubImage(image.Rect(sx, sy, sx+a.frameWidth, sy+a.frameHeight)).(*ebiten.Image), op)
+// Frame returns the index of the frame of the sprite currently shown.
+func (a *Animation) Frame() int {
+	return (a.currentFrame / a.animationSpeed) % a.frameCount
+}
+
+// SpritePath returns the asset path of the sprite of the animation.
+func (a *Animation) SpritePath() string {
+	return a.spritePath
 }
 
 // LoadAnimations loads sprite animations from the provided paths and initializes them with the specified frame count and animation speed.
@@ -46,19 +32,8 @@ func LoadAnimations(frameCount int, animationSpeed int, spritePaths map[string]s
 	animations := make(map[string]*Animation)
 
 	for animationName, spritePath := range spritePaths {
-		sprite, _, err := ebitenutil.NewImageFromFileSystem(assets.EmbeddedAssets, spritePath)
-		if err != nil {
-			log.Fatalf("Failed to load sprite: %v", err)
-		}
-
-		_, spriteHeight := sprite.Bounds().Dx(), sprite.Bounds().Dy()
-
 		animation := &Animation{
-			sprite:         sprite,
-			frameOX:        0,
-			frameOY:        0,
-			frameWidth:     spriteHeight,
-			frameHeight:    spriteHeight,
+			spritePath:     spritePath,
 			frameCount:     frameCount,
 			currentFrame:   0,
 			animationSpeed: animationSpeed,
@@ -3,7 +3,7 @@
 package entities
 
 import (
-	collider "github.com/vcscsvcscs/ebiten-collider"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/collider"
 )
 
 // Bomb represents a bomb entity in the game, which can be placed by a player
@@ -5,7 +5,7 @@ package entities
 import (
 	"math/rand"
 
-	collider "github.com/vcscsvcscs/ebiten-collider"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/collider"
 )
 
 // Box represents a box entity in the game, which can be either blank or contain
@@ -31,17 +31,17 @@ func NewBox(colliderSpace *collider.SpatialHash, start_pos_x float64, start_pos_
 	return b
 }
 
-// DropRandomStatusEffect drops a random status effect from the box with a 40% chance.
+// DropRandomStatusEffect drops a random status effect from the box with a 40% chance, rolling the dice with rng.
 // It returns an Effect representing the dropped status effect.
-func (b *Box) DropRandomStatusEffect() Effect {
-	randomEffect := rand.Intn(8) + 1
-	randomNumber := rand.Intn(100) + 1
+func (b *Box) DropRandomStatusEffect(rng *rand.Rand) Effect {
+	randomEffect := rng.Intn(8) + 1
+	randomNumber := rng.Intn(100) + 1
 	if randomNumber <= 40 {
 		switch randomEffect {
 		case 1:
 			return NewObstacleEffect(b.collider.GetHash(), b.collider.GetPosition().X, b.collider.GetPosition().Y)
 		case 2:
-			return NewSkullDebuff(b.collider.GetHash(), b.collider.GetPosition().X, b.collider.GetPosition().Y)
+			return NewSkullDebuff(b.collider.GetHash(), b.collider.GetPosition().X, b.collider.GetPosition().Y, rng)
 		case 3:
 			return NewRollerEffect(b.collider.GetHash(), b.collider.GetPosition().X, b.collider.GetPosition().Y)
 		case 4:
@@ -5,14 +5,13 @@ import (
 	"math/rand"
 	"testing"
 
-	collider "github.com/vcscsvcscs/ebiten-collider"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/collider"
 )
 
 func TestDropRandomStatusEffect(t *testing.T) {
 	colliderSpace := collider.NewSpatialHash(16)
 	box := NewBox(colliderSpace, 50.0, 60.0, false)
-
-	rand.Int()
+	rng := rand.New(rand.NewSource(1))
 
 	tests := []struct {
 		name     string
@@ -25,7 +24,7 @@ func TestDropRandomStatusEffect(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			effect := box.DropRandomStatusEffect()
+			effect := box.DropRandomStatusEffect(rng)
 			effectType := fmt.Sprintf("%T", effect)
 
 			if effectType != tt.expected {
@@ -0,0 +1,28 @@
+// Package entities provides the definition and implementation of game entities
+// and the tick clock their timers run on.
+package entities
+
+// TicksPerSecond is the number of ticks simulated per second of game time.
+const TicksPerSecond = 60
+
+// Clock counts the ticks simulated in a match. It is shared by the entities of the match,
+// so their timers follow the simulation instead of the wall clock and behave the same
+// when the simulation is paused, fast-forwarded or run headless.
+type Clock struct {
+	now uint64 // The number of ticks simulated so far.
+}
+
+// NewClock creates a new clock that has not ticked yet.
+func NewClock() *Clock {
+	return &Clock{}
+}
+
+// Now returns the number of ticks simulated so far.
+func (c *Clock) Now() uint64 {
+	return c.now
+}
+
+// Advance moves the clock forward by a single tick.
+func (c *Clock) Advance() {
+	c.now++
+}
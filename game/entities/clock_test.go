@@ -0,0 +1,18 @@
+package entities
+
+import "testing"
+
+func TestClock(t *testing.T) {
+	clock := NewClock()
+	if clock.Now() != 0 {
+		t.Errorf("Expected a new clock at tick 0, got %d", clock.Now())
+	}
+
+	for i := 0; i < 5; i++ {
+		clock.Advance()
+	}
+
+	if clock.Now() != 5 {
+		t.Errorf("Expected tick 5, got %d", clock.Now())
+	}
+}
@@ -4,11 +4,9 @@
 package entities
 
 import (
-	"log"
+	"math/rand"
 
-	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
-	collider "github.com/vcscsvcscs/ebiten-collider"
-	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/assets"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/collider"
 )
 
 // Effect represents a game effect that includes an entity and a status effect.
@@ -17,22 +15,11 @@ type Effect struct {
 	StatusEffect StatusEffect
 }
 
-// NewBombEffect creates a new bomb effect at the specified position.
-// It initializes the effect with a collider and an idle animation.
-func NewBombEffect(colliderSpace *collider.SpatialHash, start_pos_x float64, start_pos_y float64) Effect {
-	idleSprite, _, err := ebitenutil.NewImageFromFileSystem(assets.EmbeddedAssets, "assets/powerup/BombIncrease.png")
-	if err != nil {
-		log.Fatalf("Failed to load idle sprite: %v", err)
-	}
-
-	idleSpriteHeight := idleSprite.Bounds().Dy()
-
+// newEffect creates a new effect at the specified position, granting the given status effect when picked up.
+// It initializes the effect with a circular collider and an idle animation of the given sprite.
+func newEffect(collisionSpace *collider.SpatialHash, start_pos_x float64, start_pos_y float64, spritePath string, statusEffect StatusEffect) Effect {
 	idleAnimation := &Animation{
-		sprite:         idleSprite,
-		frameOX:        0,
-		frameOY:        0,
-		frameWidth:     idleSpriteHeight,
-		frameHeight:    idleSpriteHeight,
+		spritePath:     spritePath,
 		frameCount:     1,
 		currentFrame:   0,
 		animationSpeed: 24,
@@ -40,275 +27,64 @@ func NewBombEffect(colliderSpace *collider.SpatialHash, start_pos_x float64, sta
 
 	e := Effect{
 		entity: entity{
-			collider:         colliderSpace.NewCircleShape(start_pos_x, start_pos_y, float64(idleSpriteHeight)/2),
+			collider:         collisionSpace.NewCircleShape(start_pos_x, start_pos_y, 8),
 			speed:            0,
 			currentAnimation: "idle",
 			animations: map[string]*Animation{
 				"idle": idleAnimation,
 			},
 		},
-		StatusEffect: NewBombInc(),
+		StatusEffect: statusEffect,
 	}
 	e.collider.SetParent(e)
 
 	return e
 }
 
+// NewBombEffect creates a new bomb effect at the specified position.
+// It initializes the effect with a collider and an idle animation.
+func NewBombEffect(colliderSpace *collider.SpatialHash, start_pos_x float64, start_pos_y float64) Effect {
+	return newEffect(colliderSpace, start_pos_x, start_pos_y, "assets/powerup/BombIncrease.png", NewBombInc())
+}
+
 // NewRadiusEffect creates a new radius effect at the specified position.
 // It initializes the effect with a collider and an idle animation.
 func NewRadiusEffect(collisionSpace *collider.SpatialHash, start_pos_x float64, start_pos_y float64) Effect {
-	idleSprite, _, err := ebitenutil.NewImageFromFileSystem(assets.EmbeddedAssets, "assets/powerup/radiusIncrease.png")
-	if err != nil {
-		log.Fatalf("Failed to load idle sprite: %v", err)
-	}
-
-	idleSpriteHeight := idleSprite.Bounds().Dy()
-
-	idleAnimation := &Animation{
-		sprite:         idleSprite,
-		frameOX:        0,
-		frameOY:        0,
-		frameWidth:     idleSpriteHeight,
-		frameHeight:    idleSpriteHeight,
-		frameCount:     1,
-		currentFrame:   0,
-		animationSpeed: 24,
-	}
-
-	e := Effect{
-		entity: entity{
-			collider:         collisionSpace.NewCircleShape(start_pos_x, start_pos_y, float64(idleSpriteHeight)/2),
-			speed:            0,
-			currentAnimation: "idle",
-			animations: map[string]*Animation{
-				"idle": idleAnimation,
-			},
-		},
-		StatusEffect: NewRadiusInc(),
-	}
-	e.collider.SetParent(e)
-
-	return e
+	return newEffect(collisionSpace, start_pos_x, start_pos_y, "assets/powerup/radiusIncrease.png", NewRadiusInc())
 }
 
-// NewSkullDebuff creates a new skull debuff effect at the specified position.
+// NewSkullDebuff creates a new skull debuff effect at the specified position, drawing its debuff type from rng.
 // It initializes the effect with a collider and an idle animation.
-func NewSkullDebuff(collisionSpace *collider.SpatialHash, start_pos_x float64, start_pos_y float64) Effect {
-	idleSprite, _, err := ebitenutil.NewImageFromFileSystem(assets.EmbeddedAssets, "assets/powerup/SkullDecrease.png")
-	if err != nil {
-		log.Fatalf("Failed to load idle sprite: %v", err)
-	}
-
-	idleSpriteHeight := idleSprite.Bounds().Dy()
-
-	idleAnimation := &Animation{
-		sprite:         idleSprite,
-		frameOX:        0,
-		frameOY:        0,
-		frameWidth:     idleSpriteHeight,
-		frameHeight:    idleSpriteHeight,
-		frameCount:     1,
-		currentFrame:   0,
-		animationSpeed: 24,
-	}
-
-	e := Effect{
-		entity: entity{
-			collider:         collisionSpace.NewCircleShape(start_pos_x, start_pos_y, float64(idleSpriteHeight)/2),
-			speed:            0,
-			currentAnimation: "idle",
-			animations: map[string]*Animation{
-				"idle": idleAnimation,
-			},
-		},
-		StatusEffect: NewSkullDeb(),
-	}
-	e.collider.SetParent(e)
-
-	return e
+func NewSkullDebuff(collisionSpace *collider.SpatialHash, start_pos_x float64, start_pos_y float64, rng *rand.Rand) Effect {
+	return newEffect(collisionSpace, start_pos_x, start_pos_y, "assets/powerup/SkullDecrease.png", NewSkullDeb(rng))
 }
 
 // NewRollerEffect creates a new roller effect at the specified position.
 // It initializes the effect with a collider and an idle animation.
 func NewRollerEffect(collisionSpace *collider.SpatialHash, start_pos_x float64, start_pos_y float64) Effect {
-	idleSprite, _, err := ebitenutil.NewImageFromFileSystem(assets.EmbeddedAssets, "assets/powerup/Roller.png")
-	if err != nil {
-		log.Fatalf("Failed to load idle sprite: %v", err)
-	}
-
-	idleSpriteHeight := idleSprite.Bounds().Dy()
-
-	idleAnimation := &Animation{
-		sprite:         idleSprite,
-		frameOX:        0,
-		frameOY:        0,
-		frameWidth:     idleSpriteHeight,
-		frameHeight:    idleSpriteHeight,
-		frameCount:     1,
-		currentFrame:   0,
-		animationSpeed: 24,
-	}
-
-	e := Effect{
-		entity: entity{
-			collider:         collisionSpace.NewCircleShape(start_pos_x, start_pos_y, float64(idleSpriteHeight)/2),
-			speed:            0,
-			currentAnimation: "idle",
-			animations: map[string]*Animation{
-				"idle": idleAnimation,
-			},
-		},
-		StatusEffect: NewRollerInc(),
-	}
-	e.collider.SetParent(e)
-
-	return e
+	return newEffect(collisionSpace, start_pos_x, start_pos_y, "assets/powerup/Roller.png", NewRollerInc())
 }
 
 // NewObstacleEffect creates a new obstacle effect at the specified position.
 // It initializes the effect with a collider and an idle animation.
 func NewObstacleEffect(collisionSpace *collider.SpatialHash, start_pos_x float64, start_pos_y float64) Effect {
-	idleSprite, _, err := ebitenutil.NewImageFromFileSystem(assets.EmbeddedAssets, "assets/powerup/Obstacle.png")
-	if err != nil {
-		log.Fatalf("Failed to load idle sprite: %v", err)
-	}
-
-	idleSpriteHeight := idleSprite.Bounds().Dy()
-
-	idleAnimation := &Animation{
-		sprite:         idleSprite,
-		frameOX:        0,
-		frameOY:        0,
-		frameWidth:     idleSpriteHeight,
-		frameHeight:    idleSpriteHeight,
-		frameCount:     1,
-		currentFrame:   0,
-		animationSpeed: 24,
-	}
-
-	e := Effect{
-		entity: entity{
-			collider:         collisionSpace.NewCircleShape(start_pos_x, start_pos_y, float64(idleSpriteHeight)/2),
-			speed:            0,
-			currentAnimation: "idle",
-			animations: map[string]*Animation{
-				"idle": idleAnimation,
-			},
-		},
-		StatusEffect: NewObstacleInc(),
-	}
-	e.collider.SetParent(e)
-
-	return e
+	return newEffect(collisionSpace, start_pos_x, start_pos_y, "assets/powerup/Obstacle.png", NewObstacleInc())
 }
 
 // NewDetonatorEffect creates a new detonator effect at the specified position.
 // It initializes the effect with a collider and an idle animation.
 func NewDetonatorEffect(collisionSpace *collider.SpatialHash, start_pos_x float64, start_pos_y float64) Effect {
-	idleSprite, _, err := ebitenutil.NewImageFromFileSystem(assets.EmbeddedAssets, "assets/powerup/Detonator.png")
-	if err != nil {
-		log.Fatalf("Failed to load idle sprite: %v", err)
-	}
-
-	idleSpriteHeight := idleSprite.Bounds().Dy()
-
-	idleAnimation := &Animation{
-		sprite:         idleSprite,
-		frameOX:        0,
-		frameOY:        0,
-		frameWidth:     idleSpriteHeight,
-		frameHeight:    idleSpriteHeight,
-		frameCount:     1,
-		currentFrame:   0,
-		animationSpeed: 24,
-	}
-
-	e := Effect{
-		entity: entity{
-			collider:         collisionSpace.NewCircleShape(start_pos_x, start_pos_y, float64(idleSpriteHeight)/2),
-			speed:            0,
-			currentAnimation: "idle",
-			animations: map[string]*Animation{
-				"idle": idleAnimation,
-			},
-		},
-		StatusEffect: NewDetonatorInc(),
-	}
-	e.collider.SetParent(e)
-
-	return e
+	return newEffect(collisionSpace, start_pos_x, start_pos_y, "assets/powerup/Detonator.png", NewDetonatorInc())
 }
 
 // NewGhostEffect creates a new ghost effect at the specified position.
 // It initializes the effect with a collider and an idle animation.
 func NewGhostEffect(collisionSpace *collider.SpatialHash, start_pos_x float64, start_pos_y float64) Effect {
-	idleSprite, _, err := ebitenutil.NewImageFromFileSystem(assets.EmbeddedAssets, "assets/powerup/Ghost.png")
-	if err != nil {
-		log.Fatalf("Failed to load idle sprite: %v", err)
-	}
-
-	idleSpriteHeight := idleSprite.Bounds().Dy()
-
-	idleAnimation := &Animation{
-		sprite:         idleSprite,
-		frameOX:        0,
-		frameOY:        0,
-		frameWidth:     idleSpriteHeight,
-		frameHeight:    idleSpriteHeight,
-		frameCount:     1,
-		currentFrame:   0,
-		animationSpeed: 24,
-	}
-
-	e := Effect{
-		entity: entity{
-			collider:         collisionSpace.NewCircleShape(start_pos_x, start_pos_y, float64(idleSpriteHeight)/2),
-			speed:            0,
-			currentAnimation: "idle",
-			animations: map[string]*Animation{
-				"idle": idleAnimation,
-			},
-		},
-		StatusEffect: NewGhostInc(),
-	}
-	e.collider.SetParent(e)
-
-	return e
+	return newEffect(collisionSpace, start_pos_x, start_pos_y, "assets/powerup/Ghost.png", NewGhostInc())
 }
 
 // NewInvincibilityEffect creates a new invincibility effect at the specified position.
 // It initializes the effect with a collider and an idle animation.
 func NewInvincibilityEffect(collisionSpace *collider.SpatialHash, start_pos_x float64, start_pos_y float64) Effect {
-	idleSprite, _, err := ebitenutil.NewImageFromFileSystem(assets.EmbeddedAssets, "assets/powerup/Invincibility.png")
-	if err != nil {
-		log.Fatalf("Failed to load idle sprite: %v", err)
-	}
-
-	idleSpriteHeight := idleSprite.Bounds().Dy()
-
-	idleAnimation := &Animation{
-		sprite:         idleSprite,
-		frameOX:        0,
-		frameOY:        0,
-		frameWidth:     idleSpriteHeight,
-		frameHeight:    idleSpriteHeight,
-		frameCount:     1,
-		currentFrame:   0,
-		animationSpeed: 24,
-	}
-
-	e := Effect{
-		entity: entity{
-			collider:         collisionSpace.NewCircleShape(start_pos_x, start_pos_y, float64(idleSpriteHeight)/2),
-			speed:            0,
-			currentAnimation: "idle",
-			animations: map[string]*Animation{
-				"idle": idleAnimation,
-			},
-		},
-		StatusEffect: NewInvincibilityInc(),
-	}
-	e.collider.SetParent(e)
-
-	return e
+	return newEffect(collisionSpace, start_pos_x, start_pos_y, "assets/powerup/Invincibility.png", NewInvincibilityInc())
 }
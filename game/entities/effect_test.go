@@ -1,9 +1,10 @@
 package entities
 
 import (
+	"math/rand"
 	"testing"
 
-	collider "github.com/vcscsvcscs/ebiten-collider"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/collider"
 )
 
 func TestNewBombEffect(t *testing.T) {
@@ -47,7 +48,7 @@ func TestNewRadiusEffect(t *testing.T) {
 func TestNewSkullDebuff(t *testing.T) {
 	colliderSpace := collider.NewSpatialHash(16)
 	x, y := 50.0, 60.0
-	effect := NewSkullDebuff(colliderSpace, x, y)
+	effect := NewSkullDebuff(colliderSpace, x, y, rand.New(rand.NewSource(1)))
 
 	if effect.StatusEffect == nil {
 		t.Error("Expected StatusEffect to be initialized")
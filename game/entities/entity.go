@@ -1,23 +1,24 @@
 // Package entities provides the definition and implementation of game entities
-// that can be updated, drawn, and animated. It includes the Entity interface and
-// the concrete implementation of the entity struct.
+// that can be updated and animated. It includes the Entity interface and
+// the concrete implementation of the entity struct. Drawing the entities is left
+// to the scenes, so the game rules run without ebiten.
 package entities
 
 import (
 	"fmt"
 	"math"
+	"sort"
 
-	"github.com/hajimehoshi/ebiten/v2"
-	collider "github.com/vcscsvcscs/ebiten-collider"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/collider"
 )
 
-// Entity defines the behavior of a game entity which can be updated, drawn,
-// and have animations. It provides methods to get the entity's position and collider.
+// Entity defines the behavior of a game entity which can be updated
+// and have animations. It provides methods to get the entity's position, sprite and collider.
 type Entity interface {
 	// Update updates the entity's state.
 	Update() error
-	// Draw draws the entity on the screen.
-	Draw(screen *ebiten.Image)
+	// Sprite returns the asset path of the sprite the entity is drawn with and the index of its current frame.
+	Sprite() (string, int)
 	// SetCurrentAnimation sets the current animation of the entity.
 	SetCurrentAnimation(animationKey string) error
 	// TilePosition returns the entity's position in terms of tile coordinates.
@@ -69,12 +70,14 @@ func (e *entity) Update() error {
 	return fmt.Errorf("animation with key %s not found", e.currentAnimation)
 }
 
-// Draw draws the entity's current animation on the provided screen at the entity's position.
-// The drawing takes into account the screen dimensions.
-func (e *entity) Draw(screen *ebiten.Image) {
+// Sprite returns the asset path of the sprite of the entity's current animation and the index of its current frame.
+// An entity without a current animation returns an empty path.
+func (e *entity) Sprite() (string, int) {
 	if animation, ok := e.animations[e.currentAnimation]; ok {
-		animation.Draw(screen, e.collider.GetPosition().X, e.collider.GetPosition().Y, float64(screen.Bounds().Dx()), float64(screen.Bounds().Dy()))
+		return animation.SpritePath(), animation.Frame()
 	}
+
+	return "", 0
 }
 
 // SetCurrentAnimation sets the current animation of the entity to the animation specified by animationKey.
@@ -88,3 +91,59 @@ func (e *entity) SetCurrentAnimation(animationKey string) error {
 
 	return fmt.Errorf("animation with key %s not found", animationKey)
 }
+
+// CheckCollisions returns the collisions of the given shape in a deterministic order.
+// The spatial hash reports collisions in map iteration order, which would make the outcome
+// of a tick depend on chance, so the collisions are sorted by the bounds of the other shape
+// and then by the kind of entity owning it.
+//
+// Parameters:
+//   - shape: The shape to check the collisions of.
+//
+// Returns:
+//   - []collider.CollisionData: The collisions of the shape.
+func CheckCollisions(shape collider.Shape) []collider.CollisionData {
+	collisions := shape.GetHash().CheckCollisions(shape)
+
+	sort.SliceStable(collisions, func(i, j int) bool {
+		iMinX, iMinY, iMaxX, iMaxY := collisions[i].Other.GetBounds()
+		jMinX, jMinY, jMaxX, jMaxY := collisions[j].Other.GetBounds()
+
+		switch {
+		case iMinX != jMinX:
+			return iMinX < jMinX
+		case iMinY != jMinY:
+			return iMinY < jMinY
+		case iMaxX != jMaxX:
+			return iMaxX < jMaxX
+		case iMaxY != jMaxY:
+			return iMaxY < jMaxY
+		}
+
+		return collisionRank(collisions[i].Other) < collisionRank(collisions[j].Other)
+	})
+
+	return collisions
+}
+
+// collisionRank orders the kinds of entities that can own a collider.
+func collisionRank(shape collider.Shape) int {
+	switch shape.GetParent().(type) {
+	case Terrain:
+		return 0
+	case *Box:
+		return 1
+	case *Bomb:
+		return 2
+	case *Explosion:
+		return 3
+	case Effect:
+		return 4
+	case Monster:
+		return 5
+	case *Player:
+		return 6
+	default:
+		return 7
+	}
+}
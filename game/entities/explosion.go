@@ -3,34 +3,20 @@
 package entities
 
 import (
-	"log"
-
-	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
-	collider "github.com/vcscsvcscs/ebiten-collider"
-	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/assets"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/collider"
 )
 
 type Explosion struct {
-	entity     // The explosion entity inherits from the base entity.
-	time   int // The lifetime of the explosion in update ticks.
+	entity         // The explosion entity inherits from the base entity.
+	time   int     // The lifetime of the explosion in update ticks.
+	Owner  *Player // The player whose bomb caused the explosion, or nil.
 }
 
 // NewExplosion creates a new explosion entity at the specified position.
 // It initializes the explosion with a circular collider and an idle animation.
 func NewExplosion(collisionSpace *collider.SpatialHash, start_pos_x float64, start_pos_y float64) *Explosion {
-	idleSprite, _, err := ebitenutil.NewImageFromFileSystem(assets.EmbeddedAssets, "assets/graphics/explosion_animation.png")
-	if err != nil {
-		log.Fatalf("Failed to load idle sprite: %v", err)
-	}
-
-	idleSpriteHeight := idleSprite.Bounds().Dy()
-
 	idleAnimation := &Animation{
-		sprite:         idleSprite,
-		frameOX:        0,
-		frameOY:        0,
-		frameWidth:     idleSpriteHeight,
-		frameHeight:    idleSpriteHeight,
+		spritePath:     "assets/graphics/explosion_animation.png",
 		frameCount:     8,
 		currentFrame:   0,
 		animationSpeed: 24,
@@ -38,7 +24,7 @@ func NewExplosion(collisionSpace *collider.SpatialHash, start_pos_x float64, sta
 
 	e := &Explosion{
 		entity: entity{
-			collider:         collisionSpace.NewCircleShape(start_pos_x, start_pos_y, float64(idleSpriteHeight)/2),
+			collider:         collisionSpace.NewCircleShape(start_pos_x, start_pos_y, 8),
 			speed:            0,
 			currentAnimation: "idle",
 			animations: map[string]*Animation{
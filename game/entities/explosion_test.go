@@ -3,7 +3,7 @@ package entities
 import (
 	"testing"
 
-	collider "github.com/vcscsvcscs/ebiten-collider"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/collider"
 )
 
 func TestNewExplosion(t *testing.T) {
@@ -1,7 +1,10 @@
 package entities
 
 import (
+	"math/rand"
 	"testing"
+
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/collider"
 )
 
 func TestDirectionTowardsTarget(t *testing.T) {
@@ -27,3 +30,27 @@ func TestDirectionTowardsTarget(t *testing.T) {
 		})
 	}
 }
+
+func TestGhostTurnsOnTicks(t *testing.T) {
+	clock := NewClock()
+	ghost := NewGhost(collider.NewSpatialHash(16), 80, 80, 272, 272, rand.New(rand.NewSource(1)), clock).(*Ghost)
+
+	ghost.Update()
+	if ghost.nextDirectionChange != ghostTurnInterval {
+		t.Errorf("Expected the next turn at tick %d, got %d", ghostTurnInterval, ghost.nextDirectionChange)
+	}
+
+	for i := 1; i < ghostTurnInterval; i++ {
+		clock.Advance()
+		ghost.Update()
+	}
+	if ghost.nextDirectionChange != ghostTurnInterval {
+		t.Errorf("Expected no turn before tick %d, got the next turn at tick %d", ghostTurnInterval, ghost.nextDirectionChange)
+	}
+
+	clock.Advance()
+	ghost.Update()
+	if ghost.nextDirectionChange != 2*ghostTurnInterval {
+		t.Errorf("Expected the next turn at tick %d, got %d", 2*ghostTurnInterval, ghost.nextDirectionChange)
+	}
+}
@@ -5,9 +5,8 @@ package entities
 import (
 	"math"
 	"math/rand"
-	"time"
 
-	collider "github.com/vcscsvcscs/ebiten-collider"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/collider"
 )
 
 // snapToGrid snaps the given coordinates to the nearest 16x16 grid position
@@ -25,6 +24,9 @@ func snapToGrid(x, y float64) (float64, float64) {
 	return x, y
 }
 
+// ghostTurnInterval is the number of ticks a ghost keeps its direction before picking a new one.
+const ghostTurnInterval = TicksPerSecond / 2
+
 // Monster defines the behavior of a monster entity in the game.
 // It extends the Entity interface with additional methods for setting a target and updating the monster's state.
 type Monster interface {
@@ -35,7 +37,8 @@ type Monster interface {
 
 // NewGhost creates a new ghost monster entity at the specified position.
 // It initializes the ghost with a circular collider and walk animations for different directions.
-func NewGhost(collisionSpace *collider.SpatialHash, x, y float64, gameWidth, gameHeight float64) Monster {
+// Its direction changes are drawn from rng and timed by clock.
+func NewGhost(collisionSpace *collider.SpatialHash, x, y float64, gameWidth, gameHeight float64, rng *rand.Rand, clock *Clock) Monster {
 	spritePaths := map[string]string{
 		"walkLeft":  "assets/enemy/ghost/ghost-left.png",
 		"walkRight": "assets/enemy/ghost/ghost-right.png",
@@ -52,6 +55,8 @@ func NewGhost(collisionSpace *collider.SpatialHash, x, y float64, gameWidth, gam
 		},
 		gameWidth:  gameWidth,
 		gameHeight: gameHeight,
+		rng:        rng,
+		clock:      clock,
 	}
 	m.collider.SetParent((Monster)(m))
 
@@ -60,7 +65,8 @@ func NewGhost(collisionSpace *collider.SpatialHash, x, y float64, gameWidth, gam
 
 // NewBallon creates a new balloon monster entity at the specified position.
 // It initializes the balloon with a circular collider and walk animations for different directions.
-func NewBallon(collisionSpace *collider.SpatialHash, x, y float64) Monster {
+// Its direction changes are drawn from rng.
+func NewBallon(collisionSpace *collider.SpatialHash, x, y float64, rng *rand.Rand) Monster {
 	spritePaths := map[string]string{
 		"walkLeft":  "assets/enemy/balloon/balloon-left.png",
 		"walkRight": "assets/enemy/balloon/balloon-right.png",
@@ -75,8 +81,9 @@ func NewBallon(collisionSpace *collider.SpatialHash, x, y float64) Monster {
 			currentAnimation: "walkDown",
 			animations:       LoadAnimations(1, 1, spritePaths),
 		},
-		direction:      rand.Intn(4),
+		direction:      rng.Intn(4),
 		collisionSpace: collisionSpace,
+		rng:            rng,
 	}
 	m.collider.SetParent((Monster)(m))
 
@@ -85,7 +92,8 @@ func NewBallon(collisionSpace *collider.SpatialHash, x, y float64) Monster {
 
 // NewSlime creates a new slime monster entity at the specified position.
 // It initializes the slime with a circular collider and walk animations for different directions.
-func NewSlime(collisionSpace *collider.SpatialHash, x, y float64) Monster {
+// Its direction changes are drawn from rng.
+func NewSlime(collisionSpace *collider.SpatialHash, x, y float64, rng *rand.Rand) Monster {
 	spritePaths := map[string]string{
 		"walkLeft":  "assets/enemy/slime/slime-left.png",
 		"walkRight": "assets/enemy/slime/slime-right.png",
@@ -100,8 +108,9 @@ func NewSlime(collisionSpace *collider.SpatialHash, x, y float64) Monster {
 			currentAnimation: "walkDown",
 			animations:       LoadAnimations(1, 1, spritePaths),
 		},
-		direction:      rand.Intn(4),
+		direction:      rng.Intn(4),
 		collisionSpace: collisionSpace,
+		rng:            rng,
 	}
 	m.collider.SetParent((Monster)(m))
 
@@ -110,7 +119,8 @@ func NewSlime(collisionSpace *collider.SpatialHash, x, y float64) Monster {
 
 // NewOnion creates a new onion monster entity at the specified position.
 // It initializes the onion with a circular collider and walk animations for different directions.
-func NewOnion(collisionSpace *collider.SpatialHash, x, y float64) Monster {
+// Its direction changes are drawn from rng.
+func NewOnion(collisionSpace *collider.SpatialHash, x, y float64, rng *rand.Rand) Monster {
 	spritePaths := map[string]string{
 		"walkLeft":  "assets/enemy/onion/onion-left.png",
 		"walkRight": "assets/enemy/onion/onion-right.png",
@@ -125,8 +135,9 @@ func NewOnion(collisionSpace *collider.SpatialHash, x, y float64) Monster {
 			currentAnimation: "walkDown",
 			animations:       LoadAnimations(1, 1, spritePaths),
 		},
-		direction:      rand.Intn(4),
+		direction:      rng.Intn(4),
 		collisionSpace: collisionSpace,
+		rng:            rng,
 	}
 	m.collider.SetParent((Monster)(m))
 
@@ -136,10 +147,12 @@ func NewOnion(collisionSpace *collider.SpatialHash, x, y float64) Monster {
 // Ghost represents a ghost monster in the game, which moves randomly within the game area.
 type Ghost struct {
 	entity
-	direction           int       // The current direction of the ghost.
-	gameWidth           float64   // The width of the game area.
-	gameHeight          float64   // The height of the game area.
-	lastDirectionChange time.Time // The time of the last direction change.
+	direction           int        // The current direction of the ghost.
+	gameWidth           float64    // The width of the game area.
+	gameHeight          float64    // The height of the game area.
+	nextDirectionChange uint64     // The tick of the next direction change.
+	rng                 *rand.Rand // The random source for direction changes.
+	clock               *Clock     // The clock timing the direction changes.
 }
 
 // SetTarget sets the target player for the ghost.
@@ -150,9 +163,9 @@ func (g *Ghost) SetTarget(target *Player) {
 // Update updates the ghost's state, changing its direction randomly and moving it accordingly.
 // It handles collisions with other entities and the boundaries of the game area.
 func (g *Ghost) Update() error {
-	if time.Since(g.lastDirectionChange) > time.Second/2 {
-		g.direction = rand.Intn(4)
-		g.lastDirectionChange = time.Now()
+	if g.clock.Now() >= g.nextDirectionChange {
+		g.direction = g.rng.Intn(4)
+		g.nextDirectionChange = g.clock.Now() + ghostTurnInterval
 	}
 
 	switch g.direction {
@@ -171,7 +184,7 @@ func (g *Ghost) Update() error {
 	gridX, gridY := snapToGrid(currentX, currentY)
 	g.GetCollider().MoveTo(gridX, gridY)
 
-	ghostCollision := g.collider.GetHash().CheckCollisions(g.collider)
+	ghostCollision := CheckCollisions(g.collider)
 
 	for _, collision := range ghostCollision {
 		sep := collision.SeparatingVector
@@ -181,7 +194,7 @@ func (g *Ghost) Update() error {
 			break
 		case *Bomb:
 			g.GetCollider().Move(sep.X, sep.Y)
-			g.direction = rand.Intn(4)
+			g.direction = g.rng.Intn(4)
 		case *terrain:
 			if collidingEntity.IsSolid() {
 				continue
@@ -211,6 +224,7 @@ type Ballon struct {
 	direction      int                   // The current direction of the balloon.
 	collisionSpace *collider.SpatialHash // The spatial hash for collision detection.
 	target         *Player               // The target player for the balloon to follow.
+	rng            *rand.Rand            // The random source for direction changes.
 }
 
 // SetTarget sets the target player for the balloon.
@@ -239,7 +253,7 @@ func (b *Ballon) Update() error {
 	b.GetCollider().MoveTo(gridX, gridY)
 
 	// Check for collisions
-	balloonCollision := b.collider.GetHash().CheckCollisions(b.collider)
+	balloonCollision := CheckCollisions(b.collider)
 
 	// Handle collisions
 	for _, collision := range balloonCollision {
@@ -252,10 +266,14 @@ func (b *Ballon) Update() error {
 		case *Bomb, *Box:
 			b.GetCollider().Move(sep.X, sep.Y)
 			// Generate a new direction
-			b.direction = rand.Intn(4)
+			b.direction = b.rng.Intn(4)
 		case *terrain:
 			if collidingEntity.IsSolid() {
 				b.GetCollider().Move(sep.X, sep.Y)
+				if b.target == nil {
+					b.direction = b.rng.Intn(4)
+					continue
+				}
 				monsterX, monsterY := b.GetCollider().GetPosition().X, b.GetCollider().GetPosition().Y
 				targetX, targetY := b.target.GetCollider().GetPosition().X, b.target.GetCollider().GetPosition().Y
 				b.direction = directionTowardsTarget(monsterX, monsterY, targetX, targetY)
@@ -289,11 +307,11 @@ func directionTowardsTarget(monsterX, monsterY, targetX, targetY float64) int {
 
 // Slime represents a slime monster in the game, which moves randomly within the game area.
 type Slime struct {
-	entity                                    // The slime entity inherits the entity properties.
-	target              *Player               // The target player for the slime to follow.
-	direction           int                   // The current direction of the slime.
-	lastDirectionChange time.Time             // The time of the last direction change.
-	collisionSpace      *collider.SpatialHash // The spatial hash for collision detection.
+	entity                               // The slime entity inherits the entity properties.
+	target         *Player               // The target player for the slime to follow.
+	direction      int                   // The current direction of the slime.
+	collisionSpace *collider.SpatialHash // The spatial hash for collision detection.
+	rng            *rand.Rand            // The random source for direction changes.
 }
 
 // SetTarget sets the target player for the slime.
@@ -322,7 +340,7 @@ func (s *Slime) Update() error {
 	s.GetCollider().MoveTo(gridX, gridY)
 
 	// Check for collisions
-	onionCollision := s.collider.GetHash().CheckCollisions(s.collider)
+	onionCollision := CheckCollisions(s.collider)
 
 	// Handle collisions
 	for _, collision := range onionCollision {
@@ -334,15 +352,18 @@ func (s *Slime) Update() error {
 		case *Bomb, *Box:
 			s.GetCollider().Move(sep.X, sep.Y)
 			// Generate a new direction
-			s.direction = rand.Intn(4)
-			s.lastDirectionChange = time.Now()
+			s.direction = s.rng.Intn(4)
 		case *terrain:
 			if collidingEntity.IsSolid() {
 				s.GetCollider().Move(sep.X, sep.Y)
+				if s.target == nil {
+					s.direction = s.rng.Intn(4)
+					continue
+				}
 				monsterX, monsterY := s.GetCollider().GetPosition().X, s.GetCollider().GetPosition().Y
 				targetX, targetY := s.target.GetCollider().GetPosition().X, s.target.GetCollider().GetPosition().Y
-				if rand.Float64() < 0.4 { // 40% chance of making a wrong decision
-					s.direction = rand.Intn(4)
+				if s.rng.Float64() < 0.4 { // 40% chance of making a wrong decision
+					s.direction = s.rng.Intn(4)
 				} else {
 					s.direction = directionTowardsTarget(monsterX, monsterY, targetX, targetY)
 				}
@@ -354,10 +375,10 @@ func (s *Slime) Update() error {
 
 // Onion represents an onion monster in the game, which moves randomly within the game area.
 type Onion struct {
-	entity                                    // The onion entity inherits the entity properties.
-	direction           int                   // The current direction of the onion.
-	lastDirectionChange time.Time             // The time of the last direction change.
-	collisionSpace      *collider.SpatialHash // The spatial hash for collision detection.
+	entity                               // The onion entity inherits the entity properties.
+	direction      int                   // The current direction of the onion.
+	collisionSpace *collider.SpatialHash // The spatial hash for collision detection.
+	rng            *rand.Rand            // The random source for direction changes.
 }
 
 // SetTarget sets the target player for the onion.
@@ -386,7 +407,7 @@ func (o *Onion) Update() error {
 	o.GetCollider().MoveTo(gridX, gridY)
 
 	// Check for collisions
-	onionCollision := o.collider.GetHash().CheckCollisions(o.collider)
+	onionCollision := CheckCollisions(o.collider)
 
 	// Handle collisions
 	for _, collision := range onionCollision {
@@ -399,14 +420,12 @@ func (o *Onion) Update() error {
 		case *Bomb, *Box:
 			o.GetCollider().Move(sep.X, sep.Y)
 			// Generate a new direction
-			o.direction = rand.Intn(4)
-			o.lastDirectionChange = time.Now()
+			o.direction = o.rng.Intn(4)
 		case *terrain:
 			if collidingEntity.IsSolid() {
 				o.GetCollider().Move(sep.X, sep.Y)
 				// Generate a new direction
-				o.direction = rand.Intn(4)
-				o.lastDirectionChange = time.Now()
+				o.direction = o.rng.Intn(4)
 			}
 		}
 	}
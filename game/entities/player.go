@@ -4,10 +4,8 @@ package entities
 
 import (
 	"image/color"
-	"log"
 
-	"github.com/hajimehoshi/ebiten/v2"
-	collider "github.com/vcscsvcscs/ebiten-collider"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/collider"
 	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/userinfo"
 )
 
@@ -21,6 +19,30 @@ type PlayerControls struct {
 	Ability2 bool // Indicates if the player is using ability 2.
 }
 
+// Bits packs the controls into a single byte, one bit per control in field order starting from the lowest bit.
+func (c PlayerControls) Bits() uint8 {
+	var bits uint8
+	for i, pressed := range [...]bool{c.Up, c.Down, c.Left, c.Right, c.Ability1, c.Ability2} {
+		if pressed {
+			bits |= 1 << i
+		}
+	}
+
+	return bits
+}
+
+// PlayerControlsFromBits unpacks controls packed by PlayerControls.Bits.
+func PlayerControlsFromBits(bits uint8) PlayerControls {
+	return PlayerControls{
+		Up:       bits&(1<<0) != 0,
+		Down:     bits&(1<<1) != 0,
+		Left:     bits&(1<<2) != 0,
+		Right:    bits&(1<<3) != 0,
+		Ability1: bits&(1<<4) != 0,
+		Ability2: bits&(1<<5) != 0,
+	}
+}
+
 // Player represents a player entity in the game, with various attributes and abilities.
 type Player struct {
 	entity
@@ -94,24 +116,33 @@ func (p *Player) Update() (bomb *Bomb, box *Box, err error) {
 		bomb = p.PlaceBomb()
 	}
 
-	if p.Control.Up {
+	err = p.Move(p.Control)
+
+	return bomb, box, err
+}
+
+// Move moves the player by a single step in the directions pressed in the controls
+// and turns its animation towards the last of them. Collisions are left to the caller.
+// It returns an error if the animation cannot be set.
+func (p *Player) Move(controls PlayerControls) (err error) {
+	if controls.Up {
 		p.collider.Move(0, -p.speed)
 		err = p.SetCurrentAnimation("walkUp")
 	}
-	if p.Control.Down {
+	if controls.Down {
 		p.collider.Move(0, p.speed)
 		err = p.SetCurrentAnimation("walkDown")
 	}
-	if p.Control.Left {
+	if controls.Left {
 		p.collider.Move(-p.speed, 0)
 		err = p.SetCurrentAnimation("walkLeft")
 	}
-	if p.Control.Right {
+	if controls.Right {
 		p.collider.Move(p.speed, 0)
 		err = p.SetCurrentAnimation("walkRight")
 	}
 
-	return bomb, box, err
+	return err
 }
 
 // PlaceBomb places a bomb at the player's current position if the player can place a bomb and has bombs available.
@@ -141,22 +172,3 @@ func (p *Player) PlaceObstacle() (obstacle *Box) {
 	}
 	return nil
 }
-
-// Draw renders the player on the screen with the current animation and color overlay.
-func (p *Player) Draw(screen *ebiten.Image) {
-	anim, ok := p.animations[p.currentAnimation]
-	if !ok {
-		log.Printf("No animation found for key %s", p.currentAnimation)
-		return
-	}
-	position := p.collider.GetPosition()
-	opts := &ebiten.DrawImageOptions{}
-	opts.GeoM.Translate(position.X, position.Y)
-	r, g, b, a := p.ColorOverLay.RGBA()
-	opts.ColorScale.SetR(float32(r))
-	opts.ColorScale.SetG(float32(g))
-	opts.ColorScale.SetB(float32(b))
-	opts.ColorScale.SetA(float32(a))
-
-	screen.DrawImage(anim.sprite, opts)
-}
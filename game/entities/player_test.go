@@ -4,7 +4,7 @@ import (
 	"image/color"
 	"testing"
 
-	collider "github.com/vcscsvcscs/ebiten-collider"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/collider"
 	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/userinfo"
 )
 
@@ -72,3 +72,27 @@ func TestPlayerPlaceBomb(t *testing.T) {
 		t.Errorf("Expected number of bombs to decrement, remaining: %d", player.NumberOfBombs)
 	}
 }
+
+func TestPlayerControlsBits(t *testing.T) {
+	tests := []struct {
+		name     string
+		controls PlayerControls
+		bits     uint8
+	}{
+		{"None", PlayerControls{}, 0},
+		{"Up", PlayerControls{Up: true}, 1},
+		{"Right and bomb", PlayerControls{Right: true, Ability1: true}, 1<<3 | 1<<4},
+		{"All", PlayerControls{true, true, true, true, true, true}, 63},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.controls.Bits(); got != tt.bits {
+				t.Errorf("Expected bits %b, got %b", tt.bits, got)
+			}
+			if got := PlayerControlsFromBits(tt.bits); got != tt.controls {
+				t.Errorf("Expected controls %+v, got %+v", tt.controls, got)
+			}
+		})
+	}
+}
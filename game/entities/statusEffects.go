@@ -29,9 +29,9 @@ type skullDeb struct {
 	debuffType   int // The type of debuff applied to the player.
 }
 
-// NewSkullDeb creates a new SkullDebuff status effect with a random debuff type.
-func NewSkullDeb() StatusEffect {
-	randomType := rand.Intn(4) + 1
+// NewSkullDeb creates a new SkullDebuff status effect with a debuff type drawn from rng.
+func NewSkullDeb(rng *rand.Rand) StatusEffect {
+	randomType := rng.Intn(4) + 1
 
 	return &skullDeb{
 		statusEffect: statusEffect{
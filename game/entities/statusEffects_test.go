@@ -2,9 +2,10 @@ package entities
 
 import (
 	"image/color"
+	"math/rand"
 	"testing"
 
-	collider "github.com/vcscsvcscs/ebiten-collider"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/collider"
 	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/userinfo"
 )
 
@@ -23,7 +24,7 @@ func setupPlayer() *Player {
 
 func TestSkullDebEffect(t *testing.T) {
 	player := setupPlayer()
-	effect := NewSkullDeb()
+	effect := NewSkullDeb(rand.New(rand.NewSource(3))) // draws the speed debuff
 	player.State = effect
 
 	for i := 0; i < 1800; i++ {
@@ -3,11 +3,7 @@
 package entities
 
 import (
-	"log"
-
-	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
-	collider "github.com/vcscsvcscs/ebiten-collider"
-	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/assets"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/collider"
 )
 
 // terrain represents a basic terrain entity with solidity and destructibility properties.
@@ -38,10 +34,6 @@ func (t *terrain) IsDestroyable() bool {
 // NewWall creates a new wall terrain entity at the specified position with the provided image path.
 // It initializes the wall as a solid and non-destroyable terrain.
 func NewWall(collisionSpace *collider.SpatialHash, x, y float64, imagePath string) Terrain {
-	sprite, _, err := ebitenutil.NewImageFromFileSystem(assets.EmbeddedAssets, imagePath)
-	if err != nil {
-		log.Panic(err)
-	}
 	t := &terrain{
 		entity: entity{
 			collider:         collisionSpace.NewRectangleShape(x, y, 16, 16),
@@ -49,11 +41,7 @@ func NewWall(collisionSpace *collider.SpatialHash, x, y float64, imagePath strin
 			currentAnimation: "idle",
 			animations: map[string]*Animation{
 				"idle": {
-					sprite:         sprite,
-					frameOX:        0,
-					frameOY:        0,
-					frameWidth:     32,
-					frameHeight:    32,
+					spritePath:     imagePath,
 					frameCount:     1,
 					currentFrame:   0,
 					animationSpeed: 10,
@@ -71,10 +59,6 @@ func NewWall(collisionSpace *collider.SpatialHash, x, y float64, imagePath strin
 // NewGrass creates a new grass terrain entity at the specified position with the provided image path.
 // It initializes the grass as a non-solid and non-destroyable terrain.
 func NewGrass(collisionSpace *collider.SpatialHash, x, y float64, imagePath string) Terrain {
-	sprite, _, err := ebitenutil.NewImageFromFileSystem(assets.EmbeddedAssets, imagePath)
-	if err != nil {
-		log.Panic(err)
-	}
 	t := &terrain{
 		entity: entity{
 			collider:         collisionSpace.NewRectangleShape(x, y, 16, 16),
@@ -82,11 +66,7 @@ func NewGrass(collisionSpace *collider.SpatialHash, x, y float64, imagePath stri
 			currentAnimation: "idle",
 			animations: map[string]*Animation{
 				"idle": {
-					sprite:         sprite,
-					frameOX:        0,
-					frameOY:        0,
-					frameWidth:     32,
-					frameHeight:    32,
+					spritePath:     imagePath,
 					frameCount:     1,
 					currentFrame:   0,
 					animationSpeed: 10,
@@ -104,11 +84,6 @@ func NewGrass(collisionSpace *collider.SpatialHash, x, y float64, imagePath stri
 // NewDestroyAbleSolid creates a new destroyable solid terrain entity at the specified position with the provided image path.
 // It initializes the terrain as a solid and destroyable entity.
 func NewDestroyAbleSolid(collisionSpace *collider.SpatialHash, x, y float64, imagePath string) Terrain {
-	sprite, _, err := ebitenutil.NewImageFromFileSystem(assets.EmbeddedAssets, imagePath)
-	if err != nil {
-		log.Panic(err)
-	}
-
 	t := &terrain{
 		entity: entity{
 			collider:         collisionSpace.NewRectangleShape(x, y, 16, 16),
@@ -116,11 +91,7 @@ func NewDestroyAbleSolid(collisionSpace *collider.SpatialHash, x, y float64, ima
 			currentAnimation: "idle",
 			animations: map[string]*Animation{
 				"idle": {
-					sprite:         sprite,
-					frameOX:        0,
-					frameOY:        0,
-					frameWidth:     32,
-					frameHeight:    32,
+					spritePath:     imagePath,
 					frameCount:     1,
 					currentFrame:   0,
 					animationSpeed: 10,
@@ -0,0 +1,311 @@
+// This file contains the HTTP API of the game server for checking on a headless server and administering it:
+// its status, the connected clients, and actions to kick users, change the level, start or end the match
+// and broadcast messages. Every route requires the admin token of the server, and none is served without one.
+package multiplayer
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// adminPath is the path the routes of the admin API are grouped under.
+const adminPath = "/admin"
+
+// errNoMatch is returned when a match is ended while none is running.
+var errNoMatch = errors.New("no match is running")
+
+// AdminStatus represents the status of the server returned by the admin API.
+type AdminStatus struct {
+	GameState  string        // The state of the game.
+	Level      string        // The path to the level file the matches are played on.
+	LevelHash  string        // The content hash of the level.
+	Tick       uint64        // The tick of the running match.
+	MaxPlayers int           // The number of players the server accepts.
+	Spectators int           // The number of connected spectators.
+	Players    []AdminPlayer // The players of the game.
+}
+
+// AdminPlayer represents a player in the status of the server.
+type AdminPlayer struct {
+	Player     int    // The index of the player in the game information.
+	Username   string // The username of the player.
+	Ready      bool   // Whether the player is ready to start the match.
+	IsDead     bool   // Whether the player is dead.
+	Connected  bool   // Whether the client of the player is connected, rather than away within the grace period.
+	PingMillis int64  // The round-trip time of the heartbeats to the client in milliseconds, or 0 before it is measured.
+}
+
+// AdminClient represents a client connected to the server in the admin API.
+type AdminClient struct {
+	UserID     string // The identifier of the session of the user.
+	Username   string // The username of the user.
+	IP         string // The address the client connected from.
+	Player     int    // The index of the player of the client, or -1 for a spectator.
+	Spectator  bool   // Whether the client watches the match without a player.
+	Connected  bool   // Whether the client is connected, rather than away within the grace period.
+	PingMillis int64  // The round-trip time of the heartbeats to the client in milliseconds, or 0 before it is measured.
+}
+
+// adminKickRequest represents the body of a request kicking a user.
+type adminKickRequest struct {
+	UserID string // The identifier of the session of the user.
+}
+
+// adminLevelRequest represents the body of a request changing the level.
+type adminLevelRequest struct {
+	Level string // The name of the level file in the level directory of the server.
+}
+
+// adminBroadcastRequest represents the body of a request broadcasting a message.
+type adminBroadcastRequest struct {
+	Text string // The text of the message.
+}
+
+// registerAdminRoutes adds the routes of the admin API to the server if it has an admin token.
+func (s *GameServer) registerAdminRoutes() {
+	if s.config.AdminToken == "" {
+		return
+	}
+
+	admin := s.server.Group(adminPath, adminAuth(s.config.AdminToken))
+	admin.GET("/status", func(c *gin.Context) {
+		c.JSON(http.StatusOK, s.Status())
+	})
+	admin.GET("/clients", func(c *gin.Context) {
+		c.JSON(http.StatusOK, s.Clients())
+	})
+	admin.POST("/kick", func(c *gin.Context) {
+		var request adminKickRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			adminError(c, http.StatusBadRequest, err)
+
+			return
+		}
+		if err := s.Kick(request.UserID); err != nil {
+			adminError(c, http.StatusNotFound, err)
+
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	admin.POST("/level", func(c *gin.Context) {
+		var request adminLevelRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			adminError(c, http.StatusBadRequest, err)
+
+			return
+		}
+		if err := s.ChangeLevel(request.Level); err != nil {
+			adminError(c, http.StatusConflict, err)
+
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	admin.POST("/match/start", func(c *gin.Context) {
+		if err := s.StartMatch(); err != nil {
+			adminError(c, http.StatusConflict, err)
+
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	admin.POST("/match/end", func(c *gin.Context) {
+		if err := s.EndMatch(); err != nil {
+			adminError(c, http.StatusConflict, err)
+
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	admin.POST("/broadcast", func(c *gin.Context) {
+		var request adminBroadcastRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			adminError(c, http.StatusBadRequest, err)
+
+			return
+		}
+		if err := s.Broadcast(request.Text); err != nil {
+			adminError(c, http.StatusBadRequest, err)
+
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+}
+
+// adminAuth returns a middleware refusing the requests that do not carry the admin token
+// in their Authorization header as a bearer token.
+//
+// Parameters:
+//   - token: The admin token of the server.
+//
+// Returns:
+//   - gin.HandlerFunc: A Gin handler function checking the token.
+func adminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		given, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			log.Println("Refusing admin request from", c.Request.RemoteAddr)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid admin token"})
+
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// adminError answers an admin request with an error.
+func adminError(c *gin.Context, status int, err error) {
+	c.JSON(status, gin.H{"error": err.Error()})
+}
+
+// Status returns the status of the server: the state of the game, its level and its players.
+func (s *GameServer) Status() AdminStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := AdminStatus{
+		GameState:  s.info.GameState,
+		Level:      s.config.Level,
+		LevelHash:  s.info.LevelHash,
+		Tick:       s.info.Tick,
+		MaxPlayers: s.config.MaxPlayers,
+		Spectators: len(s.spectators),
+		Players:    make([]AdminPlayer, 0, len(s.clients)),
+	}
+	for client := range s.clients {
+		player := s.info.Players[client.player]
+		status.Players = append(status.Players, AdminPlayer{
+			Player:     client.player,
+			Username:   player.Username,
+			Ready:      player.Ready,
+			IsDead:     player.IsDead,
+			Connected:  client.connection != nil,
+			PingMillis: client.ping.Milliseconds(),
+		})
+	}
+	slices.SortFunc(status.Players, func(a, b AdminPlayer) int { return a.Player - b.Player })
+
+	return status
+}
+
+// Clients returns the clients connected to the server, the players first and then the spectators.
+func (s *GameServer) Clients() []AdminClient {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clients := make([]AdminClient, 0, len(s.clients)+len(s.spectators))
+	for client := range s.recipients() {
+		clients = append(clients, AdminClient{
+			UserID:     client.UserID,
+			Username:   client.Username,
+			IP:         client.IP,
+			Player:     client.player,
+			Spectator:  client.spectator,
+			Connected:  client.connection != nil,
+			PingMillis: client.ping.Milliseconds(),
+		})
+	}
+	slices.SortFunc(clients, func(a, b AdminClient) int {
+		if a.Spectator != b.Spectator {
+			if a.Spectator {
+				return 1
+			}
+
+			return -1
+		}
+		if a.Player != b.Player {
+			return a.Player - b.Player
+		}
+
+		return strings.Compare(a.UserID, b.UserID)
+	})
+
+	return clients
+}
+
+// ChangeLevel changes the level the next match is played on. The players have to get ready again.
+// Only the levels in the level directory of the server can be chosen, as the level is served to every client.
+//
+// Parameters:
+//   - name: The name of the level file in the level directory, with or without its extension.
+//
+// Returns:
+//   - error: An error if a match is running, the name is not in the level directory or the level cannot be loaded.
+func (s *GameServer) ChangeLevel(name string) error {
+	path, err := resolveLevel(s.config.LevelDir, name)
+	if err != nil {
+		return err
+	}
+	level, err := readLevel(path)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.info.GameState != GameStateLobby {
+		return fmt.Errorf("the level can only be changed in the lobby")
+	}
+
+	s.config.Level = path
+	s.level = level
+	s.info.Level = path
+	s.info.LevelHash = LevelHash(level)
+	for i := range s.info.Players {
+		s.info.Players[i].Ready = false
+	}
+	s.postChat("", "The level was changed to "+name, true)
+
+	return nil
+}
+
+// EndMatch ends the running match.
+//
+// Returns:
+//   - error: An error if no match is running.
+func (s *GameServer) EndMatch() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.info.GameState != GameStateRunning {
+		return errNoMatch
+	}
+
+	s.info.GameState = GameStateEnd
+	s.metrics.matchesPlayed.Add(1)
+	s.postChat("", "The match was ended by the server", true)
+
+	return nil
+}
+
+// Broadcast sends a message from the server to every client in the chat.
+//
+// Parameters:
+//   - text: The text of the message, cut to MaxChatLength characters.
+//
+// Returns:
+//   - error: An error if the message is empty.
+func (s *GameServer) Broadcast(text string) error {
+	text = sanitizeText(text, MaxChatLength)
+	if text == "" {
+		return fmt.Errorf("empty message")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.postChat("", "Server: "+text, true)
+
+	return nil
+}
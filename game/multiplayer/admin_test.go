@@ -0,0 +1,73 @@
+package multiplayer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/admin/status", adminAuth("secret"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		expected      int
+	}{
+		{"Valid token", "Bearer secret", http.StatusOK},
+		{"Wrong token", "Bearer guess", http.StatusUnauthorized},
+		{"Missing scheme", "secret", http.StatusUnauthorized},
+		{"No header", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/admin/status", nil)
+			if tt.authorization != "" {
+				request.Header.Set("Authorization", tt.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			if recorder.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, recorder.Code)
+			}
+		})
+	}
+}
+
+func TestGameServer_ChangeLevel(t *testing.T) {
+	s := newTestServer(t, nil)
+	level, err := os.ReadFile(s.config.Level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(s.config.LevelDir, "other.txt"), append(level, "BOX 3 3\n"...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	hash := s.info.LevelHash
+
+	for _, name := range []string{"/etc/passwd", "../level.txt", "missing"} {
+		if err := s.ChangeLevel(name); err == nil {
+			t.Errorf("Expected the level %q to be refused", name)
+		}
+	}
+	if s.info.LevelHash != hash {
+		t.Fatal("Expected a refused level to keep the level of the server")
+	}
+
+	if err := s.ChangeLevel("other"); err != nil {
+		t.Fatal(err)
+	}
+	if s.info.LevelHash == hash || s.config.Level != filepath.Join(s.config.LevelDir, "other.txt") {
+		t.Errorf("Expected the level to change to other.txt, got %s", s.config.Level)
+	}
+}
@@ -0,0 +1,138 @@
+// This file contains the chat of the game server: the messages of the players,
+// their length and rate limits, and the system messages announcing what happens in the match.
+package multiplayer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/sim"
+)
+
+// MaxChatLength is the number of characters a chat message is cut to.
+const MaxChatLength = 200
+
+// MaxUsernameLength is the number of characters a username is cut to.
+const MaxUsernameLength = 20
+
+// chatBurst is the number of chat messages a player can send at once before being rate limited.
+const chatBurst = 5
+
+// chatRefill is how long it takes for a rate limited player to be allowed another chat message.
+const chatRefill = time.Second
+
+// chatLimiter limits the rate of the chat messages of a client, allowing bursts of chatBurst messages
+// and one more message every chatRefill after that.
+type chatLimiter struct {
+	tokens float64   // The number of messages the client can send right now.
+	last   time.Time // The time the tokens were last refilled, or zero before the first message.
+}
+
+// allow returns whether a message sent at the given time is within the limit, using up a token if it is.
+func (l *chatLimiter) allow(now time.Time) bool {
+	if l.last.IsZero() {
+		l.tokens = chatBurst
+	} else {
+		l.tokens = min(chatBurst, l.tokens+float64(now.Sub(l.last))/float64(chatRefill))
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+
+	return true
+}
+
+// sanitizeText removes the control characters and the surrounding spaces of a text and cuts it to the given number of characters.
+func sanitizeText(text string, length int) string {
+	text = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+
+		return r
+	}, text))
+
+	if runes := []rune(text); len(runes) > length {
+		text = strings.TrimSpace(string(runes[:length]))
+	}
+
+	return text
+}
+
+// chat posts a chat message of a client, unless it is empty or the client sends messages too fast.
+func (s *GameServer) chat(client *serverClient, text string) {
+	text = sanitizeText(text, MaxChatLength)
+	if text == "" {
+		return
+	}
+	if !client.chat.allow(time.Now()) {
+		return
+	}
+
+	from := client.Username
+	switch {
+	case !client.spectator:
+		from = s.nameOf(client.player)
+	case from == "":
+		from = "Spectator"
+	default:
+		from += " (spectator)"
+	}
+
+	s.postChat(from, text, false)
+}
+
+// postChat adds a message to the chat shared with the clients, dropping the oldest message once the chat is full.
+//
+// Parameters:
+//   - from: The name of the player sending the message, or empty for a system message.
+//   - text: The text of the message.
+//   - system: Whether the message is sent by the server.
+func (s *GameServer) postChat(from string, text string, system bool) {
+	s.lastChatID++
+	s.info.Chat = append(s.info.Chat, ProtoChatMessage{ID: s.lastChatID, From: from, Text: text, System: system})
+	if len(s.info.Chat) > chatHistory {
+		s.info.Chat = s.info.Chat[len(s.info.Chat)-chatHistory:]
+	}
+}
+
+// nameOf returns the name of the player with the given index shown in the chat.
+func (s *GameServer) nameOf(player int) string {
+	if username := s.info.Players[player].Username; username != "" {
+		return username
+	}
+
+	return fmt.Sprintf("Player %d", player+1)
+}
+
+// deathMessage returns the system message announcing the death of a player in the match.
+//
+// Parameters:
+//   - player: The player that died.
+//
+// Returns:
+//   - string: The text of the message.
+func (s *GameServer) deathMessage(player *sim.Player) string {
+	name := s.nameOf(int(player.ID))
+
+	switch player.DeathCause {
+	case sim.DeathExplosion:
+		if player.Killer == nil || int(player.Killer.ID) >= len(s.info.Players) {
+			return name + " was blown up"
+		}
+		if player.Killer == player {
+			return name + " blew themselves up"
+		}
+
+		return name + " was blown up by " + s.nameOf(int(player.Killer.ID))
+	case sim.DeathMonster:
+		return name + " was caught by a monster"
+	default:
+		return name + " died"
+	}
+}
@@ -0,0 +1,85 @@
+package multiplayer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/sim"
+)
+
+func TestChatLimiter(t *testing.T) {
+	var limiter chatLimiter
+	start := time.Now()
+
+	for i := range chatBurst {
+		if !limiter.allow(start) {
+			t.Errorf("Expected message %d of the burst to be allowed", i+1)
+		}
+	}
+	if limiter.allow(start) {
+		t.Error("Expected the message after the burst to be rate limited")
+	}
+	if !limiter.allow(start.Add(chatRefill)) {
+		t.Error("Expected a message to be allowed after the refill")
+	}
+	if limiter.allow(start.Add(chatRefill)) {
+		t.Error("Expected a single message to be allowed after the refill")
+	}
+}
+
+func TestSanitizeText(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		length   int
+		expected string
+	}{
+		{"unchanged", "hello", 10, "hello"},
+		{"trimmed", "  hello \n", 10, "hello"},
+		{"control characters", "hel\x00lo\r\n there", 20, "hello there"},
+		{"cut", "hello there", 5, "hello"},
+		{"cut by characters", strings.Repeat("é", 8), 4, "éééé"},
+		{"blank", " \t ", 10, ""},
+	}
+
+	for _, test := range tests {
+		if got := sanitizeText(test.text, test.length); got != test.expected {
+			t.Errorf("%s: Expected %q, got %q", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestDeathMessage(t *testing.T) {
+	s := &GameServer{info: ProtoGameInfo{Players: []ProtoPlayer{{Username: "kai"}, {Username: "jay"}, {}}}}
+	jay := &sim.Player{ID: 1, DeathCause: sim.DeathExplosion}
+	jay.Killer = jay
+
+	tests := []struct {
+		name     string
+		player   *sim.Player
+		expected string
+	}{
+		{"blown up by another player", &sim.Player{ID: 0, DeathCause: sim.DeathExplosion, Killer: jay}, "kai was blown up by jay"},
+		{"own bomb", jay, "jay blew themselves up"},
+		{"monster", &sim.Player{ID: 2, DeathCause: sim.DeathMonster}, "Player 3 was caught by a monster"},
+		{"no cause", &sim.Player{ID: 0}, "kai died"},
+	}
+
+	for _, test := range tests {
+		if got := s.deathMessage(test.player); got != test.expected {
+			t.Errorf("%s: Expected %q, got %q", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestPostChat_KeepsHistory(t *testing.T) {
+	s := &GameServer{}
+	for range chatHistory + 1 {
+		s.postChat("", "hello", true)
+	}
+
+	if len(s.info.Chat) != chatHistory || s.info.Chat[0].ID != 2 || s.info.Chat[chatHistory-1].ID != chatHistory+1 {
+		t.Errorf("Expected the messages 2 to %d, got %d messages starting with %d", chatHistory+1, len(s.info.Chat), s.info.Chat[0].ID)
+	}
+}
@@ -1,101 +1,699 @@
 package multiplayer
 
 import (
+	"cmp"
+	"errors"
+	"fmt"
+	"image/color"
 	"log"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/entities"
 	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/userinfo"
 )
 
 // GameClient represents a client connected to the game server.
+// The received game state is read by the game loop while the connection is handled in the background,
+// so the state is only accessed through its methods. A dropped connection is reestablished
+// with an increasing backoff, resuming the session of the player for as long as the server keeps it.
 type GameClient struct {
-	conn     *websocket.Conn // The connection to the game server.
-	GameInfo ProtoGameInfo   // The game state information received from the server.
-	Ping     time.Duration   // The ping of the client.
-	Player   *ProtoPlayer    // The player information for the client.
+	address   string                            // The address of the game server.
+	root      string                            // The path the game server serves the room of the client under.
+	secure    bool                              // Whether the connections to the game server use TLS.
+	onClose   func(code int, text string) error // Called once the server closes the connection or it cannot be reestablished, if not nil.
+	mu        sync.Mutex                        // The lock guarding the fields below up to the username.
+	conn      *websocket.Conn                   // The current connection to the game server.
+	session   string                            // The identifier of the session on the server, used to resume it.
+	features  []string                          // The features the server announced in its welcome.
+	grace     time.Duration                     // How long the server keeps the session after the connection dropped.
+	heartbeat time.Duration                     // How often the client pings the server.
+	timeout   time.Duration                     // How long the connection may stay silent before the client drops it.
+	stats     connectionStats                   // The measured quality of the connection.
+	reason    string                            // The reason the connection ended for, or empty while it is open.
+	player    int                               // The index of the player of the client in the game information.
+	gameInfo  ProtoGameInfo                     // The game state information received from the server.
+	history   snapshotRing                      // The recent snapshots the deltas received from the server are based on.
+	acked     uint64                            // The sequence number of the latest snapshot received.
+	timeline  []ProtoGameInfo                   // The game state information of the latest ticks, to interpolate between.
+	clock     serverClock                       // The estimated clock of the server.
+	delay     time.Duration                     // How far behind the estimated clock of the server the remote entities are rendered.
+	username  string                            // The username of the player sent in the handshake.
+	password  string                            // The password or invite code of a private server.
+	spectator bool                              // Whether the client watches the match without a player.
+	inputSeq  atomic.Uint64                     // The sequence number of the last input of the player.
+	controls  chan ProtoPlayer                  // The inputs waiting to be sent to the server.
+	lobby     chan ProtoLobbyRequest            // The lobby requests waiting to be sent to the server.
+	chat      chan string                       // The chat messages waiting to be sent to the server.
+	ackReady  chan struct{}                     // Signals that a new snapshot is waiting to be acknowledged.
+	done      chan struct{}                     // The channel closed when the client is closed.
+	closed    sync.Once                         // Ensures the client is only closed once.
+	levelMu   sync.Mutex                        // The lock guarding the levels, held while a level is downloaded.
+	levels    map[string][]byte                 // The levels loaded by the client, by their content hash.
 }
 
+// lobbyQueueSize is the number of lobby requests queued before new ones are dropped.
+const lobbyQueueSize = 4
+
+// reconnectBackoff is the delay before the first attempt to reestablish a dropped connection,
+// doubled after every failed attempt up to maxReconnectBackoff.
+const reconnectBackoff = 250 * time.Millisecond
+
+// maxReconnectBackoff is the longest delay between two attempts to reestablish a dropped connection.
+const maxReconnectBackoff = 4 * time.Second
+
 // NewGameClient creates a new GameClient and connects to the game server at the specified address.
-func NewGameClient(Address string, UserInfo *userinfo.UserInfo, CloseHandler func(code int, text string) error) *GameClient {
-	gc := &GameClient{}
-	conn, _, err := websocket.DefaultDialer.Dial("ws://"+Address+"/", nil)
+// A room of a server hosting several rooms is joined by appending its identifier to the address, as in host:port/room,
+// and a server accepting secure connections by prefixing the address with wss://.
+//
+// Parameters:
+//   - Address: The address of the game server, optionally followed by the identifier of a room.
+//   - UserInfo: The user information of the player, updated with the identifier given by the server.
+//   - Password: The password or invite code of a private server, or empty.
+//   - CloseHandler: Called once the server closes the connection or it cannot be reestablished, or nil.
+//     It is called from the goroutine of the connection, the game loop checks Disconnected instead.
+//
+// Returns:
+//   - *GameClient: The connected client.
+//   - error: An error if the server cannot be reached or refuses the client, with a reason readable by the player.
+func NewGameClient(Address string, UserInfo *userinfo.UserInfo, Password string, CloseHandler func(code int, text string) error) (*GameClient, error) {
+	return newGameClient(Address, UserInfo, Password, CloseHandler, false)
+}
+
+// NewSpectatorClient creates a new GameClient and connects to the game server at the specified address
+// as a spectator, which receives the state of the match without taking a player.
+//
+// Parameters:
+//   - Address: The address of the game server, optionally followed by the identifier of a room.
+//   - UserInfo: The user information of the spectator, updated with the identifier given by the server.
+//   - Password: The password or invite code of a private server, or empty.
+//   - CloseHandler: Called once the server closes the connection or it cannot be reestablished, or nil.
+//     It is called from the goroutine of the connection, the game loop checks Disconnected instead.
+//
+// Returns:
+//   - *GameClient: The connected client.
+//   - error: An error if the server cannot be reached or refuses the client, with a reason readable by the player.
+func NewSpectatorClient(Address string, UserInfo *userinfo.UserInfo, Password string, CloseHandler func(code int, text string) error) (*GameClient, error) {
+	return newGameClient(Address, UserInfo, Password, CloseHandler, true)
+}
+
+// newGameClient creates a new GameClient connected to the game server as a player or as a spectator.
+func newGameClient(Address string, UserInfo *userinfo.UserInfo, Password string, CloseHandler func(code int, text string) error, spectator bool) (*GameClient, error) {
+	address, secure := splitScheme(Address)
+	address, root := splitRoomAddress(address)
+	gc := &GameClient{
+		address:   address,
+		root:      root,
+		secure:    secure,
+		onClose:   CloseHandler,
+		username:  UserInfo.Username,
+		password:  Password,
+		spectator: spectator,
+		controls:  make(chan ProtoPlayer, maxQueuedInputs),
+		lobby:     make(chan ProtoLobbyRequest, lobbyQueueSize),
+		chat:      make(chan string, chatBurst),
+		ackReady:  make(chan struct{}, 1),
+		done:      make(chan struct{}),
+		levels:    make(map[string][]byte),
+	}
+
+	conn, codec, welcome, err := gc.dial("")
 	if err != nil {
 		log.Println("Failed to connect to server:", err)
 
-		return nil
+		return nil, err
 	}
+	if spectator && !slices.Contains(welcome.Features, FeatureSpectators) {
+		closeConnection(conn, websocket.CloseNormalClosure, "")
 
-	gc.conn = conn
+		return nil, errors.New("the server does not accept spectators")
+	}
+	UserInfo.UserID = welcome.UserID
+	gc.welcome(conn, welcome)
+	// the snapshots are stamped with the ticks of the world, which advances at the same rate on every server,
+	// but a server sending fewer snapshots needs a longer delay to have one to interpolate towards
+	gc.clock.tickDuration = time.Second / entities.TicksPerSecond
+	gc.delay = max(InterpolationDelay, 2*time.Second/time.Duration(max(welcome.TickRate, 1)))
 
-	var userID userinfo.UserInfo
-	err = gc.conn.ReadJSON(&userID)
-	if err != nil {
-		log.Println("Failed to Get userid:", err)
+	go gc.run(conn, codec)
+
+	// the level is usually fetched while the players get ready in the lobby
+	if welcome.LevelHash != "" {
+		go func() {
+			if _, err := gc.Level(welcome.LevelHash); err != nil {
+				log.Println("Failed to fetch level:", err)
+			}
+		}()
+	}
+
+	return gc, nil
+}
+
+// dial opens a connection to the game server, introduces the client with its hello and reads the welcome of the server.
+//
+// Parameters:
+//   - session: The identifier of the session to resume, or an empty string to start a new one.
+//
+// Returns:
+//   - *websocket.Conn: The connection to the game server.
+//   - codec: The encoding negotiated with the game server.
+//   - ProtoWelcome: The welcome sent by the server.
+//   - error: An error if the server cannot be reached or refuses the connection, with the reason of the server if it gave one.
+func (gc *GameClient) dial(session string) (*websocket.Conn, codec, ProtoWelcome, error) {
+	dialer := *websocket.DefaultDialer
+	dialer.Subprotocols = clientSubprotocols()
+	dialer.HandshakeTimeout = DefaultHeartbeatTimeout
+	scheme := "ws"
+	if gc.secure {
+		scheme = "wss"
+		dialer.TLSClientConfig = clientTLSConfig(gc.address)
+	}
 
-		return nil
+	query := url.Values{usernameParameter: {gc.username}}
+	if gc.spectator {
+		query.Set(roleParameter, RoleSpectator)
 	}
-	UserInfo.UserID = userID.UserID
-	gc.Player = &ProtoPlayer{
-		Username: UserInfo.Username,
+	if session != "" {
+		query.Set(sessionParameter, session)
 	}
+	target := url.URL{Scheme: scheme, Host: gc.address, Path: gc.root, RawQuery: query.Encode()}
 
-	gc.conn.SetCloseHandler(CloseHandler)
+	conn, _, err := dialer.Dial(target.String(), nil)
+	if err != nil {
+		return nil, nil, ProtoWelcome{}, err
+	}
 
-	go gc.handleWebsocketConnection()
+	if err := conn.WriteJSON(newHello(gc.password)); err != nil {
+		conn.Close()
 
-	return gc
+		return nil, nil, ProtoWelcome{}, err
+	}
+
+	var welcome ProtoWelcome
+	conn.SetReadDeadline(time.Now().Add(DefaultHeartbeatTimeout))
+	if err := conn.ReadJSON(&welcome); err != nil {
+		conn.Close()
+		if closeErr, ok := serverClosed(err); ok {
+			return nil, nil, ProtoWelcome{}, &RefusedError{CloseError: closeErr}
+		}
+
+		return nil, nil, ProtoWelcome{}, err
+	}
+	if err := checkVersion(welcome.Version, ProtocolVersion); err != nil {
+		closeConnection(conn, CloseVersionMismatch, err.Error())
+
+		return nil, nil, ProtoWelcome{}, err
+	}
+
+	return conn, codecFor(conn.Subprotocol()), welcome, nil
 }
 
-// handleWebsocketConnection handles the websocket communication with the game server.
-func (gc *GameClient) handleWebsocketConnection() {
-	if gc.conn == nil {
-		log.Panic("Connection is nil")
+// welcome takes over a new connection described by the welcome of the server.
+// A connection that does not resume the previous session starts from a full snapshot.
+func (gc *GameClient) welcome(conn *websocket.Conn, welcome ProtoWelcome) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	// a client closed while reconnecting drops the new connection right away
+	if gc.isClosed() {
+		conn.Close()
+
+		return
 	}
+
+	gc.conn = conn
+	gc.session = welcome.UserID
+	gc.grace = welcome.ResumeGrace
+	gc.features = welcome.Features
+	gc.player = welcome.Player
+	gc.heartbeat = cmp.Or(welcome.Heartbeat, DefaultHeartbeatInterval)
+	gc.timeout = cmp.Or(welcome.Timeout, DefaultHeartbeatTimeout)
+	gc.stats = connectionStats{}
+
+	// the server has no snapshots acknowledged through the previous connection, so the next one is complete
+	gc.history = snapshotRing{}
+	gc.acked = 0
+}
+
+// run handles the connections to the game server until the client is closed, the server closes the connection
+// or a dropped connection cannot be reestablished while the server keeps the session.
+//
+// Parameters:
+//   - conn: The first connection to the game server.
+//   - codec: The encoding negotiated for the connection.
+func (gc *GameClient) run(conn *websocket.Conn, codec codec) {
+	defer gc.Close()
+
 	for {
-		comStart := time.Now()
+		gc.mu.Lock()
+		heartbeat, timeout := gc.heartbeat, gc.timeout
+		gc.mu.Unlock()
 
-		err := gc.conn.WriteJSON(*(gc.Player))
-		if err != nil {
-			log.Println("Failed to send message to server:", err)
-			// If we can't write, the connection is likely closed
-			if websocket.IsCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Println("Connection closed, stopping websocket handler")
+		keepAlive(conn, timeout, gc.observeRTT)
+		stop := make(chan struct{})
+		go gc.writeLoop(conn, codec, heartbeat, timeout, stop)
+		err := gc.readLoop(conn, codec, timeout)
+		close(stop)
+		conn.Close()
+
+		if closeErr, ok := serverClosed(err); ok {
+			log.Println("Server closed the connection:", closeErr)
+			gc.disconnect(closeErr.Code, closeErr.Text)
+
+			return
+		}
+		if gc.isClosed() {
+			return
+		}
+
+		if isTimeout(err) {
+			log.Println("Connection to server timed out")
+		} else {
+			log.Println("Connection to server lost:", err)
+		}
+		if conn, codec, err = gc.reconnect(); conn == nil {
+			if gc.isClosed() {
 				return
 			}
+			if closeErr, ok := serverClosed(err); ok {
+				gc.disconnect(closeErr.Code, closeErr.Text)
+			} else {
+				gc.disconnect(websocket.CloseAbnormalClosure, "")
+			}
+
+			return
+		}
+	}
+}
+
+// reconnect tries to reestablish a dropped connection with an increasing backoff, until the server
+// would have dropped the session of the player.
+//
+// Returns:
+//   - *websocket.Conn: The new connection to the game server, or nil if it cannot be reestablished.
+//   - codec: The encoding negotiated for the new connection.
+//   - error: The refusal of the server, or the error of the last attempt if the connection cannot be reestablished.
+func (gc *GameClient) reconnect() (*websocket.Conn, codec, error) {
+	gc.mu.Lock()
+	session, grace := gc.session, gc.grace
+	gc.mu.Unlock()
+
+	deadline := time.Now().Add(grace)
+	backoff := reconnectBackoff
+	var lastErr error
+	for time.Now().Before(deadline) {
+		select {
+		case <-time.After(backoff):
+		case <-gc.done:
+			return nil, nil, nil
+		}
+		backoff = min(2*backoff, maxReconnectBackoff)
+
+		conn, codec, welcome, err := gc.dial(session)
+		if err != nil {
+			if closeErr, ok := serverClosed(err); ok {
+				// the server is reachable again, but refuses the client
+				log.Println("Server refused to resume the session:", closeErr)
+
+				return nil, nil, err
+			}
+			log.Println("Failed to reconnect to server:", err)
+			lastErr = err
+
 			continue
 		}
 
-		err = gc.conn.ReadJSON(&gc.GameInfo)
+		if welcome.UserID != session {
+			log.Println("Session expired, joined as a new player")
+		} else {
+			log.Println("Session resumed")
+		}
+		gc.welcome(conn, welcome)
+
+		return conn, codec, nil
+	}
+
+	return nil, nil, lastErr
+}
+
+// disconnect records the reason the connection to the server ended for and calls the close handler.
+func (gc *GameClient) disconnect(code int, text string) {
+	gc.mu.Lock()
+	gc.reason = CloseReason(code, text)
+	gc.mu.Unlock()
+
+	if gc.onClose != nil {
+		gc.onClose(code, text)
+	}
+}
+
+// Disconnected returns why the server ended the connection, once it closed it or it could not be reestablished.
+// A client closed by the player is not disconnected.
+//
+// Returns:
+//   - string: The reason the connection ended for, readable by the player.
+//   - bool: Whether the client was disconnected.
+func (gc *GameClient) Disconnected() (string, bool) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	return gc.reason, gc.reason != ""
+}
+
+// RefusedError is returned when the server refuses a new connection with a close message.
+type RefusedError struct {
+	*websocket.CloseError // The close message of the server.
+}
+
+// Error returns the reason the server gave for refusing the connection.
+func (e *RefusedError) Error() string {
+	return CloseReason(e.Code, e.Text)
+}
+
+// Unwrap returns the close message of the server.
+func (e *RefusedError) Unwrap() error {
+	return e.CloseError
+}
+
+// splitRoomAddress splits an address joined by a client into the address of the game server
+// and the path of the room on the server.
+//
+// Parameters:
+//   - address: The address of the game server, optionally followed by a slash and the identifier of a room.
+//
+// Returns:
+//   - string: The address of the game server.
+//   - string: The path the room is served under, or "/" for a server hosting a single match.
+func splitRoomAddress(address string) (string, string) {
+	host, room, _ := strings.Cut(address, "/")
+	if room = strings.Trim(room, "/"); room == "" {
+		return host, "/"
+	}
+
+	return host, roomsPath + room + "/"
+}
+
+// serverClosed returns the close message of the server if it ended the connection on purpose.
+// A connection dropped without a close message is reported with the abnormal closure code instead.
+func serverClosed(err error) (*websocket.CloseError, bool) {
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code == websocket.CloseAbnormalClosure {
+		return nil, false
+	}
+
+	return closeErr, true
+}
+
+// isClosed returns whether the client has been closed.
+func (gc *GameClient) isClosed() bool {
+	select {
+	case <-gc.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// GameInfo returns the latest game state information received from the server.
+func (gc *GameClient) GameInfo() ProtoGameInfo {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	return gc.gameInfo
+}
+
+// InterpolatedGameInfo returns the latest game state information received from the server, with the remote players
+// and the monsters placed at least InterpolationDelay behind it, so they move smoothly even if the snapshots arrive unevenly.
+// The player of the client is left at its latest position.
+//
+// Parameters:
+//   - now: The time the game state is rendered at.
+//
+// Returns:
+//   - ProtoGameInfo: The game state information to render.
+func (gc *GameClient) InterpolatedGameInfo(now time.Time) ProtoGameInfo {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	if len(gc.timeline) == 0 {
+		return gc.gameInfo
+	}
+
+	tick := gc.clock.tickAt(now.Add(-gc.delay))
+	extrapolation := float64(maxExtrapolation) / float64(gc.clock.tickDuration)
+
+	return interpolateGameInfo(gc.timeline, tick, extrapolation, gc.player)
+}
+
+// Player returns the index of the player of the client in the game information.
+func (gc *GameClient) Player() int {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	return gc.player
+}
+
+// Spectator returns whether the client watches the match without a player.
+func (gc *GameClient) Spectator() bool {
+	return gc.spectator
+}
+
+// SendControls queues the controls of the player for the next tick of the server.
+// If the connection cannot keep up, the controls are dropped.
+//
+// Parameters:
+//   - controls: The controls pressed by the player.
+//
+// Returns:
+//   - uint64: The sequence number of the input, reported back in the snapshots once the server simulated it.
+func (gc *GameClient) SendControls(controls entities.PlayerControls) uint64 {
+	seq := gc.inputSeq.Add(1)
+
+	select {
+	// the username was sent in the handshake, so the inputs only carry the controls
+	case gc.controls <- ProtoPlayer{Control: controls, InputSeq: seq}:
+	default:
+	}
+
+	return seq
+}
+
+// SendLobby asks the server to update the lobby state of the player.
+// If the connection cannot keep up, the request is dropped.
+//
+// Parameters:
+//   - ready: Whether the player is ready to start the match.
+//   - color: The color the player wants, granted if no other player has it.
+func (gc *GameClient) SendLobby(ready bool, color color.RGBA) {
+	select {
+	case gc.lobby <- ProtoLobbyRequest{Ready: ready, Color: color}:
+	default:
+		log.Println("Dropping lobby request")
+	}
+}
+
+// SendChat sends a chat message to the other players.
+// If the connection cannot keep up, the message is dropped.
+//
+// Parameters:
+//   - text: The text of the message, cut to MaxChatLength characters by the server.
+func (gc *GameClient) SendChat(text string) {
+	select {
+	case gc.chat <- text:
+	default:
+		log.Println("Dropping chat message")
+	}
+}
+
+// readLoop receives the snapshots of the game state from the server until the connection fails
+// or stays silent for the heartbeat timeout.
+//
+// Parameters:
+//   - conn: The connection to the game server.
+//   - codec: The encoding negotiated for the connection.
+//   - timeout: How long the connection may stay silent.
+//
+// Returns:
+//   - error: The error ending the connection.
+func (gc *GameClient) readLoop(conn *websocket.Conn, codec codec, timeout time.Duration) error {
+	for {
+		_, data, err := conn.ReadMessage()
 		if err != nil {
-			log.Println("Failed to read message from server:", err)
-			// If we can't read, the connection is likely closed
-			if websocket.IsCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Println("Connection closed, stopping websocket handler")
+			return err
+		}
+		conn.SetReadDeadline(time.Now().Add(timeout))
+
+		var snapshot ProtoSnapshot
+		if err := codec.Unmarshal(data, &snapshot); err != nil {
+			return fmt.Errorf("failed to decode message from server: %w", err)
+		}
+
+		if gc.applySnapshot(snapshot, time.Now()) {
+			select {
+			case gc.ackReady <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// applySnapshot reconstructs the game state information from a snapshot received from the server.
+// The slices handed out by GameInfo are never written again, as every snapshot creates new ones.
+//
+// Parameters:
+//   - snapshot: The snapshot received from the server.
+//   - received: The time the snapshot arrived.
+//
+// Returns:
+//   - bool: Whether the snapshot is newer than the current game state information.
+func (gc *GameClient) applySnapshot(snapshot ProtoSnapshot, received time.Time) bool {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	if snapshot.Seq <= gc.acked {
+		return false
+	}
+
+	var baseline ProtoGameInfo
+	if snapshot.Baseline != 0 {
+		var ok bool
+		if baseline, ok = gc.history.get(snapshot.Baseline); !ok {
+			// the server falls back to a full snapshot once the baseline we acknowledged gets too old
+			log.Println("Dropping snapshot with unknown baseline", snapshot.Baseline)
+
+			return false
+		}
+	}
+
+	gc.gameInfo = applySnapshot(baseline, snapshot)
+	gc.player = snapshot.Player
+	gc.acked = snapshot.Seq
+	gc.history.put(snapshot.Seq, gc.gameInfo)
+	gc.stats.observeSeq(snapshot.Seq)
+
+	// snapshots sent between ticks, like the ones in the lobby, only replace the latest one
+	if n := len(gc.timeline); n > 0 && gc.timeline[n-1].Tick >= gc.gameInfo.Tick {
+		gc.timeline[n-1] = gc.gameInfo
+	} else {
+		gc.timeline = append(gc.timeline, gc.gameInfo)
+		if gc.gameInfo.GameState == GameStateRunning {
+			gc.clock.observe(gc.gameInfo.Tick, received)
+			gc.stats.observeTick(gc.gameInfo.Tick, received, gc.clock.tickDuration)
+		}
+		if len(gc.timeline) > timelineLength {
+			gc.timeline = gc.timeline[1:]
+		}
+	}
+
+	return true
+}
+
+// observeRTT adds a round-trip time measured by a heartbeat to the quality of the connection.
+func (gc *GameClient) observeRTT(rtt time.Duration) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	gc.stats.observeRTT(rtt)
+}
+
+// ConnectionQuality returns the measured quality of the connection to the game server.
+// It starts over whenever the connection is reestablished.
+func (gc *GameClient) ConnectionQuality() ConnectionQuality {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	return gc.stats.quality()
+}
+
+// ack returns the sequence number of the latest snapshot received.
+func (gc *GameClient) ack() uint64 {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	return gc.acked
+}
+
+// writeLoop sends the queued controls of the player and the acknowledgements of the received snapshots
+// to the server until the connection is stopped. Every input acknowledges the latest snapshot,
+// so separate acknowledgements are only sent when no input was sent since the snapshot arrived.
+// The server is pinged every heartbeat interval.
+//
+// Parameters:
+//   - conn: The connection to the game server.
+//   - codec: The encoding negotiated for the connection.
+//   - heartbeat: How often the server is pinged.
+//   - timeout: How long writing a message may take.
+//   - stop: The channel closed when the connection is no longer used.
+func (gc *GameClient) writeLoop(conn *websocket.Conn, codec codec, heartbeat time.Duration, timeout time.Duration, stop <-chan struct{}) {
+	var sentAck uint64
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		var message ProtoClientMessage
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := sendPing(conn, timeout); err != nil {
+				log.Println("Failed to ping server:", err)
+				conn.Close()
+
 				return
 			}
+
 			continue
+		case input := <-gc.controls:
+			message = ProtoClientMessage{Ack: gc.ack(), Input: &input}
+		case request := <-gc.lobby:
+			message = ProtoClientMessage{Ack: gc.ack(), Lobby: &request}
+		case text := <-gc.chat:
+			message = ProtoClientMessage{Ack: gc.ack(), Chat: text}
+		case <-gc.ackReady:
+			if message.Ack = gc.ack(); message.Ack == sentAck {
+				continue
+			}
 		}
 
-		for _, player := range gc.GameInfo.Players {
-			if player.Username == gc.Player.Username {
-				gc.Player.Color = player.Color
-				break
-			}
+		data, err := codec.Marshal(message)
+		if err != nil {
+			log.Println("Failed to encode message:", err)
+
+			return
 		}
 
-		gc.Ping = time.Since(comStart)
+		// a failed write also fails the read loop, which reestablishes the connection
+		conn.SetWriteDeadline(time.Now().Add(timeout))
+		if err := conn.WriteMessage(codec.MessageType(), data); err != nil {
+			log.Println("Failed to send message to server:", err)
+			conn.Close()
 
-		// Add a small delay to prevent overwhelming the connection
-		time.Sleep(time.Millisecond * 16) // ~60 FPS
+			return
+		}
+		sentAck = message.Ack
 	}
 }
 
-// Close closes the connection to the game server.
+// Close closes the connection to the game server and stops reestablishing it.
 func (gc *GameClient) Close() {
-	if gc.conn == nil {
+	if gc == nil {
 		return
 	}
 
-	gc.conn.Close()
+	gc.closed.Do(func() {
+		close(gc.done)
+
+		gc.mu.Lock()
+		defer gc.mu.Unlock()
+		if gc.conn != nil {
+			gc.conn.Close()
+		}
+	})
 }
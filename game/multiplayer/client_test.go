@@ -0,0 +1,181 @@
+package multiplayer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/userinfo"
+)
+
+func TestGameClient_ResumesSession(t *testing.T) {
+	sessions := make(chan string, 2)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var hello ProtoHello
+		conn.ReadJSON(&hello)
+		sessions <- r.URL.Query().Get(sessionParameter)
+		conn.WriteJSON(ProtoWelcome{Version: ProtocolVersion, UserID: "session", Player: 1, TickRate: DefaultTickRate, ResumeGrace: time.Second})
+		if len(sessions) == 1 {
+			// drop the first connection without a close message
+			return
+		}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewGameClient(strings.TrimPrefix(server.URL, "http://"), &userinfo.UserInfo{}, "", func(code int, text string) error {
+		t.Errorf("Expected the connection to be reestablished, got closed with %d %s", code, text)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected the client to connect, got %v", err)
+	}
+	defer client.Close()
+
+	if session := <-sessions; session != "" {
+		t.Errorf("Expected a new session, got %q", session)
+	}
+	select {
+	case session := <-sessions:
+		if session != "session" {
+			t.Errorf("Expected the session to be resumed, got %q", session)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the client to reconnect")
+	}
+}
+
+func TestGameClient_DropsSilentConnection(t *testing.T) {
+	sessions := make(chan string, 2)
+	done := make(chan struct{})
+	defer close(done)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var hello ProtoHello
+		conn.ReadJSON(&hello)
+		sessions <- r.URL.Query().Get(sessionParameter)
+		conn.WriteJSON(ProtoWelcome{
+			Version:     ProtocolVersion,
+			UserID:      "session",
+			TickRate:    DefaultTickRate,
+			ResumeGrace: time.Second,
+			Heartbeat:   20 * time.Millisecond,
+			Timeout:     100 * time.Millisecond,
+		})
+
+		// neither read nor answer the pings, like a server that hangs
+		<-done
+	}))
+	defer server.Close()
+
+	client, err := NewGameClient(strings.TrimPrefix(server.URL, "http://"), &userinfo.UserInfo{}, "", func(code int, text string) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected the client to connect, got %v", err)
+	}
+	defer client.Close()
+
+	<-sessions
+	select {
+	case session := <-sessions:
+		if session != "session" {
+			t.Errorf("Expected the session to be resumed, got %q", session)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the client to drop the silent connection and reconnect")
+	}
+}
+
+func TestNewSpectatorClient(t *testing.T) {
+	queries := make(chan map[string][]string, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var hello ProtoHello
+		conn.ReadJSON(&hello)
+		queries <- r.URL.Query()
+		conn.WriteJSON(ProtoWelcome{Version: ProtocolVersion, Features: []string{FeatureSpectators}, UserID: "spectator", Player: -1, Spectator: true, TickRate: DefaultTickRate})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewSpectatorClient(strings.TrimPrefix(server.URL, "http://"), &userinfo.UserInfo{Username: "sensei"}, "", func(code int, text string) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected the client to connect, got %v", err)
+	}
+	defer client.Close()
+
+	query := <-queries
+	if role := query[roleParameter]; len(role) != 1 || role[0] != RoleSpectator {
+		t.Errorf("Expected to connect as a spectator, got role %v", role)
+	}
+	if username := query[usernameParameter]; len(username) != 1 || username[0] != "sensei" {
+		t.Errorf("Expected the username in the handshake, got %v", username)
+	}
+	if !client.Spectator() || client.Player() != -1 {
+		t.Errorf("Expected a spectator without a player, got player %d", client.Player())
+	}
+}
+
+func TestSplitRoomAddress(t *testing.T) {
+	tests := []struct {
+		address      string
+		expectedHost string
+		expectedRoot string
+	}{
+		{"localhost:8080", "localhost:8080", "/"},
+		{"localhost:8080/", "localhost:8080", "/"},
+		{"192.168.1.10:8080/finals", "192.168.1.10:8080", "/rooms/finals/"},
+		{"192.168.1.10:8080/finals/", "192.168.1.10:8080", "/rooms/finals/"},
+	}
+
+	for _, tt := range tests {
+		host, root := splitRoomAddress(tt.address)
+		if host != tt.expectedHost || root != tt.expectedRoot {
+			t.Errorf("Expected %s and %s for %s, got %s and %s", tt.expectedHost, tt.expectedRoot, tt.address, host, root)
+		}
+	}
+}
+
+func TestGameClient_FollowsPlayerIndex(t *testing.T) {
+	client := &GameClient{player: 2}
+
+	// the player before the one of the client left the lobby
+	client.applySnapshot(ProtoSnapshot{Seq: 1, GameState: GameStateLobby, Player: 1}, time.Now())
+
+	if player := client.Player(); player != 1 {
+		t.Errorf("Expected the player index of the snapshot, got %d", player)
+	}
+}
@@ -0,0 +1,535 @@
+// This file contains the wire encodings of the game protocol.
+package multiplayer
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/entities"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/sim"
+)
+
+// Subprotocols naming the encodings in the websocket handshake.
+const SubprotocolBinary = "ninjago.binary"
+const SubprotocolJSON = "ninjago.json"
+
+// PreferJSON makes the clients ask the server for the JSON encoding, which is easier to inspect while debugging.
+var PreferJSON = false
+
+// codec encodes and decodes the messages of the game protocol.
+type codec interface {
+	// Subprotocol returns the name of the encoding negotiated in the websocket handshake.
+	Subprotocol() string
+	// MessageType returns the websocket message type carrying the encoded messages.
+	MessageType() int
+	// Marshal encodes a message.
+	Marshal(v any) ([]byte, error)
+	// Unmarshal decodes a message into the value pointed to by v.
+	Unmarshal(data []byte, v any) error
+}
+
+// clientSubprotocols returns the encodings a client asks for, in the order of its preference.
+func clientSubprotocols() []string {
+	if PreferJSON {
+		return []string{SubprotocolJSON}
+	}
+
+	return []string{SubprotocolBinary, SubprotocolJSON}
+}
+
+// codecFor returns the codec of the negotiated subprotocol.
+// Clients that did not negotiate an encoding speak JSON.
+func codecFor(subprotocol string) codec {
+	if subprotocol == SubprotocolBinary {
+		return binaryCodec{}
+	}
+
+	return jsonCodec{}
+}
+
+// jsonCodec encodes the messages as JSON text messages.
+type jsonCodec struct{}
+
+func (jsonCodec) Subprotocol() string                { return SubprotocolJSON }
+func (jsonCodec) MessageType() int                   { return websocket.TextMessage }
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// positionScale is the number of steps a pixel is divided into when the binary encoding quantizes positions.
+const positionScale = 64
+
+// The values of the string fields the binary encoding sends as their index in the table.
+// Values missing from a table are still sent, but spelled out.
+var (
+	gameStateNames  = []string{GameStateLobby, GameStateRunning, GameStateEnd}
+	entityTypeNames = []string{"", sim.MonsterGhost, sim.MonsterSlime, sim.MonsterBalloon, sim.MonsterOnion, "SkullDebuff", "Skate", "RadiusIncrease", "BombCountIncrease", "RollerIncrease",
+		"ObstacleIncrease", "DetonatorIncrease", "GhostIncrease", "InvincibilityIncrease"}
+	terrainNames   = []string{"GRASS", "SOLID"}
+	animationNames = []string{"", "walkUp", "walkDown", "walkLeft", "walkRight"}
+)
+
+// errUnknownMessage is returned when the binary encoding is asked to handle a type it does not know.
+var errUnknownMessage = errors.New("unknown message type")
+
+// binaryCodec encodes the messages as compact binary messages.
+type binaryCodec struct{}
+
+func (binaryCodec) Subprotocol() string { return SubprotocolBinary }
+func (binaryCodec) MessageType() int    { return websocket.BinaryMessage }
+
+// Marshal encodes a message.
+//
+// Parameters:
+//   - v: The message to encode, a ProtoSnapshot or a ProtoClientMessage.
+//
+// Returns:
+//   - []byte: The encoded message.
+//   - error: An error if the message type is not supported.
+func (binaryCodec) Marshal(v any) ([]byte, error) {
+	var w binaryWriter
+
+	switch msg := v.(type) {
+	case *ProtoSnapshot:
+		w.snapshot(msg)
+	case ProtoSnapshot:
+		w.snapshot(&msg)
+	case *ProtoClientMessage:
+		w.clientMessage(msg)
+	case ProtoClientMessage:
+		w.clientMessage(&msg)
+	default:
+		return nil, fmt.Errorf("%w: %T", errUnknownMessage, v)
+	}
+
+	return w.buf, nil
+}
+
+// Unmarshal decodes a message into the value pointed to by v.
+//
+// Parameters:
+//   - data: The encoded message.
+//   - v: A pointer to a ProtoSnapshot or a ProtoClientMessage.
+//
+// Returns:
+//   - error: An error if the message is malformed or the message type is not supported.
+func (binaryCodec) Unmarshal(data []byte, v any) error {
+	r := binaryReader{data: data}
+
+	switch msg := v.(type) {
+	case *ProtoSnapshot:
+		r.snapshot(msg)
+	case *ProtoClientMessage:
+		r.clientMessage(msg)
+	default:
+		return fmt.Errorf("%w: %T", errUnknownMessage, v)
+	}
+
+	if r.err == nil && len(r.data) > 0 {
+		r.err = fmt.Errorf("%d trailing bytes", len(r.data))
+	}
+
+	return r.err
+}
+
+// binaryWriter appends the primitives of the binary encoding to a buffer.
+type binaryWriter struct {
+	buf []byte // The encoded message.
+}
+
+// uvarint writes an unsigned variable-length integer.
+func (w *binaryWriter) uvarint(v uint64) {
+	w.buf = binary.AppendUvarint(w.buf, v)
+}
+
+// varint writes a signed variable-length integer.
+func (w *binaryWriter) varint(v int64) {
+	w.buf = binary.AppendVarint(w.buf, v)
+}
+
+// byte writes a single byte.
+func (w *binaryWriter) byte(v byte) {
+	w.buf = append(w.buf, v)
+}
+
+// boolean writes a boolean as a single byte.
+func (w *binaryWriter) boolean(v bool) {
+	if v {
+		w.byte(1)
+	} else {
+		w.byte(0)
+	}
+}
+
+// string writes a length-prefixed string.
+func (w *binaryWriter) string(v string) {
+	w.uvarint(uint64(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+// enum writes a string as its index in the table, or spelled out if the table does not contain it.
+func (w *binaryWriter) enum(table []string, v string) {
+	for i, name := range table {
+		if name == v {
+			w.uvarint(uint64(i + 1))
+			return
+		}
+	}
+
+	w.uvarint(0)
+	w.string(v)
+}
+
+// position writes a coordinate quantized to a fraction of a pixel.
+func (w *binaryWriter) position(v float64) {
+	w.varint(int64(math.Round(v * positionScale)))
+}
+
+// color writes a color as its four components.
+func (w *binaryWriter) color(c color.RGBA) {
+	w.buf = append(w.buf, c.R, c.G, c.B, c.A)
+}
+
+// player writes the information of a player.
+func (w *binaryWriter) player(p ProtoPlayer) {
+	w.string(p.Username)
+	w.varint(int64(p.Ping))
+	w.position(p.X)
+	w.position(p.Y)
+	w.byte(p.Control.Bits())
+	w.color(p.Color)
+	w.boolean(p.IsDead)
+	w.boolean(p.Ready)
+	w.varint(int64(p.Score))
+	w.uvarint(p.InputSeq)
+}
+
+// entity writes a generic game entity.
+func (w *binaryWriter) entity(e ProtoEntity) {
+	w.uvarint(e.ID)
+	w.position(e.X)
+	w.position(e.Y)
+	w.boolean(e.IsDead)
+	w.enum(entityTypeNames, e.Type)
+	w.enum(animationNames, e.CurrentAnimation)
+}
+
+// terrainChange writes a change in the terrain.
+func (w *binaryWriter) terrainChange(c ProtoTerrainChange) {
+	w.varint(int64(c.X))
+	w.varint(int64(c.Y))
+	w.enum(terrainNames, c.To)
+}
+
+// writeList writes the changes of a list, using write for every changed element.
+func writeList[T comparable](w *binaryWriter, delta ProtoListDelta[T], write func(T)) {
+	w.uvarint(uint64(delta.Length))
+	w.uvarint(uint64(len(delta.Changed)))
+	for _, change := range delta.Changed {
+		w.uvarint(uint64(change.Index))
+		write(change.Value)
+	}
+}
+
+// entityDelta writes the changes of a list of entities.
+func (w *binaryWriter) entityDelta(delta ProtoEntityDelta) {
+	for _, entities := range [][]ProtoEntity{delta.Spawned, delta.Updated} {
+		w.uvarint(uint64(len(entities)))
+		for _, e := range entities {
+			w.entity(e)
+		}
+	}
+
+	w.uvarint(uint64(len(delta.Despawned)))
+	for _, id := range delta.Despawned {
+		w.uvarint(id)
+	}
+}
+
+// snapshot writes a snapshot of the game state.
+func (w *binaryWriter) snapshot(s *ProtoSnapshot) {
+	w.uvarint(s.Seq)
+	w.uvarint(s.Baseline)
+	w.enum(gameStateNames, s.GameState)
+	w.string(s.Level)
+	w.string(s.LevelHash)
+	w.varint(s.Seed)
+	w.uvarint(s.Tick)
+	w.uvarint(uint64(s.MaxPlayers))
+	w.varint(int64(s.Player))
+
+	writeList(w, s.TerrainChanges, w.terrainChange)
+	writeList(w, s.Players, w.player)
+	for _, delta := range []ProtoEntityDelta{s.Monsters, s.Bombs, s.Explosions, s.Boxes, s.StatusEffects} {
+		w.entityDelta(delta)
+	}
+	w.uvarint(uint64(len(s.Chat)))
+	for _, message := range s.Chat {
+		w.chatMessage(message)
+	}
+}
+
+// chatMessage writes a chat message.
+func (w *binaryWriter) chatMessage(m ProtoChatMessage) {
+	w.uvarint(m.ID)
+	w.string(m.From)
+	w.string(m.Text)
+	w.boolean(m.System)
+}
+
+// clientMessage writes a message sent by a client.
+func (w *binaryWriter) clientMessage(m *ProtoClientMessage) {
+	w.uvarint(m.Ack)
+	w.boolean(m.Input != nil)
+	if m.Input != nil {
+		w.player(*m.Input)
+	}
+	w.boolean(m.Lobby != nil)
+	if m.Lobby != nil {
+		w.boolean(m.Lobby.Ready)
+		w.color(m.Lobby.Color)
+	}
+	w.string(m.Chat)
+}
+
+// binaryReader reads the primitives of the binary encoding, remembering the first error.
+type binaryReader struct {
+	data []byte // The part of the message not read yet.
+	err  error  // The first error that occurred while reading.
+}
+
+// errTruncated is returned when a message ends before all of its fields were read.
+var errTruncated = errors.New("truncated message")
+
+// uvarint reads an unsigned variable-length integer.
+func (r *binaryReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = errTruncated
+		return 0
+	}
+	r.data = r.data[n:]
+
+	return v
+}
+
+// varint reads a signed variable-length integer.
+func (r *binaryReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.err = errTruncated
+		return 0
+	}
+	r.data = r.data[n:]
+
+	return v
+}
+
+// length reads the length of a list or string, which cannot exceed the rest of the message.
+func (r *binaryReader) length() int {
+	v := r.uvarint()
+	if v > uint64(len(r.data)) {
+		r.err = errTruncated
+		return 0
+	}
+
+	return int(v)
+}
+
+// bytes reads n raw bytes.
+func (r *binaryReader) bytes(n int) []byte {
+	if r.err != nil {
+		return make([]byte, n)
+	}
+	if len(r.data) < n {
+		r.err = errTruncated
+		return make([]byte, n)
+	}
+
+	b := r.data[:n]
+	r.data = r.data[n:]
+
+	return b
+}
+
+// byte reads a single byte.
+func (r *binaryReader) byte() byte {
+	return r.bytes(1)[0]
+}
+
+// boolean reads a boolean stored as a single byte.
+func (r *binaryReader) boolean() bool {
+	return r.byte() != 0
+}
+
+// string reads a length-prefixed string.
+func (r *binaryReader) string() string {
+	return string(r.bytes(r.length()))
+}
+
+// enum reads a string written as its index in the table.
+func (r *binaryReader) enum(table []string) string {
+	i := r.uvarint()
+	if i == 0 {
+		return r.string()
+	}
+	if i > uint64(len(table)) {
+		if r.err == nil {
+			r.err = fmt.Errorf("unknown enum value %d", i)
+		}
+		return ""
+	}
+
+	return table[i-1]
+}
+
+// position reads a quantized coordinate.
+func (r *binaryReader) position() float64 {
+	return float64(r.varint()) / positionScale
+}
+
+// color reads a color written as its four components.
+func (r *binaryReader) color() color.RGBA {
+	c := r.bytes(4)
+
+	return color.RGBA{R: c[0], G: c[1], B: c[2], A: c[3]}
+}
+
+// player reads the information of a player.
+func (r *binaryReader) player() ProtoPlayer {
+	var p ProtoPlayer
+	p.Username = r.string()
+	p.Ping = time.Duration(r.varint())
+	p.X = r.position()
+	p.Y = r.position()
+	p.Control = entities.PlayerControlsFromBits(r.byte())
+	p.Color = r.color()
+	p.IsDead = r.boolean()
+	p.Ready = r.boolean()
+	p.Score = int(r.varint())
+	p.InputSeq = r.uvarint()
+
+	return p
+}
+
+// entity reads a generic game entity.
+func (r *binaryReader) entity() ProtoEntity {
+	var e ProtoEntity
+	e.ID = r.uvarint()
+	e.X = r.position()
+	e.Y = r.position()
+	e.IsDead = r.boolean()
+	e.Type = r.enum(entityTypeNames)
+	e.CurrentAnimation = r.enum(animationNames)
+
+	return e
+}
+
+// terrainChange reads a change in the terrain.
+func (r *binaryReader) terrainChange() ProtoTerrainChange {
+	var c ProtoTerrainChange
+	c.X = int(r.varint())
+	c.Y = int(r.varint())
+	c.To = r.enum(terrainNames)
+
+	return c
+}
+
+// readList reads the changes of a list, using read for every changed element.
+func readList[T comparable](r *binaryReader, read func() T) ProtoListDelta[T] {
+	delta := ProtoListDelta[T]{Length: int(r.uvarint())}
+	if delta.Length > maxListLength && r.err == nil {
+		r.err = fmt.Errorf("list length %d exceeds the limit of %d", delta.Length, maxListLength)
+	}
+
+	// every change takes at least a byte, so the count cannot exceed the rest of the message
+	count := r.length()
+	for i := 0; i < count && r.err == nil; i++ {
+		index := int(r.uvarint())
+		delta.Changed = append(delta.Changed, ProtoListChange[T]{Index: index, Value: read()})
+	}
+
+	return delta
+}
+
+// entityDelta reads the changes of a list of entities.
+func (r *binaryReader) entityDelta() ProtoEntityDelta {
+	var delta ProtoEntityDelta
+	for _, entities := range []*[]ProtoEntity{&delta.Spawned, &delta.Updated} {
+		// every entity takes at least a byte, so the count cannot exceed the rest of the message
+		count := r.length()
+		for i := 0; i < count && r.err == nil; i++ {
+			*entities = append(*entities, r.entity())
+		}
+	}
+
+	count := r.length()
+	for i := 0; i < count && r.err == nil; i++ {
+		delta.Despawned = append(delta.Despawned, r.uvarint())
+	}
+
+	return delta
+}
+
+// snapshot reads a snapshot of the game state.
+func (r *binaryReader) snapshot(s *ProtoSnapshot) {
+	s.Seq = r.uvarint()
+	s.Baseline = r.uvarint()
+	s.GameState = r.enum(gameStateNames)
+	s.Level = r.string()
+	s.LevelHash = r.string()
+	s.Seed = r.varint()
+	s.Tick = r.uvarint()
+	s.MaxPlayers = int(r.uvarint())
+	s.Player = int(r.varint())
+
+	s.TerrainChanges = readList(r, r.terrainChange)
+	s.Players = readList(r, r.player)
+	for _, delta := range []*ProtoEntityDelta{&s.Monsters, &s.Bombs, &s.Explosions, &s.Boxes, &s.StatusEffects} {
+		*delta = r.entityDelta()
+	}
+	s.Chat = nil
+	for i, count := 0, r.length(); i < count && r.err == nil; i++ {
+		s.Chat = append(s.Chat, r.chatMessage())
+	}
+}
+
+// chatMessage reads a chat message.
+func (r *binaryReader) chatMessage() ProtoChatMessage {
+	var m ProtoChatMessage
+	m.ID = r.uvarint()
+	m.From = r.string()
+	m.Text = r.string()
+	m.System = r.boolean()
+
+	return m
+}
+
+// clientMessage reads a message sent by a client.
+func (r *binaryReader) clientMessage(m *ProtoClientMessage) {
+	m.Ack = r.uvarint()
+	m.Input = nil
+	if r.boolean() {
+		input := r.player()
+		m.Input = &input
+	}
+	m.Lobby = nil
+	if r.boolean() {
+		lobby := ProtoLobbyRequest{Ready: r.boolean(), Color: r.color()}
+		m.Lobby = &lobby
+	}
+	m.Chat = r.string()
+}
@@ -0,0 +1,116 @@
+package multiplayer
+
+import (
+	"image/color"
+	"reflect"
+	"testing"
+
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/entities"
+)
+
+// testSnapshot returns a snapshot using every field of the protocol.
+func testSnapshot() ProtoSnapshot {
+	return ProtoSnapshot{
+		Seq:            42,
+		Baseline:       40,
+		GameState:      GameStateRunning,
+		Level:          "assets/levels/level1.txt",
+		LevelHash:      "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03",
+		Seed:           -7,
+		Tick:           99,
+		MaxPlayers:     5,
+		Player:         1,
+		TerrainChanges: ProtoListDelta[ProtoTerrainChange]{Length: 3, Changed: []ProtoListChange[ProtoTerrainChange]{{Index: 2, Value: ProtoTerrainChange{X: 4, Y: 5, To: "GRASS"}}}},
+		Players: ProtoListDelta[ProtoPlayer]{Length: 2, Changed: []ProtoListChange[ProtoPlayer]{{Index: 1, Value: ProtoPlayer{
+			Username: "ninja", Ping: 1234, X: 83.5, Y: -1.25, Control: entities.PlayerControls{Up: true, Ability2: true},
+			Color: color.RGBA{R: 255, B: 10, A: 255}, IsDead: true, Ready: true, Score: 3, InputSeq: 300,
+		}}}},
+		Monsters:      ProtoEntityDelta{Updated: []ProtoEntity{{ID: 1, X: 16, Y: 32.015625, Type: "GHOST"}}},
+		Bombs:         ProtoEntityDelta{},
+		Explosions:    ProtoEntityDelta{Despawned: []uint64{4, 5}},
+		Boxes:         ProtoEntityDelta{Spawned: []ProtoEntity{{ID: 9, X: 1, Y: 2, IsDead: true}}},
+		StatusEffects: ProtoEntityDelta{Spawned: []ProtoEntity{{ID: 12, Type: "SkullDebuff"}, {ID: 13, Type: "NotInTheTable"}}},
+		Chat:          []ProtoChatMessage{{ID: 7, From: "ninja", Text: "gg"}, {ID: 8, Text: "ninja left", System: true}},
+	}
+}
+
+func TestBinaryCodec_Snapshot(t *testing.T) {
+	snapshot := testSnapshot()
+
+	data, err := binaryCodec{}.Marshal(&snapshot)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var got ProtoSnapshot
+	if err := (binaryCodec{}).Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, snapshot) {
+		t.Errorf("Expected %+v, got %+v", snapshot, got)
+	}
+
+	jsonData, err := jsonCodec{}.Marshal(&snapshot)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if len(data)*4 > len(jsonData) {
+		t.Errorf("Expected the binary encoding to be much smaller than %d bytes of JSON, got %d bytes", len(jsonData), len(data))
+	}
+}
+
+func TestBinaryCodec_ClientMessage(t *testing.T) {
+	tests := []ProtoClientMessage{
+		{Ack: 9},
+		{Ack: 10, Input: &ProtoPlayer{Username: "ninja", Control: entities.PlayerControls{Left: true, Ability1: true}, InputSeq: 11}},
+		{Ack: 12, Lobby: &ProtoLobbyRequest{Ready: true, Color: color.RGBA{R: 255, A: 255}}},
+		{Ack: 13, Chat: "hello"},
+	}
+
+	for _, message := range tests {
+		data, err := binaryCodec{}.Marshal(message)
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+
+		var got ProtoClientMessage
+		if err := (binaryCodec{}).Unmarshal(data, &got); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		if !reflect.DeepEqual(got, message) {
+			t.Errorf("Expected %+v, got %+v", message, got)
+		}
+	}
+}
+
+func TestBinaryCodec_Truncated(t *testing.T) {
+	snapshot := testSnapshot()
+	data, err := binaryCodec{}.Marshal(&snapshot)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	for _, n := range []int{0, 1, len(data) / 2, len(data) - 1} {
+		var got ProtoSnapshot
+		if err := (binaryCodec{}).Unmarshal(data[:n], &got); err == nil {
+			t.Errorf("Expected an error for a message truncated to %d bytes", n)
+		}
+	}
+}
+
+func TestCodecFor(t *testing.T) {
+	tests := []struct {
+		subprotocol string
+		expected    codec
+	}{
+		{SubprotocolBinary, binaryCodec{}},
+		{SubprotocolJSON, jsonCodec{}},
+		{"", jsonCodec{}},
+	}
+
+	for _, test := range tests {
+		if got := codecFor(test.subprotocol); got != test.expected {
+			t.Errorf("Expected %T for %q, got %T", test.expected, test.subprotocol, got)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// This file contains the settings of the game server.
+package multiplayer
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/entities"
+)
+
+// DefaultPort is the port the game server listens on unless configured otherwise.
+const DefaultPort = 8080
+
+// DefaultResumeGrace is how long a server keeps the player of a dropped connection unless configured otherwise.
+const DefaultResumeGrace = 30 * time.Second
+
+// DefaultTickRate is the number of ticks the server simulates per second.
+const DefaultTickRate = entities.TicksPerSecond
+
+// MaxPlayers is the largest number of players a server accepts, one for every player color.
+const MaxPlayers = 7
+
+// DefaultMaxSpectators is the number of spectators a server accepts unless configured otherwise.
+const DefaultMaxSpectators = 16
+
+// ServerConfig represents the settings of a game server.
+type ServerConfig struct {
+	Address           string        // The address the server listens on, in host:port form.
+	Level             string        // The path to the level file the match is played on.
+	LevelDir          string        // The directory the levels chosen by name through the admin API are read from, or empty to refuse choosing levels.
+	MaxPlayers        int           // The number of players the server accepts.
+	MaxSpectators     int           // The number of spectators the server accepts besides the players.
+	TickRate          int           // The number of times per second the server applies the inputs and sends snapshots.
+	StartPlayers      int           // The number of connected players starting the match on their own, or 0 to wait for StartMatch.
+	Discoverable      bool          // Whether the server answers the discovery probes of the clients on the local network.
+	ResumeGrace       time.Duration // How long the player of a dropped connection is kept for the client to resume it, or 0 to drop it right away.
+	AdminToken        string        // The token authorizing the requests to the admin API, or empty to disable it.
+	Password          string        // The password or invite code new clients have to give to join, or empty for a server open to everyone.
+	TLS               bool          // Whether the server only accepts secure connections.
+	CertFile          string        // The path to the certificate of the server, or empty to use a self-signed one.
+	KeyFile           string        // The path to the private key of the certificate.
+	HeartbeatInterval time.Duration // How often the server and the clients ping each other.
+	HeartbeatTimeout  time.Duration // How long a connection may stay silent before it is dropped.
+}
+
+// DefaultServerConfig returns the settings of a server hosting the given level on DefaultPort of every interface.
+// The other levels are chosen from the directory of the level.
+//
+// Parameters:
+//   - level: The path to the level file the match is played on.
+//
+// Returns:
+//   - ServerConfig: The default settings.
+func DefaultServerConfig(level string) ServerConfig {
+	return ServerConfig{
+		Address:           net.JoinHostPort("", strconv.Itoa(DefaultPort)),
+		Level:             level,
+		LevelDir:          filepath.Dir(level),
+		MaxPlayers:        MaxPlayers,
+		MaxSpectators:     DefaultMaxSpectators,
+		TickRate:          DefaultTickRate,
+		Discoverable:      true,
+		ResumeGrace:       DefaultResumeGrace,
+		HeartbeatInterval: DefaultHeartbeatInterval,
+		HeartbeatTimeout:  DefaultHeartbeatTimeout,
+	}
+}
+
+// Validate checks whether the server can run with the settings.
+//
+// Returns:
+//   - error: An error describing the first invalid setting, or nil if the settings are valid.
+func (c ServerConfig) Validate() error {
+	if _, _, err := net.SplitHostPort(c.Address); err != nil {
+		return fmt.Errorf("invalid address %q: %w", c.Address, err)
+	}
+	if c.Level == "" {
+		return fmt.Errorf("no level given")
+	}
+	if c.MaxPlayers < 1 || c.MaxPlayers > MaxPlayers {
+		return fmt.Errorf("max players must be between 1 and %d, got %d", MaxPlayers, c.MaxPlayers)
+	}
+	if c.MaxSpectators < 0 {
+		return fmt.Errorf("max spectators must not be negative, got %d", c.MaxSpectators)
+	}
+	// the world always advances at entities.TicksPerSecond, a server tick simulates a whole number of its ticks
+	if c.TickRate < 1 || c.TickRate > entities.TicksPerSecond || entities.TicksPerSecond%c.TickRate != 0 {
+		return fmt.Errorf("tick rate must divide %d, got %d", entities.TicksPerSecond, c.TickRate)
+	}
+	if c.ResumeGrace < 0 {
+		return fmt.Errorf("resume grace must not be negative, got %s", c.ResumeGrace)
+	}
+	if c.HeartbeatInterval <= 0 {
+		return fmt.Errorf("heartbeat interval must be positive, got %s", c.HeartbeatInterval)
+	}
+	// a timeout shorter than the interval would drop every connection between two heartbeats
+	if c.HeartbeatTimeout <= c.HeartbeatInterval {
+		return fmt.Errorf("heartbeat timeout must be longer than the heartbeat interval, got %s", c.HeartbeatTimeout)
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("a certificate needs both its file and its key file")
+	}
+	if c.CertFile != "" && !c.TLS {
+		return fmt.Errorf("a certificate is given, but TLS is disabled")
+	}
+	if c.StartPlayers < 0 || c.StartPlayers > c.MaxPlayers {
+		return fmt.Errorf("start players must be between 0 and the max players, got %d", c.StartPlayers)
+	}
+
+	return nil
+}
+
+// stepsPerTick returns the number of world ticks simulated by a single server tick.
+func (c ServerConfig) stepsPerTick() int {
+	return entities.TicksPerSecond / c.TickRate
+}
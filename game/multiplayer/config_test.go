@@ -0,0 +1,36 @@
+package multiplayer
+
+import "testing"
+
+func TestServerConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*ServerConfig)
+		valid  bool
+	}{
+		{"default", func(c *ServerConfig) {}, true},
+		{"address without port", func(c *ServerConfig) { c.Address = "localhost" }, false},
+		{"no level", func(c *ServerConfig) { c.Level = "" }, false},
+		{"no players", func(c *ServerConfig) { c.MaxPlayers = 0 }, false},
+		{"more players than colors", func(c *ServerConfig) { c.MaxPlayers = MaxPlayers + 1 }, false},
+		{"no spectators", func(c *ServerConfig) { c.MaxSpectators = 0 }, true},
+		{"negative spectators", func(c *ServerConfig) { c.MaxSpectators = -1 }, false},
+		{"tick rate dividing the game", func(c *ServerConfig) { c.TickRate = DefaultTickRate / 2 }, true},
+		{"tick rate not dividing the game", func(c *ServerConfig) { c.TickRate = DefaultTickRate - 1 }, false},
+		{"start players above the max", func(c *ServerConfig) { c.MaxPlayers, c.StartPlayers = 2, 3 }, false},
+		{"no heartbeats", func(c *ServerConfig) { c.HeartbeatInterval = 0 }, false},
+		{"heartbeat timeout below the interval", func(c *ServerConfig) { c.HeartbeatTimeout = c.HeartbeatInterval }, false},
+		{"self-signed certificate", func(c *ServerConfig) { c.TLS = true }, true},
+		{"certificate without key", func(c *ServerConfig) { c.TLS, c.CertFile = true, "server.crt" }, false},
+		{"certificate without TLS", func(c *ServerConfig) { c.CertFile, c.KeyFile = "server.crt", "server.key" }, false},
+	}
+
+	for _, test := range tests {
+		config := DefaultServerConfig("level.txt")
+		test.modify(&config)
+
+		if err := config.Validate(); (err == nil) != test.valid {
+			t.Errorf("%s: Expected valid to be %t, got error %v", test.name, test.valid, err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// This file contains the reasons a game server closes the connections of its clients for.
+// Every connection the server ends on purpose is closed with a close message carrying one of the close codes
+// and a reason readable by the player, so the client can tell the player why the game ended
+// instead of treating it as a dropped connection to reestablish.
+package multiplayer
+
+import (
+	"fmt"
+
+	"github.com/gorilla/websocket"
+)
+
+// CloseHostLeft is the close code of the connections closed because the host stopped the server or closed the room.
+const CloseHostLeft = 4002
+
+// CloseKicked is the close code of the connection of a user kicked by the host.
+const CloseKicked = 4003
+
+// CloseBanned is the close code of the connections of a user banned by the host.
+const CloseBanned = 4004
+
+// CloseServerFull is the close code of a connection refused because the server has no room for another client.
+const CloseServerFull = 4005
+
+// CloseReason returns the reason the server closed a connection for, readable by the player.
+// The reason given by the server is preferred, a close message without one is described by its code.
+//
+// Parameters:
+//   - code: The close code of the connection.
+//   - text: The reason given by the server in its close message.
+//
+// Returns:
+//   - string: The reason the connection was closed for.
+func CloseReason(code int, text string) string {
+	if text != "" {
+		return text
+	}
+
+	switch code {
+	case CloseVersionMismatch:
+		return "incompatible game versions"
+	case CloseWrongPassword:
+		return errWrongPassword.Error()
+	case CloseHostLeft:
+		return "the host left the game"
+	case CloseKicked:
+		return "kicked by the host"
+	case CloseBanned:
+		return "banned from the server"
+	case CloseServerFull:
+		return errServerFull.Error()
+	case websocket.CloseAbnormalClosure:
+		return "the connection to the server was lost"
+	default:
+		return fmt.Sprintf("the server closed the connection (code %d)", code)
+	}
+}
+
+// closeAll closes the connections of every client with the given code and reason, while holding the lock of the server.
+func (s *GameServer) closeAll(code int, reason string) {
+	for client := range s.recipients() {
+		if client.connection != nil {
+			closeConnection(client.connection.conn, code, reason)
+		}
+	}
+}
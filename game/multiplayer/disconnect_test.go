@@ -0,0 +1,72 @@
+package multiplayer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/userinfo"
+)
+
+func TestCloseReason(t *testing.T) {
+	tests := []struct {
+		code   int
+		text   string
+		reason string
+	}{
+		{CloseKicked, "kicked by the host", "kicked by the host"},
+		{CloseHostLeft, "", "the host left the game"},
+		{CloseServerFull, "", "server is full"},
+		{websocket.CloseAbnormalClosure, "", "the connection to the server was lost"},
+		{4999, "", "the server closed the connection (code 4999)"},
+	}
+
+	for _, test := range tests {
+		if reason := CloseReason(test.code, test.text); reason != test.reason {
+			t.Errorf("Expected %q for code %d, got %q", test.reason, test.code, reason)
+		}
+	}
+}
+
+func TestGameClient_Disconnected(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var hello ProtoHello
+		conn.ReadJSON(&hello)
+		conn.WriteJSON(ProtoWelcome{Version: ProtocolVersion, UserID: "session", TickRate: DefaultTickRate, ResumeGrace: time.Second})
+		closeConnection(conn, CloseHostLeft, "the host left the game")
+	}))
+	defer server.Close()
+
+	codes := make(chan int, 1)
+	client, err := NewGameClient(strings.TrimPrefix(server.URL, "http://"), &userinfo.UserInfo{}, "", func(code int, text string) error {
+		codes <- code
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected the client to connect, got %v", err)
+	}
+	defer client.Close()
+
+	select {
+	case code := <-codes:
+		if code != CloseHostLeft {
+			t.Errorf("Expected close code %d, got %d", CloseHostLeft, code)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the close handler to be called")
+	}
+
+	if reason, ok := client.Disconnected(); !ok || reason != "the host left the game" {
+		t.Errorf("Expected the client to be disconnected because the host left, got %q, %t", reason, ok)
+	}
+}
@@ -0,0 +1,286 @@
+// This file contains the discovery of the game servers on the local network.
+// Browsing clients broadcast a probe every second and the servers answer it with their details,
+// so the round trip of the probe is also the ping of the server.
+package multiplayer
+
+import (
+	"cmp"
+	"encoding/json"
+	"log"
+	"net"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiscoveryPort is the UDP port the game servers answer the discovery probes on.
+const DiscoveryPort = 8081
+
+// discoveryMagic marks the discovery messages, so unrelated datagrams sent to the port are ignored.
+const discoveryMagic = "ninjago"
+
+// probeInterval is how often a browsing client broadcasts a probe.
+const probeInterval = time.Second
+
+// serverTimeout is how long a server stays in the list of a browsing client without answering a probe.
+const serverTimeout = 3 * probeInterval
+
+// maxDatagramSize is the size of the buffer the discovery messages are read into.
+const maxDatagramSize = 1024
+
+// ProtoDiscoveryProbe represents the probe a browsing client broadcasts to find the game servers.
+type ProtoDiscoveryProbe struct {
+	Magic string // The marker of the discovery messages.
+	Nonce int64  // The value the servers echo back, used to measure the ping.
+}
+
+// ProtoServerInfo represents the answer of a game server to a discovery probe.
+type ProtoServerInfo struct {
+	Magic      string // The marker of the discovery messages.
+	Nonce      int64  // The value of the probe being answered.
+	Version    int    // The protocol version of the server.
+	Port       int    // The TCP port the game server listens on.
+	Level      string // The name of the level played on the server.
+	GameState  string // The state of the match on the server.
+	Players    int    // The number of connected players.
+	MaxPlayers int    // The number of players the server accepts.
+	Private    bool   // Whether joining the server requires a password or an invite code.
+	TLS        bool   // Whether the server only accepts secure connections.
+}
+
+// DiscoveredServer represents a game server found on the local network.
+type DiscoveredServer struct {
+	Address string          // The address of the game server, in host:port form.
+	Info    ProtoServerInfo // The latest details sent by the server.
+	Ping    time.Duration   // The round trip time of the latest probe answered by the server.
+	seen    time.Time       // The time the server last answered a probe.
+}
+
+// JoinAddress returns the address a client joins the server at, with the secure scheme if the server uses TLS.
+func (d DiscoveredServer) JoinAddress() string {
+	if d.Info.TLS {
+		return secureScheme + d.Address
+	}
+
+	return d.Address
+}
+
+// Compatible returns whether the server speaks the protocol version of this client.
+func (d DiscoveredServer) Compatible() bool {
+	return d.Info.Version == ProtocolVersion
+}
+
+// serveDiscovery answers the discovery probes received on the connection until it is closed.
+//
+// Parameters:
+//   - conn: The UDP connection listening on DiscoveryPort.
+//   - port: The TCP port the game server listens on.
+func (s *GameServer) serveDiscovery(conn net.PacketConn, port int) {
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		var probe ProtoDiscoveryProbe
+		if err := json.Unmarshal(buf[:n], &probe); err != nil || probe.Magic != discoveryMagic {
+			continue
+		}
+
+		answer, err := json.Marshal(s.serverInfo(probe.Nonce, port))
+		if err != nil {
+			log.Println("Failed to encode discovery answer", err)
+			continue
+		}
+		if _, err := conn.WriteTo(answer, addr); err != nil {
+			log.Println("Failed to answer discovery probe", err)
+		}
+	}
+}
+
+// serverInfo returns the details of the server sent to a browsing client.
+func (s *GameServer) serverInfo(nonce int64, port int) ProtoServerInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return ProtoServerInfo{
+		Magic:      discoveryMagic,
+		Nonce:      nonce,
+		Version:    ProtocolVersion,
+		Port:       port,
+		Level:      strings.TrimSuffix(filepath.Base(s.config.Level), filepath.Ext(s.config.Level)),
+		GameState:  s.info.GameState,
+		Players:    len(s.clients),
+		MaxPlayers: s.config.MaxPlayers,
+		Private:    s.config.Password != "",
+		TLS:        s.config.TLS,
+	}
+}
+
+// ServerBrowser finds the game servers on the local network in the background.
+type ServerBrowser struct {
+	conn    *net.UDPConn                // The connection the probes are sent and answered on.
+	start   time.Time                   // The time the browser started, the nonces are measured from.
+	mu      sync.Mutex                  // The lock guarding the discovered servers.
+	servers map[string]DiscoveredServer // The discovered servers by their address.
+	done    chan struct{}               // The channel closed when the browser is closed.
+	closed  sync.Once                   // Ensures the browser is only closed once.
+}
+
+// NewServerBrowser creates a ServerBrowser and starts probing the local network.
+//
+// Returns:
+//   - *ServerBrowser: A pointer to the newly created ServerBrowser instance.
+//   - error: An error if the UDP connection cannot be opened.
+func NewServerBrowser() (*ServerBrowser, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, err
+	}
+
+	b := &ServerBrowser{
+		conn:    conn,
+		start:   time.Now(),
+		servers: make(map[string]DiscoveredServer),
+		done:    make(chan struct{}),
+	}
+
+	go b.readLoop()
+	go b.probeLoop()
+
+	return b, nil
+}
+
+// Servers returns the servers that answered recently, ordered by their ping.
+func (b *ServerBrowser) Servers() []DiscoveredServer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	servers := make([]DiscoveredServer, 0, len(b.servers))
+	for address, server := range b.servers {
+		if time.Since(server.seen) > serverTimeout {
+			delete(b.servers, address)
+			continue
+		}
+		servers = append(servers, server)
+	}
+	slices.SortFunc(servers, func(a, b DiscoveredServer) int {
+		return cmp.Or(cmp.Compare(a.Ping, b.Ping), cmp.Compare(a.Address, b.Address))
+	})
+
+	return servers
+}
+
+// Close stops probing and closes the connection of the browser.
+func (b *ServerBrowser) Close() {
+	b.closed.Do(func() {
+		close(b.done)
+		b.conn.Close()
+	})
+}
+
+// probeLoop broadcasts a probe every probeInterval until the browser is closed.
+func (b *ServerBrowser) probeLoop() {
+	ticker := time.NewTicker(probeInterval)
+	defer ticker.Stop()
+
+	for {
+		b.probe()
+
+		select {
+		case <-ticker.C:
+		case <-b.done:
+			return
+		}
+	}
+}
+
+// probe sends a probe to every broadcast address of the local network.
+func (b *ServerBrowser) probe() {
+	message, err := json.Marshal(ProtoDiscoveryProbe{Magic: discoveryMagic, Nonce: int64(time.Since(b.start))})
+	if err != nil {
+		log.Println("Failed to encode discovery probe", err)
+		return
+	}
+
+	for _, ip := range broadcastAddresses() {
+		// unreachable networks are expected, the probe is sent to every interface
+		b.conn.WriteToUDP(message, &net.UDPAddr{IP: ip, Port: DiscoveryPort})
+	}
+}
+
+// readLoop records the answers to the probes until the browser is closed.
+func (b *ServerBrowser) readLoop() {
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := b.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		received := time.Now()
+
+		var info ProtoServerInfo
+		if err := json.Unmarshal(buf[:n], &info); err != nil || info.Magic != discoveryMagic {
+			continue
+		}
+
+		// a server reached through several broadcast addresses answers every probe more than once
+		address := net.JoinHostPort(addr.IP.String(), strconv.Itoa(info.Port))
+		ping := received.Sub(b.start.Add(time.Duration(info.Nonce)))
+
+		b.mu.Lock()
+		if known, ok := b.servers[address]; !ok || known.Info.Nonce != info.Nonce {
+			b.servers[address] = DiscoveredServer{Address: address, Info: info, Ping: ping, seen: received}
+		}
+		b.mu.Unlock()
+	}
+}
+
+// broadcastAddresses returns the limited broadcast address and the broadcast address of every IPv4 network
+// of the machine, since some systems only send the limited broadcast on a single interface.
+func broadcastAddresses() []net.IP {
+	addresses := []net.IP{net.IPv4bcast}
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return addresses
+	}
+	for _, i := range interfaces {
+		if i.Flags&net.FlagUp == 0 || i.Flags&net.FlagBroadcast == 0 {
+			continue
+		}
+		addrs, err := i.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			network, ok := addr.(*net.IPNet)
+			if !ok || network.IP.To4() == nil {
+				continue
+			}
+			addresses = append(addresses, broadcastAddress(network))
+		}
+	}
+
+	return addresses
+}
+
+// broadcastAddress returns the broadcast address of an IPv4 network.
+func broadcastAddress(network *net.IPNet) net.IP {
+	ip := network.IP.To4()
+	mask := network.Mask
+	if len(mask) == net.IPv6len {
+		mask = mask[12:]
+	}
+
+	broadcast := make(net.IP, net.IPv4len)
+	for i := range broadcast {
+		broadcast[i] = ip[i] | ^mask[i]
+	}
+
+	return broadcast
+}
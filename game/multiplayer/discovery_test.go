@@ -0,0 +1,30 @@
+package multiplayer
+
+import (
+	"net"
+	"testing"
+)
+
+func TestBroadcastAddress(t *testing.T) {
+	tests := []struct {
+		network  string
+		expected string
+	}{
+		{"192.168.1.23/24", "192.168.1.255"},
+		{"10.0.0.5/8", "10.255.255.255"},
+		{"172.16.5.4/20", "172.16.15.255"},
+		{"192.168.1.23/32", "192.168.1.23"},
+	}
+
+	for _, test := range tests {
+		ip, network, err := net.ParseCIDR(test.network)
+		if err != nil {
+			t.Fatal(err)
+		}
+		network.IP = ip
+
+		if got := broadcastAddress(network); got.String() != test.expected {
+			t.Errorf("%s: Expected %s, got %s", test.network, test.expected, got)
+		}
+	}
+}
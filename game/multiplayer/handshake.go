@@ -0,0 +1,114 @@
+// This file contains the handshake opening every connection to the game server.
+// The client introduces itself with a hello carrying its protocol version, build and features,
+// and the server answers with its own in the welcome, or closes the connection with CloseVersionMismatch
+// and a readable reason if the client speaks another version of the protocol.
+package multiplayer
+
+import (
+	"fmt"
+	"runtime/debug"
+	"slices"
+	"time"
+)
+
+// CloseVersionMismatch is the close code of a connection refused because the client and the server
+// speak different versions of the protocol. The codes from 4000 are reserved for the applications.
+const CloseVersionMismatch = 4000
+
+// helloTimeout is how long the server waits for the hello of a new connection.
+const helloTimeout = 5 * time.Second
+
+// The optional features of the protocol a server or a client may support.
+const (
+	FeatureChat          = "chat"           // The chat between the players.
+	FeatureSpectators    = "spectators"     // Connections watching the match without a player.
+	FeatureLevelDownload = "level-download" // Downloading the level of the server by its content hash.
+)
+
+// clientFeatures are the features supported by the clients of this build.
+var clientFeatures = []string{FeatureChat, FeatureSpectators, FeatureLevelDownload}
+
+// Build identifies the build of the game, the revision it was built from if it is known.
+// It is only informational, compatibility is decided by ProtocolVersion.
+var Build = buildRevision()
+
+// ProtoHello represents the first message a client sends to the server.
+type ProtoHello struct {
+	Version  int      // The protocol version of the client.
+	Build    string   // The build of the client.
+	Features []string // The features supported by the client.
+	Password string   // The password or invite code of a private server, or empty.
+}
+
+// newHello returns the hello of the clients of this build.
+//
+// Parameters:
+//   - password: The password or invite code given by the player.
+//
+// Returns:
+//   - ProtoHello: The hello of the client.
+func newHello(password string) ProtoHello {
+	return ProtoHello{Version: ProtocolVersion, Build: Build, Features: clientFeatures, Password: password}
+}
+
+// checkVersion returns an error with a reason readable by the player if the peers speak different protocol versions.
+// The reason is sent in a close message, so it is kept well below the 123 bytes such a message can hold.
+//
+// Parameters:
+//   - server: The protocol version of the server.
+//   - client: The protocol version of the client.
+//
+// Returns:
+//   - error: An error describing the mismatch, or nil if the versions match.
+func checkVersion(server int, client int) error {
+	if server == client {
+		return nil
+	}
+
+	newer := "the server"
+	if client > server {
+		newer = "this game"
+	}
+
+	return fmt.Errorf("incompatible game versions: %s is newer (server v%d, client v%d)", newer, server, client)
+}
+
+// features returns the features the server supports with its settings.
+func (s *GameServer) features() []string {
+	features := []string{FeatureChat, FeatureLevelDownload}
+	if s.config.MaxSpectators > 0 {
+		features = append(features, FeatureSpectators)
+	}
+
+	return features
+}
+
+// Supports returns whether the game server supports an optional feature of the protocol.
+//
+// Parameters:
+//   - feature: One of the Feature constants.
+//
+// Returns:
+//   - bool: Whether the server announced the feature in its welcome.
+func (gc *GameClient) Supports(feature string) bool {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	return slices.Contains(gc.features, feature)
+}
+
+// buildRevision returns the short revision the game was built from, or "dev" if it is not known.
+func buildRevision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "dev"
+	}
+
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" && len(setting.Value) >= 7 {
+			return setting.Value[:7]
+		}
+	}
+
+	return "dev"
+}
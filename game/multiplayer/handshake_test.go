@@ -0,0 +1,113 @@
+package multiplayer
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/userinfo"
+)
+
+func TestCheckVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		server   int
+		client   int
+		expected string
+	}{
+		{"Same version", 2, 2, ""},
+		{"Newer server", 3, 2, "incompatible game versions: the server is newer (server v3, client v2)"},
+		{"Newer client", 2, 3, "incompatible game versions: this game is newer (server v2, client v3)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkVersion(tt.server, tt.client)
+			if tt.expected == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.expected {
+				t.Errorf("Expected error %q, got %v", tt.expected, err)
+			}
+			if len(tt.expected) > 123 {
+				t.Errorf("Expected the reason to fit a close message, got %d bytes", len(tt.expected))
+			}
+		})
+	}
+}
+
+func TestNewGameClient_VersionMismatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		server   func(conn *websocket.Conn)
+		expected string
+	}{
+		{
+			name: "Refused by the server",
+			server: func(conn *websocket.Conn) {
+				var hello ProtoHello
+				conn.ReadJSON(&hello)
+				closeConnection(conn, CloseVersionMismatch, checkVersion(ProtocolVersion+1, hello.Version).Error())
+			},
+			expected: "incompatible game versions: the server is newer",
+		},
+		{
+			name: "Older server",
+			server: func(conn *websocket.Conn) {
+				var hello ProtoHello
+				conn.ReadJSON(&hello)
+				conn.WriteJSON(ProtoWelcome{Version: ProtocolVersion - 1})
+				conn.ReadMessage()
+			},
+			expected: "incompatible game versions: this game is newer",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upgrader := websocket.Upgrader{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				conn, err := upgrader.Upgrade(w, r, nil)
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+
+				tt.server(conn)
+			}))
+			defer server.Close()
+
+			client, err := NewGameClient(strings.TrimPrefix(server.URL, "http://"), &userinfo.UserInfo{}, "", func(code int, text string) error {
+				return nil
+			})
+			if err == nil {
+				client.Close()
+				t.Fatal("Expected the client to be refused")
+			}
+			if !strings.HasPrefix(err.Error(), tt.expected) {
+				t.Errorf("Expected error %q, got %q", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestRefusedError(t *testing.T) {
+	err := error(&RefusedError{CloseError: &websocket.CloseError{Code: CloseVersionMismatch}})
+
+	if err.Error() != "incompatible game versions" {
+		t.Errorf("Expected the code to be described without a reason, got %q", err)
+	}
+	if _, ok := serverClosed(err); !ok {
+		t.Errorf("Expected the close message to be unwrapped")
+	}
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseVersionMismatch {
+		t.Errorf("Expected close code %d, got %v", CloseVersionMismatch, closeErr)
+	}
+}
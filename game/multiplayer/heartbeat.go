@@ -0,0 +1,193 @@
+// This file contains the heartbeats keeping the connections between the clients and the game server alive.
+// Both sides send a ping every heartbeat interval and drop a connection they have not heard from for the heartbeat
+// timeout, so a half-dead connection is torn down instead of hanging its goroutines. The pongs carry the time
+// their ping was sent, which measures the round-trip time shown to the players with the jitter and the loss of the snapshots.
+package multiplayer
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// DefaultHeartbeatInterval is how often the server and the clients ping each other unless configured otherwise.
+const DefaultHeartbeatInterval = 2 * time.Second
+
+// DefaultHeartbeatTimeout is how long a connection stays silent before it is dropped unless configured otherwise.
+const DefaultHeartbeatTimeout = 10 * time.Second
+
+// lossWindow is the number of recent snapshots the loss of the snapshots is measured over.
+const lossWindow = 100
+
+// ConnectionQuality represents the quality of the connection of a client to the game server.
+type ConnectionQuality struct {
+	RTT    time.Duration // The smoothed round-trip time of the heartbeats, or 0 before it is measured.
+	Jitter time.Duration // The variation of the delay of the snapshots of the running match.
+	Loss   float64       // The fraction of the snapshots dropped on the way to the client, between 0 and 1.
+}
+
+// connectionStats measures the quality of a connection from its heartbeats and the snapshots it receives.
+type connectionStats struct {
+	rtt      time.Duration // The smoothed round-trip time.
+	jitter   time.Duration // The smoothed variation of the transit time of the snapshots.
+	transit  time.Duration // The transit time of the last snapshot of a new tick, relative to its tick.
+	lastTick uint64        // The tick of the last snapshot the transit time was measured for, or 0 before the first one.
+	lastSeq  uint64        // The sequence number of the last snapshot received, or 0 before the first one.
+	received int           // The number of snapshots received recently.
+	missed   int           // The number of snapshots missed recently.
+}
+
+// observeRTT adds a round-trip time measured by a heartbeat.
+func (c *connectionStats) observeRTT(sample time.Duration) {
+	c.rtt = smoothRTT(c.rtt, sample)
+}
+
+// observeSeq adds the sequence number of a snapshot received from the server. The server drops the snapshots
+// of a client whose queue is full, which shows as a gap in the sequence numbers.
+func (c *connectionStats) observeSeq(seq uint64) {
+	if c.lastSeq != 0 && seq > c.lastSeq {
+		c.missed += int(seq - c.lastSeq - 1)
+	}
+	c.lastSeq = seq
+	c.received++
+
+	// halving the counts keeps the loss following the recent snapshots while it stays smooth
+	if c.received+c.missed >= lossWindow {
+		c.received, c.missed = c.received/2, c.missed/2
+	}
+}
+
+// observeTick adds the arrival of the first snapshot of a tick of the running match.
+// The jitter is the one of RFC 3550: how much the delay of consecutive snapshots differs.
+//
+// Parameters:
+//   - tick: The tick of the match the snapshot describes.
+//   - received: The time the snapshot arrived.
+//   - tickDuration: The duration of a tick of the world.
+func (c *connectionStats) observeTick(tick uint64, received time.Time, tickDuration time.Duration) {
+	transit := time.Duration(received.UnixNano()) - time.Duration(tick)*tickDuration
+
+	// a new match starts its ticks over, so its delay is not compared with the previous match
+	if c.lastTick != 0 && tick > c.lastTick {
+		c.jitter += (max(transit-c.transit, c.transit-transit) - c.jitter) / 16
+	}
+	c.transit = transit
+	c.lastTick = tick
+}
+
+// quality returns the measured quality of the connection.
+func (c *connectionStats) quality() ConnectionQuality {
+	quality := ConnectionQuality{RTT: c.rtt, Jitter: c.jitter}
+	if c.received+c.missed > 0 {
+		quality.Loss = float64(c.missed) / float64(c.received+c.missed)
+	}
+
+	return quality
+}
+
+// smoothRTT adds a round-trip time sample to a smoothed round-trip time, or starts it from the sample.
+func smoothRTT(rtt time.Duration, sample time.Duration) time.Duration {
+	if rtt == 0 {
+		return sample
+	}
+
+	return rtt + (sample-rtt)/8
+}
+
+// pingPayload returns the payload of a ping sent at the given time.
+func pingPayload(now time.Time) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(now.UnixNano()))
+}
+
+// pongRTT returns the round-trip time of a pong answering a ping with the payload of pingPayload.
+//
+// Parameters:
+//   - payload: The payload of the pong.
+//   - now: The time the pong arrived.
+//
+// Returns:
+//   - time.Duration: The round-trip time.
+//   - bool: Whether the pong carries the time of a ping sent earlier.
+func pongRTT(payload string, now time.Time) (time.Duration, bool) {
+	if len(payload) != 8 {
+		return 0, false
+	}
+
+	sent := time.Unix(0, int64(binary.BigEndian.Uint64([]byte(payload))))
+	if sent.After(now) {
+		return 0, false
+	}
+
+	return now.Sub(sent), true
+}
+
+// keepAlive drops the connection once nothing arrives through it for the timeout, answers the pings of the other side
+// and reports the round-trip times of the answered pings. The read loop extends the deadline with every message it reads.
+//
+// Parameters:
+//   - conn: The connection to keep alive.
+//   - timeout: How long the connection may stay silent.
+//   - onRTT: Called with the round-trip time of every answered ping, from the read loop.
+func keepAlive(conn *websocket.Conn, timeout time.Duration, onRTT func(time.Duration)) {
+	conn.SetReadDeadline(time.Now().Add(timeout))
+
+	conn.SetPingHandler(func(data string) error {
+		conn.SetReadDeadline(time.Now().Add(timeout))
+
+		err := conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(timeout))
+		if errors.Is(err, websocket.ErrCloseSent) {
+			return nil
+		}
+
+		return err
+	})
+
+	conn.SetPongHandler(func(data string) error {
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		if rtt, ok := pongRTT(data, time.Now()); ok {
+			onRTT(rtt)
+		}
+
+		return nil
+	})
+}
+
+// isTimeout returns whether an error is caused by a connection staying silent for longer than its deadline.
+func isTimeout(err error) bool {
+	var netErr net.Error
+
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// sendPing pings the other side of a connection.
+//
+// Parameters:
+//   - conn: The connection to ping.
+//   - timeout: How long writing the ping may take.
+//
+// Returns:
+//   - error: An error if the ping cannot be written.
+func sendPing(conn *websocket.Conn, timeout time.Duration) error {
+	now := time.Now()
+
+	return conn.WriteControl(websocket.PingMessage, pingPayload(now), now.Add(timeout))
+}
+
+// observePing updates the round-trip time of a client with a heartbeat answered through one of its connections,
+// and shares it with the other players in the ping of its player.
+func (s *GameServer) observePing(client *serverClient, connection *serverConn, rtt time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if client.connection != connection {
+		return
+	}
+
+	client.ping = smoothRTT(client.ping, rtt)
+	if !client.spectator {
+		s.info.Players[client.player].Ping = client.ping
+	}
+}
@@ -0,0 +1,93 @@
+package multiplayer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPongRTT(t *testing.T) {
+	sent := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name    string
+		payload string
+		now     time.Time
+		rtt     time.Duration
+		ok      bool
+	}{
+		{"answered ping", string(pingPayload(sent)), sent.Add(40 * time.Millisecond), 40 * time.Millisecond, true},
+		{"empty payload", "", sent, 0, false},
+		{"foreign payload", "hello", sent, 0, false},
+		{"ping from the future", string(pingPayload(sent)), sent.Add(-time.Second), 0, false},
+	}
+
+	for _, test := range tests {
+		rtt, ok := pongRTT(test.payload, test.now)
+		if rtt != test.rtt || ok != test.ok {
+			t.Errorf("%s: Expected %s, %t, got %s, %t", test.name, test.rtt, test.ok, rtt, ok)
+		}
+	}
+}
+
+func TestSmoothRTT(t *testing.T) {
+	rtt := smoothRTT(0, 80*time.Millisecond)
+	if rtt != 80*time.Millisecond {
+		t.Errorf("Expected the first sample to be taken as is, got %s", rtt)
+	}
+
+	rtt = smoothRTT(rtt, 160*time.Millisecond)
+	if rtt != 90*time.Millisecond {
+		t.Errorf("Expected a spike to move the RTT by an eighth, got %s", rtt)
+	}
+}
+
+func TestConnectionStats_Loss(t *testing.T) {
+	var stats connectionStats
+	for seq := uint64(1); seq <= 20; seq++ {
+		// every fifth snapshot is dropped by the server
+		if seq%5 != 2 {
+			stats.observeSeq(seq)
+		}
+	}
+
+	if loss := stats.quality().Loss; loss < 0.19 || loss > 0.21 {
+		t.Errorf("Expected a loss of 0.2, got %f", loss)
+	}
+
+	for seq := uint64(21); seq <= 20+10*lossWindow; seq++ {
+		stats.observeSeq(seq)
+	}
+
+	if loss := stats.quality().Loss; loss > 0.01 {
+		t.Errorf("Expected the loss to recover, got %f", loss)
+	}
+}
+
+func TestConnectionStats_Jitter(t *testing.T) {
+	tickDuration := time.Second / 60
+	start := time.Unix(1700000000, 0)
+
+	var steady connectionStats
+	for tick := uint64(1); tick <= 100; tick++ {
+		steady.observeTick(tick, start.Add(time.Duration(tick)*tickDuration), tickDuration)
+	}
+	if jitter := steady.quality().Jitter; jitter != 0 {
+		t.Errorf("Expected no jitter for steady snapshots, got %s", jitter)
+	}
+
+	var jittery connectionStats
+	for tick := uint64(1); tick <= 100; tick++ {
+		delay := time.Duration(tick%2) * 20 * time.Millisecond
+		jittery.observeTick(tick, start.Add(time.Duration(tick)*tickDuration+delay), tickDuration)
+	}
+	if jitter := jittery.quality().Jitter; jitter < 15*time.Millisecond || jitter > 20*time.Millisecond {
+		t.Errorf("Expected a jitter close to 20ms, got %s", jitter)
+	}
+
+	// a new match starting its ticks over does not count as jitter
+	jittery.jitter = 0
+	jittery.observeTick(1, start.Add(time.Hour), tickDuration)
+	if jitter := jittery.quality().Jitter; jitter != 0 {
+		t.Errorf("Expected a new match to keep the jitter, got %s", jitter)
+	}
+}
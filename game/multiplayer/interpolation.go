@@ -0,0 +1,125 @@
+// This file contains the interpolation of the remote entities between the snapshots received by a client.
+package multiplayer
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// InterpolationDelay is how far behind the latest snapshot the remote entities are rendered,
+// so there is usually a later snapshot to interpolate towards even if one arrives late.
+const InterpolationDelay = 100 * time.Millisecond
+
+// maxExtrapolation is how long the remote entities keep moving along their last velocity
+// once the interpolation runs out of snapshots, before they stop and wait for the next one.
+const maxExtrapolation = 200 * time.Millisecond
+
+// timelineLength is the number of snapshots a client keeps to interpolate between.
+const timelineLength = 32
+
+// clockSmoothing is the weight of a single snapshot when estimating the clock of the server,
+// so the jitter of the arrival times does not make the remote entities stutter.
+const clockSmoothing = 8
+
+// serverClock estimates the local time at which the server simulated each tick from the arrival times of the snapshots.
+type serverClock struct {
+	tickDuration time.Duration // The duration of a single tick on the server.
+	start        time.Time     // The estimated local time of the first tick of the server.
+	started      bool          // Whether a snapshot has been received yet.
+}
+
+// observe updates the estimate with a snapshot of the given tick received at the given time.
+func (c *serverClock) observe(tick uint64, received time.Time) {
+	start := received.Add(-time.Duration(tick) * c.tickDuration)
+	if !c.started {
+		c.start, c.started = start, true
+		return
+	}
+
+	c.start = c.start.Add(start.Sub(c.start) / clockSmoothing)
+}
+
+// tickAt returns the fractional tick the server was simulating at the given local time.
+func (c *serverClock) tickAt(now time.Time) float64 {
+	if !c.started || c.tickDuration <= 0 {
+		return 0
+	}
+
+	return float64(now.Sub(c.start)) / float64(c.tickDuration)
+}
+
+// interpolateGameInfo returns the latest game state information of the timeline with the remote players
+// and the monsters moved to their positions at the given tick. Between two snapshots the positions are
+// interpolated, after the last one they are extrapolated for a limited number of ticks.
+//
+// Parameters:
+//   - timeline: The received game state information, ordered by their ticks.
+//   - tick: The fractional tick to render the remote entities at.
+//   - extrapolationTicks: The number of ticks the positions are extrapolated for at most.
+//   - local: The index of the player of the client, which is left at its latest position.
+//
+// Returns:
+//   - ProtoGameInfo: The game state information to render.
+func interpolateGameInfo(timeline []ProtoGameInfo, tick, extrapolationTicks float64, local int) ProtoGameInfo {
+	if len(timeline) == 0 {
+		return ProtoGameInfo{}
+	}
+
+	latest := timeline[len(timeline)-1]
+	if len(timeline) == 1 {
+		return latest
+	}
+
+	// find the pair of snapshots around the tick, or the last pair to extrapolate from
+	i := 1
+	for i < len(timeline)-1 && float64(timeline[i].Tick) < tick {
+		i++
+	}
+	from, to := timeline[i-1], timeline[i]
+	if from.Tick >= to.Tick {
+		return latest
+	}
+
+	tick = max(tick, float64(from.Tick))
+	tick = min(tick, float64(to.Tick)+extrapolationTicks)
+	t := (tick - float64(from.Tick)) / float64(to.Tick-from.Tick)
+
+	info := latest
+	info.Players = slices.Clone(latest.Players)
+	for j := range info.Players {
+		if j == local || j >= len(from.Players) || j >= len(to.Players) || to.Players[j].IsDead {
+			continue
+		}
+		info.Players[j].X = lerp(from.Players[j].X, to.Players[j].X, t)
+		info.Players[j].Y = lerp(from.Players[j].Y, to.Players[j].Y, t)
+	}
+
+	info.Monsters = slices.Clone(latest.Monsters)
+	for j, monster := range info.Monsters {
+		a, okA := entityByID(from.Monsters, monster.ID)
+		b, okB := entityByID(to.Monsters, monster.ID)
+		if !okA || !okB {
+			continue
+		}
+		info.Monsters[j].X = lerp(a.X, b.X, t)
+		info.Monsters[j].Y = lerp(a.Y, b.Y, t)
+	}
+
+	return info
+}
+
+// entityByID finds the entity with the given identifier in a list ordered by the identifiers.
+func entityByID(entities []ProtoEntity, id uint64) (ProtoEntity, bool) {
+	i, ok := slices.BinarySearchFunc(entities, id, func(e ProtoEntity, id uint64) int { return cmp.Compare(e.ID, id) })
+	if !ok {
+		return ProtoEntity{}, false
+	}
+
+	return entities[i], true
+}
+
+// lerp returns the value at t between a and b, extrapolating beyond b for t larger than 1.
+func lerp(a, b, t float64) float64 {
+	return a + (b-a)*t
+}
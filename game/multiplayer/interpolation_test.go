@@ -0,0 +1,74 @@
+package multiplayer
+
+import (
+	"testing"
+	"time"
+)
+
+// testTimeline returns snapshots of a remote player and a monster moving right by a pixel every tick.
+func testTimeline(ticks ...uint64) []ProtoGameInfo {
+	timeline := make([]ProtoGameInfo, 0, len(ticks))
+	for _, tick := range ticks {
+		timeline = append(timeline, ProtoGameInfo{
+			Tick:     tick,
+			Players:  []ProtoPlayer{{X: 100}, {X: float64(tick)}},
+			Monsters: []ProtoEntity{{ID: 3, X: float64(tick), Y: 5}},
+		})
+	}
+
+	return timeline
+}
+
+func TestInterpolateGameInfo(t *testing.T) {
+	tests := []struct {
+		name     string
+		tick     float64
+		expected float64
+	}{
+		{"between snapshots", 12.5, 12.5},
+		{"on a snapshot", 14, 14},
+		{"before the timeline", 2, 10},
+		{"extrapolated", 19, 19},
+		{"extrapolation limit", 40, 24},
+	}
+
+	timeline := testTimeline(10, 12, 14, 16)
+	for _, test := range tests {
+		info := interpolateGameInfo(timeline, test.tick, 8, 0)
+
+		if info.Players[1].X != test.expected || info.Monsters[0].X != test.expected {
+			t.Errorf("%s: Expected the remote entities at %f, got %f and %f", test.name, test.expected, info.Players[1].X, info.Monsters[0].X)
+		}
+		if info.Players[0].X != 100 {
+			t.Errorf("%s: Expected the local player to stay at its latest position, got %f", test.name, info.Players[0].X)
+		}
+	}
+
+	if timeline[3].Players[1].X != 16 {
+		t.Error("Expected the timeline to be left untouched")
+	}
+}
+
+func TestInterpolateGameInfo_SingleSnapshot(t *testing.T) {
+	info := interpolateGameInfo(testTimeline(10), 5, 8, 0)
+
+	if info.Tick != 10 || info.Players[1].X != 10 {
+		t.Errorf("Expected the only snapshot, got %+v", info)
+	}
+}
+
+func TestServerClock(t *testing.T) {
+	clock := serverClock{tickDuration: 10 * time.Millisecond}
+	start := time.Now()
+
+	clock.observe(5, start.Add(50*time.Millisecond))
+	if tick := clock.tickAt(start.Add(100 * time.Millisecond)); tick != 10 {
+		t.Errorf("Expected tick 10, got %f", tick)
+	}
+
+	// a late snapshot only moves the estimate a little
+	clock.observe(6, start.Add(140*time.Millisecond))
+	if tick := clock.tickAt(start.Add(100 * time.Millisecond)); tick >= 10 || tick < 9 {
+		t.Errorf("Expected a tick slightly below 10, got %f", tick)
+	}
+}
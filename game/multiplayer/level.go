@@ -0,0 +1,178 @@
+// This file contains the transfer of the level files from the game server to the clients.
+// The server announces the content hash of its level and serves the file by that hash,
+// so the clients play the level of the host even if it is not on their disk, and keep a copy of every level they downloaded.
+package multiplayer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// levelPath is the path of the HTTP route serving the level of the server, followed by its content hash.
+const levelPath = "/levels/"
+
+// maxLevelSize is the largest level file a client downloads.
+const maxLevelSize = 1 << 20
+
+// levelDownloadTimeout is how long downloading a level may take.
+const levelDownloadTimeout = 10 * time.Second
+
+// levelCacheDir is the directory under the user cache directory the downloaded levels are kept in.
+var levelCacheDir = filepath.Join("ninjago", "levels")
+
+// LevelHash returns the content hash of a level file, which identifies the level on the network.
+//
+// Parameters:
+//   - level: The contents of the level file.
+//
+// Returns:
+//   - string: The hexadecimal SHA-256 hash of the contents.
+func LevelHash(level []byte) string {
+	hash := sha256.Sum256(level)
+
+	return hex.EncodeToString(hash[:])
+}
+
+// levelHandler serves the level of the server to the clients by its content hash.
+//
+// Returns:
+//   - gin.HandlerFunc: A Gin handler function serving the level file.
+func (s *GameServer) levelHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s.mu.Lock()
+		level, hash := s.level, s.info.LevelHash
+		s.mu.Unlock()
+
+		if c.Param("hash") != hash {
+			c.Status(http.StatusNotFound)
+
+			return
+		}
+
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", level)
+	}
+}
+
+// Level returns the contents of the level with the given content hash. The level is read from the cache
+// of the downloaded levels, or downloaded from the game server and added to the cache.
+//
+// Parameters:
+//   - hash: The content hash of the level, as announced by the server.
+//
+// Returns:
+//   - []byte: The contents of the level file.
+//   - error: An error if the level is not cached and cannot be downloaded.
+func (gc *GameClient) Level(hash string) ([]byte, error) {
+	// a level requested again while it is being downloaded waits for that download
+	gc.levelMu.Lock()
+	defer gc.levelMu.Unlock()
+
+	if level, ok := gc.levels[hash]; ok {
+		return level, nil
+	}
+
+	level, err := readCachedLevel(hash)
+	if err != nil {
+		if level, err = gc.downloadLevel(hash); err != nil {
+			return nil, err
+		}
+		if err := writeCachedLevel(hash, level); err != nil {
+			log.Println("Failed to cache level", err)
+		}
+	}
+	gc.levels[hash] = level
+
+	return level, nil
+}
+
+// downloadLevel downloads the level with the given content hash from the game server and checks its hash.
+func (gc *GameClient) downloadLevel(hash string) ([]byte, error) {
+	target := url.URL{Scheme: "http", Host: gc.address, Path: path.Join(gc.root, levelPath, hash)}
+	client := http.Client{Timeout: levelDownloadTimeout}
+	if gc.secure {
+		target.Scheme = "https"
+		client.Transport = &http.Transport{TLSClientConfig: clientTLSConfig(gc.address)}
+	}
+
+	response, err := client.Get(target.String())
+	if err != nil {
+		return nil, fmt.Errorf("error downloading level: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error downloading level: %s", response.Status)
+	}
+
+	level, err := io.ReadAll(io.LimitReader(response.Body, maxLevelSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("error downloading level: %w", err)
+	}
+	if len(level) > maxLevelSize {
+		return nil, fmt.Errorf("level is larger than %d bytes", maxLevelSize)
+	}
+	if LevelHash(level) != hash {
+		return nil, fmt.Errorf("downloaded level does not match its hash %s", hash)
+	}
+
+	return level, nil
+}
+
+// cachedLevelPath returns the path of the cached copy of the level with the given content hash.
+func cachedLevelPath(hash string) (string, error) {
+	// the hash names a file, so anything but a hash could escape the cache directory
+	if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != sha256.Size {
+		return "", fmt.Errorf("invalid level hash %q", hash)
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, levelCacheDir, hash+".txt"), nil
+}
+
+// readCachedLevel reads the cached copy of the level with the given content hash.
+// A copy that no longer matches its hash is ignored.
+func readCachedLevel(hash string) ([]byte, error) {
+	path, err := cachedLevelPath(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	level, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if LevelHash(level) != hash {
+		return nil, fmt.Errorf("cached level %s is corrupted", path)
+	}
+
+	return level, nil
+}
+
+// writeCachedLevel adds a downloaded level to the cache.
+func writeCachedLevel(hash string, level []byte) error {
+	path, err := cachedLevelPath(hash)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, level, 0o644)
+}
@@ -0,0 +1,61 @@
+package multiplayer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGameClient_Level(t *testing.T) {
+	cache := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cache)
+	t.Setenv("LocalAppData", cache)
+	t.Setenv("HOME", cache)
+
+	level := []byte("WWW\nW.W\nWWW\n")
+	hash := LevelHash(level)
+
+	var downloads atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads.Add(1)
+		switch r.URL.Path {
+		case levelPath + hash:
+			w.Write(level)
+		case levelPath + LevelHash([]byte("other")):
+			w.Write([]byte("tampered"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	address := strings.TrimPrefix(server.URL, "http://")
+
+	client := &GameClient{address: address, levels: make(map[string][]byte)}
+	for range 2 {
+		got, err := client.Level(hash)
+		if err != nil || string(got) != string(level) {
+			t.Fatalf("Expected the level, got %q and %v", got, err)
+		}
+	}
+	if downloads.Load() != 1 {
+		t.Errorf("Expected a single download, got %d", downloads.Load())
+	}
+
+	// a new client finds the level in the cache
+	client = &GameClient{address: address, levels: make(map[string][]byte)}
+	if got, err := client.Level(hash); err != nil || string(got) != string(level) {
+		t.Errorf("Expected the cached level, got %q and %v", got, err)
+	}
+	if downloads.Load() != 1 {
+		t.Errorf("Expected the level to be read from the cache, got %d downloads", downloads.Load())
+	}
+
+	if _, err := client.Level(LevelHash([]byte("other"))); err == nil {
+		t.Error("Expected a level not matching its hash to be rejected")
+	}
+	if _, err := client.Level("../../secret"); err == nil {
+		t.Error("Expected an invalid hash to be rejected")
+	}
+}
@@ -0,0 +1,198 @@
+// This file contains the management of the lobby by the host: the ready checks, the colors,
+// the player cap and removing players from the server.
+package multiplayer
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// errNotReady is returned when the match is started before every player is ready.
+var errNotReady = errors.New("not every player is ready")
+
+// errUnknownUser is returned when the host manages a user that is not connected to the server.
+var errUnknownUser = errors.New("no such user")
+
+// closeTimeout is how long writing a close message to a removed client may take.
+const closeTimeout = time.Second
+
+// PlayerColors returns the colors the players can pick from: Red, Green, Blue, Yellow, Cyan, Magenta, White.
+func PlayerColors() []color.RGBA {
+	return []color.RGBA{
+		{R: 255, G: 0, B: 0, A: 255},
+		{R: 0, G: 255, B: 0, A: 255},
+		{R: 0, G: 0, B: 255, A: 255},
+		{R: 255, G: 255, B: 0, A: 255},
+		{R: 0, G: 255, B: 255, A: 255},
+		{R: 255, G: 0, B: 255, A: 255},
+		{R: 255, G: 255, B: 255, A: 255}}
+}
+
+// applyLobbyRequest updates the lobby state of the player of a client. The requested color is only
+// granted if no other player has it, in which case the previous color of the player becomes available.
+// Requests arriving after the match started are ignored.
+func (s *GameServer) applyLobbyRequest(client *serverClient, request ProtoLobbyRequest) {
+	if s.info.GameState != GameStateLobby {
+		return
+	}
+
+	player := &s.info.Players[client.player]
+	player.Ready = request.Ready
+
+	if request.Color != player.Color {
+		if i := slices.Index(s.colors, request.Color); i >= 0 {
+			s.colors[i] = player.Color
+			player.Color = request.Color
+		}
+	}
+}
+
+// allReady returns whether there is a player on the server and every player is ready.
+func (s *GameServer) allReady() bool {
+	for client := range s.clients {
+		if !s.info.Players[client.player].Ready {
+			return false
+		}
+	}
+
+	return len(s.clients) > 0
+}
+
+// SetMaxPlayers changes the number of players the server accepts.
+//
+// Parameters:
+//   - maxPlayers: The number of players, at least the number of players already connected.
+//
+// Returns:
+//   - error: An error if the number is out of range.
+func (s *GameServer) SetMaxPlayers(maxPlayers int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if maxPlayers < max(1, len(s.clients)) || maxPlayers > MaxPlayers {
+		return fmt.Errorf("max players must be between %d and %d, got %d", max(1, len(s.clients)), MaxPlayers, maxPlayers)
+	}
+
+	s.config.MaxPlayers = maxPlayers
+	s.info.MaxPlayers = maxPlayers
+
+	return nil
+}
+
+// UserOf returns the user playing the player with the given index.
+//
+// Parameters:
+//   - player: The index of the player in the game information.
+//
+// Returns:
+//   - User: The user of the player.
+//   - bool: Whether a user on the server plays the player.
+func (s *GameServer) UserOf(player int) (User, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for client := range s.clients {
+		if client.player == player {
+			return client.User, true
+		}
+	}
+
+	return User{}, false
+}
+
+// Kick removes a user from the server. The user can join again as a new player.
+//
+// Parameters:
+//   - userID: The identifier of the user.
+//
+// Returns:
+//   - error: An error if the user is not on the server.
+func (s *GameServer) Kick(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.remove(userID, CloseKicked, "kicked by the host")
+}
+
+// Ban removes a player or a spectator from the server and refuses any further connection from its session
+// or its IP address. The IP address of a user connected through the loopback interface is not banned,
+// as the host and every other local client share it.
+//
+// Parameters:
+//   - userID: The identifier of the user.
+//
+// Returns:
+//   - error: An error if the user is not on the server.
+func (s *GameServer) Ban(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for client := range s.recipients() {
+		if client.UserID != userID {
+			continue
+		}
+
+		s.banned[userID] = struct{}{}
+		if ip := net.ParseIP(hostOf(client.IP)); ip != nil && !ip.IsLoopback() {
+			s.banned[hostOf(client.IP)] = struct{}{}
+		}
+	}
+
+	return s.remove(userID, CloseBanned, "banned by the host")
+}
+
+// remove closes the connection of a user with the given close code and reason and drops its session,
+// while holding the lock of the server. The user may be a player or a spectator.
+func (s *GameServer) remove(userID string, code int, reason string) error {
+	for client := range s.recipients() {
+		if client.UserID != userID {
+			continue
+		}
+
+		if client.connection != nil {
+			closeConnection(client.connection.conn, code, reason)
+		}
+		if client.spectator {
+			delete(s.spectators, client)
+		} else {
+			s.unregister(client)
+		}
+
+		return nil
+	}
+
+	return errUnknownUser
+}
+
+// isBanned returns whether a connection resuming the given session from the given address is refused.
+func (s *GameServer) isBanned(session string, address string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, sessionBanned := s.banned[session]
+	_, addressBanned := s.banned[hostOf(address)]
+
+	return (session != "" && sessionBanned) || addressBanned
+}
+
+// closeConnection sends a close message with the given code and reason, then closes the connection.
+func closeConnection(conn *websocket.Conn, code int, reason string) {
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(closeTimeout))
+	conn.Close()
+}
+
+// hostOf returns the host part of an address in host:port form, or the address itself if it has no port.
+func hostOf(address string) string {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+
+	return host
+}
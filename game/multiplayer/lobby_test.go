@@ -0,0 +1,192 @@
+package multiplayer
+
+import (
+	"errors"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/userinfo"
+)
+
+// newAwayClient registers a player whose connection dropped, so removing it closes no connection.
+func newAwayClient(t *testing.T, s *GameServer, userID string) *serverClient {
+	t.Helper()
+
+	client := newTestClient(t, s, userID)
+	client.connection = nil
+
+	return client
+}
+
+func TestApplyLobbyRequest_Color(t *testing.T) {
+	s := newTestServer(t, nil)
+	first, second := newTestClient(t, s, "first"), newTestClient(t, s, "second")
+	firstColor, secondColor := s.info.Players[first.player].Color, s.info.Players[second.player].Color
+
+	s.applyLobbyRequest(first, ProtoLobbyRequest{Color: secondColor})
+	if got := s.info.Players[first.player].Color; got != firstColor {
+		t.Errorf("Expected a taken color to be refused, got %v instead of %v", got, firstColor)
+	}
+	if got := s.info.Players[second.player].Color; got != secondColor {
+		t.Errorf("Expected the second player to keep %v, got %v", secondColor, got)
+	}
+
+	free := s.colors[0]
+	s.applyLobbyRequest(first, ProtoLobbyRequest{Color: free})
+	if got := s.info.Players[first.player].Color; got != free {
+		t.Errorf("Expected a free color to be granted, got %v instead of %v", got, free)
+	}
+	if !slices.Contains(s.colors, firstColor) {
+		t.Errorf("Expected the previous color %v to become available", firstColor)
+	}
+}
+
+func TestGameServer_Tick_StartsWhenAllReady(t *testing.T) {
+	s := newTestServer(t, func(config *ServerConfig) { config.StartPlayers = 2 })
+	first := newTestClient(t, s, "first")
+
+	s.applyLobbyRequest(first, ProtoLobbyRequest{Ready: true, Color: s.info.Players[first.player].Color})
+	s.tick()
+	if s.info.GameState != GameStateLobby {
+		t.Fatalf("Expected the lobby to wait for a second player, got %s", s.info.GameState)
+	}
+
+	second := newTestClient(t, s, "second")
+	s.tick()
+	if s.info.GameState != GameStateLobby {
+		t.Fatalf("Expected the lobby to wait for every player to be ready, got %s", s.info.GameState)
+	}
+
+	s.applyLobbyRequest(second, ProtoLobbyRequest{Ready: true, Color: s.info.Players[second.player].Color})
+	s.tick()
+	if s.info.GameState != GameStateRunning {
+		t.Errorf("Expected the match to start when every player is ready, got %s", s.info.GameState)
+	}
+}
+
+func TestGameServer_Register_Full(t *testing.T) {
+	s := newTestServer(t, func(config *ServerConfig) { config.MaxPlayers = 2 })
+	newTestClient(t, s, "first")
+	newTestClient(t, s, "second")
+
+	client := &serverClient{User: User{UserInfo: userinfo.UserInfo{UserID: "third"}}}
+	if _, err := s.register(client); !errors.Is(err, errServerFull) {
+		t.Errorf("Expected a join over the limit to be refused with %v, got %v", errServerFull, err)
+	}
+	if len(s.info.Players) != 2 || len(s.clients) != 2 {
+		t.Errorf("Expected 2 players, got %d players and %d clients", len(s.info.Players), len(s.clients))
+	}
+}
+
+func TestGameServer_Leave_ShiftsPlayers(t *testing.T) {
+	tests := []struct {
+		name  string
+		leave func(s *GameServer, client *serverClient) error
+	}{
+		{
+			name: "Leaves",
+			leave: func(s *GameServer, client *serverClient) error {
+				s.unregister(client)
+
+				return nil
+			},
+		},
+		{
+			name:  "Kicked",
+			leave: func(s *GameServer, client *serverClient) error { return s.Kick(client.UserID) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, nil)
+			first, second, third := newAwayClient(t, s, "first"), newAwayClient(t, s, "second"), newAwayClient(t, s, "third")
+
+			if err := tt.leave(s, second); err != nil {
+				t.Fatal(err)
+			}
+
+			if first.player != 0 || third.player != 1 {
+				t.Errorf("Expected the players 0 and 1, got %d and %d", first.player, third.player)
+			}
+			if len(s.info.Players) != 2 || s.info.Players[third.player].Username != "third" {
+				t.Errorf("Expected the third player to move to index 1, got %+v", s.info.Players)
+			}
+			if _, ok := s.clients[second]; ok {
+				t.Error("Expected the second client to be removed")
+			}
+		})
+	}
+}
+
+func TestGameServer_Ban(t *testing.T) {
+	tests := []struct {
+		name          string
+		ip            string
+		spectator     bool
+		addressBanned bool
+	}{
+		{name: "Remote player", ip: "192.0.2.1:1234", addressBanned: true},
+		{name: "Local player", ip: "127.0.0.1:1234", addressBanned: false},
+		{name: "Local IPv6 player", ip: "[::1]:1234", addressBanned: false},
+		{name: "Remote spectator", ip: "192.0.2.1:1234", spectator: true, addressBanned: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, nil)
+			client := &serverClient{User: User{UserInfo: userinfo.UserInfo{UserID: "banned"}, IP: tt.ip}}
+			register := s.register
+			if tt.spectator {
+				register = s.registerSpectator
+			}
+			if _, err := register(client); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := s.Ban("banned"); err != nil {
+				t.Fatal(err)
+			}
+
+			if !s.isBanned("banned", "198.51.100.1:1234") {
+				t.Error("Expected the session to be banned")
+			}
+			if got := s.isBanned("", hostOf(tt.ip)+":5678"); got != tt.addressBanned {
+				t.Errorf("Expected the address to be banned: %v, got %v", tt.addressBanned, got)
+			}
+			if len(s.clients) != 0 || len(s.spectators) != 0 {
+				t.Errorf("Expected the user to be removed, got %d players and %d spectators", len(s.clients), len(s.spectators))
+			}
+		})
+	}
+}
+
+func TestGameServer_Ban_RefusesReconnect(t *testing.T) {
+	s := newTestServer(t, nil)
+	server := httptest.NewServer(s.server)
+	defer server.Close()
+	address := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	conn, welcome, _ := joinTestServer(t, address+"/")
+	if conn == nil {
+		t.Fatal("Expected the first connection to be welcomed")
+	}
+	defer conn.Close()
+
+	if err := s.Ban(welcome.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, code := joinTestServer(t, address+"/?"+sessionParameter+"="+welcome.UserID); code != CloseBanned {
+		t.Errorf("Expected the banned session to be refused with %d, got %d", CloseBanned, code)
+	}
+
+	// the test connects through the loopback interface, which is never banned
+	other, _, code := joinTestServer(t, address+"/")
+	if other == nil {
+		t.Fatalf("Expected a new session from the loopback address to be welcomed, got %d", code)
+	}
+	other.Close()
+}
@@ -0,0 +1,186 @@
+// This file contains the metrics of the game server, served at metricsPath in the text format scraped by Prometheus:
+// the connected clients and their round-trip times, the traffic, the duration of the ticks, the size of the snapshots,
+// the matches played and the panics recovered in the connection handlers.
+package multiplayer
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// metricsPath is the path of the HTTP route serving the metrics of the server.
+const metricsPath = "/metrics"
+
+// tickDurationBuckets are the upper bounds of the buckets of the tick duration histogram in seconds.
+var tickDurationBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1}
+
+// snapshotSizeBuckets are the upper bounds of the buckets of the snapshot size histogram in bytes.
+var snapshotSizeBuckets = []float64{64, 128, 256, 512, 1024, 2048, 4096, 8192, 16384}
+
+// serverMetrics represents the metrics collected by the game server.
+// The counters are updated by the connection handlers without holding the lock of the server.
+type serverMetrics struct {
+	bytesSent        atomic.Uint64 // The number of bytes written to the clients.
+	bytesReceived    atomic.Uint64 // The number of bytes read from the clients.
+	messagesSent     atomic.Uint64 // The number of messages written to the clients.
+	messagesReceived atomic.Uint64 // The number of messages read from the clients.
+	matchesPlayed    atomic.Uint64 // The number of matches that ended.
+	panics           atomic.Uint64 // The number of panics recovered in the connection handlers.
+	mu               sync.Mutex    // The lock guarding the histograms.
+	tickDuration     histogram     // How long the ticks of the server took in seconds.
+	snapshotSize     histogram     // The size of the encoded snapshots in bytes.
+}
+
+// newServerMetrics creates the metrics of a new server.
+func newServerMetrics() *serverMetrics {
+	return &serverMetrics{
+		tickDuration: newHistogram(tickDurationBuckets),
+		snapshotSize: newHistogram(snapshotSizeBuckets),
+	}
+}
+
+// countSent counts a message written to a client.
+func (m *serverMetrics) countSent(size int) {
+	m.messagesSent.Add(1)
+	m.bytesSent.Add(uint64(size))
+}
+
+// countReceived counts a message read from a client.
+func (m *serverMetrics) countReceived(size int) {
+	m.messagesReceived.Add(1)
+	m.bytesReceived.Add(uint64(size))
+}
+
+// observeTick records the duration of a tick.
+func (m *serverMetrics) observeTick(duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tickDuration.observe(duration.Seconds())
+}
+
+// observeSnapshot records the size of an encoded snapshot.
+func (m *serverMetrics) observeSnapshot(size int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.snapshotSize.observe(float64(size))
+}
+
+// histogram represents the distribution of the observed values over buckets with fixed upper bounds.
+type histogram struct {
+	bounds []float64 // The upper bounds of the buckets, in increasing order.
+	counts []uint64  // The number of observed values in each bucket, not including the smaller buckets.
+	sum    float64   // The sum of the observed values.
+	count  uint64    // The number of observed values.
+}
+
+// newHistogram creates an empty histogram with the given upper bounds of its buckets.
+func newHistogram(bounds []float64) histogram {
+	return histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+// observe adds a value to the histogram. Values above the last bound are only counted in the total.
+func (h *histogram) observe(value float64) {
+	if i, _ := slices.BinarySearch(h.bounds, value); i < len(h.bounds) {
+		h.counts[i]++
+	}
+	h.sum += value
+	h.count++
+}
+
+// write writes the histogram in the text format with cumulative buckets.
+func (h *histogram) write(w io.Writer, name string, help string) {
+	writeHeader(w, name, help, "histogram")
+
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, formatFloat(bound), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", name, formatFloat(h.sum))
+	fmt.Fprintf(w, "%s_count %d\n", name, h.count)
+}
+
+// writeHeader writes the help and the type of a metric.
+func writeHeader(w io.Writer, name string, help string, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// writeCounter writes a counter without labels.
+func writeCounter(w io.Writer, name string, help string, value uint64) {
+	writeHeader(w, name, help, "counter")
+	fmt.Fprintf(w, "%s %d\n", name, value)
+}
+
+// formatFloat formats a value of a metric.
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// escapeLabel escapes a label value for the text format.
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// metricsHandler serves the metrics of the server in the text format scraped by Prometheus.
+// The round-trip times are labeled with the index and the username of the player, never with the session of the user,
+// which would let anyone reading the metrics take over the player.
+//
+// Returns:
+//   - gin.HandlerFunc: A Gin handler function serving the metrics.
+func (s *GameServer) metricsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var out strings.Builder
+
+		s.mu.Lock()
+		players, spectators := 0, 0
+		for client := range s.recipients() {
+			switch {
+			case client.connection == nil:
+			case client.spectator:
+				spectators++
+			default:
+				players++
+			}
+		}
+		writeHeader(&out, "ninjago_connected_clients", "The number of clients connected to the server.", "gauge")
+		fmt.Fprintf(&out, "ninjago_connected_clients{role=\"player\"} %d\n", players)
+		fmt.Fprintf(&out, "ninjago_connected_clients{role=\"spectator\"} %d\n", spectators)
+
+		writeHeader(&out, "ninjago_client_rtt_seconds", "The smoothed round-trip time of the snapshots to the connected players.", "gauge")
+		for client := range s.clients {
+			if client.connection == nil || client.ping == 0 {
+				continue
+			}
+			fmt.Fprintf(&out, "ninjago_client_rtt_seconds{player=\"%d\",username=\"%s\"} %s\n",
+				client.player, escapeLabel(client.Username), formatFloat(client.ping.Seconds()))
+		}
+		s.mu.Unlock()
+
+		metrics := s.metrics
+		writeCounter(&out, "ninjago_sent_bytes_total", "The number of bytes written to the clients.", metrics.bytesSent.Load())
+		writeCounter(&out, "ninjago_received_bytes_total", "The number of bytes read from the clients.", metrics.bytesReceived.Load())
+		writeCounter(&out, "ninjago_sent_messages_total", "The number of messages written to the clients.", metrics.messagesSent.Load())
+		writeCounter(&out, "ninjago_received_messages_total", "The number of messages read from the clients.", metrics.messagesReceived.Load())
+		writeCounter(&out, "ninjago_matches_played_total", "The number of matches that ended.", metrics.matchesPlayed.Load())
+		writeCounter(&out, "ninjago_recovered_panics_total", "The number of panics recovered in the connection handlers.", metrics.panics.Load())
+
+		metrics.mu.Lock()
+		metrics.tickDuration.write(&out, "ninjago_tick_duration_seconds", "How long the ticks of the server took.")
+		metrics.snapshotSize.write(&out, "ninjago_snapshot_size_bytes", "The size of the encoded snapshots.")
+		metrics.mu.Unlock()
+
+		c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(out.String()))
+	}
+}
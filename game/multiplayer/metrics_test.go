@@ -0,0 +1,60 @@
+package multiplayer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHistogram_Write(t *testing.T) {
+	h := newHistogram([]float64{1, 2.5})
+	for _, value := range []float64{0.5, 1, 2, 3} {
+		h.observe(value)
+	}
+
+	var out strings.Builder
+	h.write(&out, "test_seconds", "A test histogram.")
+
+	expected := `# HELP test_seconds A test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{le="1"} 2
+test_seconds_bucket{le="2.5"} 3
+test_seconds_bucket{le="+Inf"} 4
+test_seconds_sum 6.5
+test_seconds_count 4
+`
+	if out.String() != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, out.String())
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"sensei", "sensei"},
+		{`say "hi"`, `say \"hi\"`},
+		{`back\slash`, `back\\slash`},
+		{"two\nlines", `two\nlines`},
+	}
+
+	for _, tt := range tests {
+		if escaped := escapeLabel(tt.value); escaped != tt.expected {
+			t.Errorf("Expected %q, got %q", tt.expected, escaped)
+		}
+	}
+}
+
+func TestServerMetrics_Counts(t *testing.T) {
+	metrics := newServerMetrics()
+	metrics.countSent(100)
+	metrics.countSent(50)
+	metrics.countReceived(10)
+
+	if metrics.messagesSent.Load() != 2 || metrics.bytesSent.Load() != 150 {
+		t.Errorf("Expected 2 messages and 150 bytes sent, got %d and %d", metrics.messagesSent.Load(), metrics.bytesSent.Load())
+	}
+	if metrics.messagesReceived.Load() != 1 || metrics.bytesReceived.Load() != 10 {
+		t.Errorf("Expected 1 message and 10 bytes received, got %d and %d", metrics.messagesReceived.Load(), metrics.bytesReceived.Load())
+	}
+}
@@ -0,0 +1,89 @@
+// This file contains the private matches: a host protects its server with a password or a generated invite code,
+// which new clients give in their hello. The server checks it before giving the client a player,
+// and refuses wrong credentials with CloseWrongPassword. Resumed sessions are not asked again.
+package multiplayer
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"math/big"
+)
+
+// CloseWrongPassword is the close code of a connection refused because the client gave no password
+// or a wrong one to a private server.
+const CloseWrongPassword = 4001
+
+// MaxPasswordLength is the number of characters a password is cut to.
+const MaxPasswordLength = 64
+
+// inviteCodeLength is the number of characters of a generated invite code.
+const inviteCodeLength = 6
+
+// inviteCodeAlphabet are the characters of the invite codes, leaving out the ones that are easily confused when read aloud.
+const inviteCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// errPasswordRequired is returned when a client joins a private server without a password.
+var errPasswordRequired = errors.New("this game is private, a password or invite code is required")
+
+// errWrongPassword is returned when a client joins a private server with a wrong password.
+var errWrongPassword = errors.New("wrong password or invite code")
+
+// NewInviteCode generates a short random invite code, used as the password of a private server.
+//
+// Returns:
+//   - string: The invite code.
+func NewInviteCode() string {
+	code := make([]byte, inviteCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(inviteCodeAlphabet))))
+		if err != nil {
+			panic(err)
+		}
+		code[i] = inviteCodeAlphabet[n.Int64()]
+	}
+
+	return string(code)
+}
+
+// checkPassword returns an error with a reason readable by the player if a client may not join a server.
+//
+// Parameters:
+//   - expected: The password of the server, or empty if the server is open to everyone.
+//   - given: The password given by the client.
+//
+// Returns:
+//   - error: An error describing why the client is refused, or nil if it may join.
+func checkPassword(expected string, given string) error {
+	if expected == "" {
+		return nil
+	}
+	if given == "" {
+		return errPasswordRequired
+	}
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(given)) != 1 {
+		return errWrongPassword
+	}
+
+	return nil
+}
+
+// SetPassword changes the password new clients have to give to join the server.
+// The clients already on the server stay, and can resume their sessions without it.
+//
+// Parameters:
+//   - password: The password or invite code, cut to MaxPasswordLength characters, or empty to open the server to everyone.
+func (s *GameServer) SetPassword(password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.config.Password = sanitizeText(password, MaxPasswordLength)
+}
+
+// password returns the password new clients have to give to join the server.
+func (s *GameServer) password() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.config.Password
+}
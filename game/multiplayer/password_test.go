@@ -0,0 +1,97 @@
+package multiplayer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/userinfo"
+)
+
+func TestCheckPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		given    string
+		err      error
+	}{
+		{"Open server", "", "", nil},
+		{"Open server with password", "", "secret", nil},
+		{"Right password", "secret", "secret", nil},
+		{"No password", "secret", "", errPasswordRequired},
+		{"Wrong password", "secret", "guess", errWrongPassword},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkPassword(tt.expected, tt.given); err != tt.err {
+				t.Errorf("Expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestNewInviteCode(t *testing.T) {
+	code := NewInviteCode()
+
+	if len(code) != inviteCodeLength {
+		t.Errorf("Expected %d characters, got %q", inviteCodeLength, code)
+	}
+	for _, r := range code {
+		if !strings.ContainsRune(inviteCodeAlphabet, r) {
+			t.Errorf("Expected only characters of the alphabet, got %q", code)
+		}
+	}
+	if other := NewInviteCode(); other == code {
+		t.Errorf("Expected different codes, got %q twice", code)
+	}
+}
+
+func TestGameServer_SetPassword(t *testing.T) {
+	s := &GameServer{}
+
+	s.SetPassword("  secret\n")
+	if password := s.password(); password != "secret" {
+		t.Errorf("Expected the sanitized password, got %q", password)
+	}
+
+	s.SetPassword("")
+	if password := s.password(); password != "" {
+		t.Errorf("Expected an open server, got %q", password)
+	}
+}
+
+func TestGameClient_RefusedPassword(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var hello ProtoHello
+		conn.ReadJSON(&hello)
+		if err := checkPassword("secret", hello.Password); err != nil {
+			closeConnection(conn, CloseWrongPassword, err.Error())
+			return
+		}
+		conn.WriteJSON(ProtoWelcome{Version: ProtocolVersion, UserID: "session", TickRate: DefaultTickRate})
+		conn.ReadMessage()
+	}))
+	defer server.Close()
+	address := strings.TrimPrefix(server.URL, "http://")
+	closeHandler := func(code int, text string) error { return nil }
+
+	if _, err := NewGameClient(address, &userinfo.UserInfo{}, "guess", closeHandler); err == nil || err.Error() != errWrongPassword.Error() {
+		t.Errorf("Expected %q, got %v", errWrongPassword, err)
+	}
+
+	client, err := NewGameClient(address, &userinfo.UserInfo{}, "secret", closeHandler)
+	if err != nil {
+		t.Fatalf("Expected the client to join with the password, got %v", err)
+	}
+	client.Close()
+}
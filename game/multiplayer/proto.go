@@ -7,6 +7,9 @@ import (
 	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/entities"
 )
 
+// ProtocolVersion is the version of the game protocol, changed whenever the messages change incompatibly.
+const ProtocolVersion = 2
+
 // ProtoPlayer represents the player information to be shared across the network.
 type ProtoPlayer struct {
 	Username string                  // The username of the player.
@@ -15,11 +18,14 @@ type ProtoPlayer struct {
 	Control  entities.PlayerControls // The player controls.
 	Color    color.RGBA              // The color associated with the player.
 	IsDead   bool                    // Whether the player is dead.
+	Ready    bool                    // Whether the player is ready to start the match.
 	Score    int                     // The score of the player.
+	InputSeq uint64                  // The sequence number of the input, or in snapshots of the last input the server simulated.
 }
 
 // ProtoEntity represents a generic game entity to be shared across the network.
 type ProtoEntity struct {
+	ID               uint64  // The identifier of the entity, assigned by the server when the entity spawns.
 	X, Y             float64 // The x and y coordinates of the entity.
 	IsDead           bool    // Whether the entity is dead.
 	Type             string  // The type of the entity.
@@ -34,16 +40,102 @@ type ProtoTerrainChange struct {
 
 // ProtoGameInfo represents the overall game state information to be shared across the network.
 type ProtoGameInfo struct {
-	GameState string // The current game state.
-	Level     string // The level of the game.
+	GameState  string // The current game state.
+	Level      string // The level of the game.
+	LevelHash  string // The content hash of the level file, which the clients download the level by.
+	Seed       int64  // The seed of the random source of the match.
+	Tick       uint64 // The tick of the match the information describes.
+	MaxPlayers int    // The number of players the server accepts.
 
 	TerrainChanges []ProtoTerrainChange // The terrain changes in the game.
 	Players        []ProtoPlayer        // The players in the game.
-	Monsters       []ProtoEntity        // The monsters in the game.
+	Monsters       []ProtoEntity        // The monsters in the game, in the order of their identifiers like every list of entities.
 	Bombs          []ProtoEntity        // The bombs in the game.
 	Explosions     []ProtoEntity        // The explosions in the game.
 	Boxes          []ProtoEntity        // The boxes in the game.
 	StatusEffects  []ProtoEntity        // The status effects in the game.
+	Chat           []ProtoChatMessage   // The latest chat messages, oldest first.
+}
+
+// ProtoListChange represents an element of a list that differs from the baseline of a snapshot.
+type ProtoListChange[T comparable] struct {
+	Index int // The index of the element in the list.
+	Value T   // The new value of the element.
+}
+
+// ProtoListDelta represents the changes of a list since the baseline of a snapshot.
+type ProtoListDelta[T comparable] struct {
+	Length  int                  // The length of the list.
+	Changed []ProtoListChange[T] // The elements that differ from the baseline.
+}
+
+// ProtoEntityDelta represents the changes of a list of entities since the baseline of a snapshot.
+// The entities are matched by their identifiers, so removing one never changes the others.
+type ProtoEntityDelta struct {
+	Spawned   []ProtoEntity // The entities spawned since the baseline.
+	Updated   []ProtoEntity // The entities that changed since the baseline.
+	Despawned []uint64      // The identifiers of the entities removed since the baseline.
+}
+
+// ProtoSnapshot represents the game state information of a single tick sent to a client.
+// Unless it is a full snapshot, it only contains the changes since the baseline,
+// the last snapshot the client acknowledged.
+type ProtoSnapshot struct {
+	Seq        uint64 // The sequence number of the snapshot.
+	Baseline   uint64 // The sequence number of the snapshot the changes are relative to, or 0 for a full snapshot.
+	GameState  string // The current game state.
+	Level      string // The level of the game, or empty if it is the one of the baseline.
+	LevelHash  string // The content hash of the level file, which the clients download the level by, or empty if it is the one of the baseline.
+	Seed       int64  // The seed of the random source of the match.
+	Tick       uint64 // The tick of the match the snapshot describes.
+	MaxPlayers int    // The number of players the server accepts.
+	Player     int    // The index of the player of the recipient, which changes when a player before it leaves the lobby.
+
+	TerrainChanges ProtoListDelta[ProtoTerrainChange] // The changes of the terrain changes in the game.
+	Players        ProtoListDelta[ProtoPlayer]        // The changes of the players in the game.
+	Monsters       ProtoEntityDelta                   // The changes of the monsters in the game.
+	Bombs          ProtoEntityDelta                   // The changes of the bombs in the game.
+	Explosions     ProtoEntityDelta                   // The changes of the explosions in the game.
+	Boxes          ProtoEntityDelta                   // The changes of the boxes in the game.
+	StatusEffects  ProtoEntityDelta                   // The changes of the status effects in the game.
+	Chat           []ProtoChatMessage                 // The chat messages sent since the baseline.
+}
+
+// ProtoChatMessage represents a message in the chat of the server.
+type ProtoChatMessage struct {
+	ID     uint64 // The identifier of the message, increasing with every message.
+	From   string // The username of the sender, empty for messages of the server.
+	Text   string // The text of the message.
+	System bool   // Whether the server posted the message about an event, like a player joining.
+}
+
+// ProtoWelcome represents the first message the server sends to a new client.
+type ProtoWelcome struct {
+	Version     int           // The protocol version of the server.
+	Build       string        // The build of the server.
+	Features    []string      // The features supported by the server.
+	UserID      string        // The identifier of the session of the user, used to resume it after the connection dropped.
+	Player      int           // The index of the player of the client in the game information, or -1 for a spectator.
+	Spectator   bool          // Whether the client watches the match without a player.
+	TickRate    int           // The number of ticks the server simulates per second.
+	ResumeGrace time.Duration // How long the server keeps the session after the connection dropped.
+	LevelHash   string        // The content hash of the level file, so the client can fetch the level while it waits in the lobby.
+	Heartbeat   time.Duration // How often the client pings the server.
+	Timeout     time.Duration // How long the connection may stay silent before the client drops it.
+}
+
+// ProtoClientMessage represents a message sent by a client to the server.
+type ProtoClientMessage struct {
+	Ack   uint64             // The sequence number of the last snapshot the client received.
+	Input *ProtoPlayer       // The input of the player, or nil if the message only acknowledges a snapshot.
+	Lobby *ProtoLobbyRequest // The requested lobby state of the player, or nil if it is unchanged.
+	Chat  string             // The chat message of the player, or empty if it sent none.
+}
+
+// ProtoLobbyRequest represents the state a player asks for in the lobby.
+type ProtoLobbyRequest struct {
+	Ready bool       // Whether the player is ready to start the match.
+	Color color.RGBA // The color the player wants, granted if no other player has it.
 }
 
 // Constants representing the possible game states.
@@ -0,0 +1,293 @@
+// This file contains the room manager, which hosts several matches on a single address.
+// Every room is a game server of its own, with its own level, state and players, served under roomsPath
+// followed by the identifier of the room: clients join a room by appending its identifier to the address of the server.
+// The rooms are listed publicly, while creating and closing them is part of the admin API.
+// The other routes of a room, like its own admin API and metrics, are served under its path as well.
+package multiplayer
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// roomsPath is the path the rooms are listed at and served under, followed by their identifier.
+const roomsPath = "/rooms/"
+
+// DefaultMaxRooms is the number of rooms a room manager hosts at once unless configured otherwise.
+const DefaultMaxRooms = 8
+
+// errUnknownRoom is returned when a room that does not exist is closed.
+var errUnknownRoom = errors.New("no such room")
+
+// roomIDPattern matches the valid identifiers of the rooms, which are part of the addresses the clients join.
+var roomIDPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,32}$`)
+
+// RoomManager hosts several rooms on a single address, each running a match of its own.
+type RoomManager struct {
+	mu       sync.Mutex       // The lock guarding the rooms.
+	config   ServerConfig     // The settings the rooms are created with, and the address of the manager.
+	maxRooms int              // The number of rooms hosted at once.
+	rooms    map[string]*room // The hosted rooms, by their identifier.
+	server   *gin.Engine      // The server instance.
+}
+
+// room represents a room hosted by the room manager.
+type room struct {
+	server *GameServer  // The game server running the match of the room.
+	stop   func(string) // Stops the tick loop of the room and closes its connections with the given reason.
+}
+
+// RoomRequest represents the settings of a new room. The settings left empty are taken from the room manager.
+type RoomRequest struct {
+	ID           string // The identifier of the room, or empty to generate one.
+	Level        string // The name of the level file in the level directory of the server, or empty for the level of the manager.
+	MaxPlayers   int    // The number of players the room accepts.
+	StartPlayers int    // The number of connected players starting the match on their own.
+	Password     string // The password or invite code of a private room.
+}
+
+// RoomInfo represents a room in the list of the rooms.
+type RoomInfo struct {
+	ID         string // The identifier of the room.
+	Level      string // The name of the level of the room.
+	GameState  string // The state of the game in the room.
+	Players    int    // The number of players in the room.
+	MaxPlayers int    // The number of players the room accepts.
+	Spectators int    // The number of spectators in the room.
+	Private    bool   // Whether joining the room requires a password or an invite code.
+}
+
+// NewRoomManager creates a new room manager without any rooms.
+//
+// Parameters:
+//   - config: The address of the manager and the default settings of its rooms.
+//   - maxRooms: The number of rooms hosted at once.
+//
+// Returns:
+//   - *RoomManager: The initialized room manager.
+//   - error: An error if the settings are invalid.
+func NewRoomManager(config ServerConfig, maxRooms int) (*RoomManager, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if maxRooms < 1 {
+		return nil, fmt.Errorf("max rooms must be at least 1, got %d", maxRooms)
+	}
+
+	// the rooms share the address of the manager and cannot answer the discovery probes on their own
+	config.Discoverable = false
+
+	manager := RoomManager{
+		config:   config,
+		maxRooms: maxRooms,
+		rooms:    make(map[string]*room),
+		server:   gin.Default(),
+	}
+
+	manager.server.GET(roomsPath, func(c *gin.Context) {
+		c.JSON(http.StatusOK, manager.Rooms())
+	})
+	manager.server.Any(roomsPath+":room/*path", manager.roomHandler())
+	if config.AdminToken != "" {
+		admin := manager.server.Group(adminPath, adminAuth(config.AdminToken))
+		admin.POST("/rooms", func(c *gin.Context) {
+			var request RoomRequest
+			if err := c.ShouldBindJSON(&request); err != nil {
+				adminError(c, http.StatusBadRequest, err)
+
+				return
+			}
+			info, err := manager.CreateRoom(request)
+			if err != nil {
+				adminError(c, http.StatusConflict, err)
+
+				return
+			}
+			c.JSON(http.StatusCreated, info)
+		})
+		admin.DELETE("/rooms/:room", func(c *gin.Context) {
+			if err := manager.CloseRoom(c.Param("room")); err != nil {
+				adminError(c, http.StatusNotFound, err)
+
+				return
+			}
+			c.Status(http.StatusNoContent)
+		})
+	}
+
+	return &manager, nil
+}
+
+// Run starts the room manager listening on its address.
+//
+// Returns:
+//   - func(): A function to stop the manager and close all of its rooms.
+//   - error: An error if the manager cannot listen on its address.
+func (m *RoomManager) Run() (Close func(), err error) {
+	listener, err := listen(m.config)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := &http.Server{Handler: m.server}
+	go func() { log.Println(srv.Serve(listener)) }()
+	log.Println("Room manager is listening on", listener.Addr())
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			srv.Close()
+
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			for id, room := range m.rooms {
+				room.stop("the server was shut down")
+				delete(m.rooms, id)
+			}
+		})
+	}, nil
+}
+
+// CreateRoom creates a new room and starts its tick loop.
+//
+// Parameters:
+//   - request: The settings of the room.
+//
+// Returns:
+//   - RoomInfo: The new room.
+//   - error: An error if the manager hosts as many rooms as it can, the identifier is invalid or taken,
+//     the level is not in the level directory, or the room cannot be created with the settings.
+func (m *RoomManager) CreateRoom(request RoomRequest) (RoomInfo, error) {
+	if request.ID == "" {
+		request.ID = strings.SplitN(uuid.New().String(), "-", 2)[0]
+	}
+	if !roomIDPattern.MatchString(request.ID) {
+		return RoomInfo{}, fmt.Errorf("invalid room identifier %q", request.ID)
+	}
+
+	config := m.config
+	if request.Level != "" {
+		// the level of a room is served to everyone joining it, so it is only read from the level directory
+		level, err := resolveLevel(m.config.LevelDir, request.Level)
+		if err != nil {
+			return RoomInfo{}, err
+		}
+		config.Level = level
+	}
+	if request.MaxPlayers != 0 {
+		config.MaxPlayers = request.MaxPlayers
+		config.StartPlayers = min(config.StartPlayers, config.MaxPlayers)
+	}
+	if request.StartPlayers != 0 {
+		config.StartPlayers = request.StartPlayers
+	}
+	if request.Password != "" {
+		config.Password = request.Password
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.rooms) >= m.maxRooms {
+		return RoomInfo{}, fmt.Errorf("the server hosts at most %d rooms", m.maxRooms)
+	}
+	if _, ok := m.rooms[request.ID]; ok {
+		return RoomInfo{}, fmt.Errorf("room %s already exists", request.ID)
+	}
+
+	server, err := NewGameServer(config)
+	if err != nil {
+		return RoomInfo{}, err
+	}
+	m.rooms[request.ID] = &room{server: server, stop: server.start()}
+	log.Println("Created room", request.ID, "on level", config.Level)
+
+	return server.roomInfo(request.ID), nil
+}
+
+// Rooms returns the rooms hosted by the manager, ordered by their identifier.
+func (m *RoomManager) Rooms() []RoomInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rooms := make([]RoomInfo, 0, len(m.rooms))
+	for id, room := range m.rooms {
+		rooms = append(rooms, room.server.roomInfo(id))
+	}
+	slices.SortFunc(rooms, func(a, b RoomInfo) int { return strings.Compare(a.ID, b.ID) })
+
+	return rooms
+}
+
+// CloseRoom stops the match of a room and disconnects its clients, telling them the room was closed.
+//
+// Parameters:
+//   - id: The identifier of the room.
+//
+// Returns:
+//   - error: An error if there is no such room.
+func (m *RoomManager) CloseRoom(id string) error {
+	m.mu.Lock()
+	room, ok := m.rooms[id]
+	delete(m.rooms, id)
+	m.mu.Unlock()
+
+	if !ok {
+		return errUnknownRoom
+	}
+
+	room.stop("the room was closed")
+	log.Println("Closed room", id)
+
+	return nil
+}
+
+// roomHandler passes the requests under the path of a room to the game server of the room,
+// which sees them as if it was served on its own.
+//
+// Returns:
+//   - gin.HandlerFunc: A Gin handler function routing the requests to the rooms.
+func (m *RoomManager) roomHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m.mu.Lock()
+		room, ok := m.rooms[c.Param("room")]
+		m.mu.Unlock()
+
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": errUnknownRoom.Error()})
+
+			return
+		}
+
+		c.Request.URL.Path = c.Param("path")
+		c.Request.URL.RawPath = ""
+		room.server.server.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// roomInfo returns the room the server runs in the list of the rooms.
+func (s *GameServer) roomInfo(id string) RoomInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return RoomInfo{
+		ID:         id,
+		Level:      strings.TrimSuffix(filepath.Base(s.config.Level), filepath.Ext(s.config.Level)),
+		GameState:  s.info.GameState,
+		Players:    len(s.clients),
+		MaxPlayers: s.config.MaxPlayers,
+		Spectators: len(s.spectators),
+		Private:    s.config.Password != "",
+	}
+}
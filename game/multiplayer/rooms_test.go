@@ -0,0 +1,168 @@
+package multiplayer
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// newTestRoomManager creates a room manager on the test level with the admin token "secret",
+// serves it and closes its rooms at the end of the test. The level directory also holds the level "other".
+//
+// Returns:
+//   - *RoomManager: The room manager.
+//   - string: The URL the manager is served at.
+func newTestRoomManager(t *testing.T, maxRooms int) (*RoomManager, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	config := DefaultServerConfig(writeTestLevel(t))
+	config.AdminToken = "secret"
+	level, err := os.ReadFile(config.Level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(config.LevelDir, "other.txt"), append(level, "BOX 3 3\n"...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	manager, err := NewRoomManager(config, maxRooms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(manager.server)
+	t.Cleanup(func() {
+		for _, room := range manager.Rooms() {
+			manager.CloseRoom(room.ID)
+		}
+		server.Close()
+	})
+
+	return manager, server.URL
+}
+
+func TestRoomManager_RoomHandler(t *testing.T) {
+	manager, address := newTestRoomManager(t, DefaultMaxRooms)
+	if _, err := manager.CreateRoom(RoomRequest{ID: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.CreateRoom(RoomRequest{ID: "second", Level: "other"}); err != nil {
+		t.Fatal(err)
+	}
+	hash := manager.rooms["second"].server.levelHash()
+
+	tests := []struct {
+		name     string
+		path     string
+		expected int
+	}{
+		{"Level of the room", "/rooms/second/levels/" + hash, http.StatusOK},
+		{"Level of another room", "/rooms/first/levels/" + hash, http.StatusNotFound},
+		{"Unknown room", "/rooms/third/levels/" + hash, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := http.Get(address + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer response.Body.Close()
+
+			if response.StatusCode != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, response.StatusCode)
+			}
+			if tt.expected == http.StatusOK {
+				if level, _ := io.ReadAll(response.Body); LevelHash(level) != hash {
+					t.Errorf("Expected the level of the room, got %q", level)
+				}
+			}
+		})
+	}
+
+	conn, _, code := joinTestServer(t, "ws"+strings.TrimPrefix(address, "http")+"/rooms/second/")
+	if conn == nil {
+		t.Fatalf("Expected the room to welcome the client, got %d", code)
+	}
+	defer conn.Close()
+
+	rooms := manager.Rooms()
+	if rooms[0].Players != 0 || rooms[1].Players != 1 {
+		t.Errorf("Expected the client to join the second room, got %+v", rooms)
+	}
+}
+
+func TestRoomManager_CreateRoom(t *testing.T) {
+	manager, _ := newTestRoomManager(t, 2)
+
+	for _, level := range []string{"/etc/passwd", "../level.txt", "missing"} {
+		if _, err := manager.CreateRoom(RoomRequest{Level: level}); err == nil {
+			t.Errorf("Expected a room on the level %q to be refused", level)
+		}
+	}
+
+	for _, id := range []string{"first", "second"} {
+		if _, err := manager.CreateRoom(RoomRequest{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := manager.CreateRoom(RoomRequest{ID: "third"}); err == nil {
+		t.Error("Expected a room over the limit to be refused")
+	}
+	if rooms := manager.Rooms(); len(rooms) != 2 {
+		t.Errorf("Expected 2 rooms, got %d", len(rooms))
+	}
+}
+
+func TestRoomManager_DeleteRoom(t *testing.T) {
+	manager, address := newTestRoomManager(t, DefaultMaxRooms)
+	if _, err := manager.CreateRoom(RoomRequest{ID: "first"}); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, _, code := joinTestServer(t, "ws"+strings.TrimPrefix(address, "http")+"/rooms/first/")
+	if conn == nil {
+		t.Fatalf("Expected the room to welcome the client, got %d", code)
+	}
+	defer conn.Close()
+
+	request, err := http.NewRequest(http.MethodDelete, address+"/admin/rooms/first", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Authorization", "Bearer secret")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected status %d, got %d", http.StatusNoContent, response.StatusCode)
+	}
+
+	// the snapshots queued before the room was closed are read first
+	var closeErr *websocket.CloseError
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if !errors.As(err, &closeErr) {
+				t.Fatal(err)
+			}
+
+			break
+		}
+	}
+	if closeErr.Code != CloseHostLeft {
+		t.Errorf("Expected close code %d, got %d", CloseHostLeft, closeErr.Code)
+	}
+	if rooms := manager.Rooms(); len(rooms) != 0 {
+		t.Errorf("Expected the room to be removed, got %+v", rooms)
+	}
+}
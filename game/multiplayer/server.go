@@ -2,201 +2,957 @@
 package multiplayer
 
 import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
 	"image/color"
 	"log"
 	"math/rand"
+	"net"
 	"net/http"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/collider"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/entities"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/replay"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/sim"
 	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/userinfo"
 )
 
-// ProtoGameInfo represents the game state information sent to the clients.
+// maxQueuedInputs is the number of inputs queued for a client before the oldest ones are dropped,
+// so a client sending faster than the tick rate cannot build up an ever growing input delay.
+const maxQueuedInputs = 8
+
+// errServerFull is returned when a client connects to a server that has no room for another player.
+var errServerFull = errors.New("server is full")
+
+// sendQueueSize is the number of messages queued for a client before new ones are dropped.
+const sendQueueSize = 16
+
+// User represents a user connected to the server.
 type User struct {
-	userinfo.UserInfo              // The user information for the current player.
-	IP                string       // The IP address of the client.
-	Player            *ProtoPlayer // The player information for the current player.
+	userinfo.UserInfo        // The user information for the current player.
+	IP                string // The IP address of the client.
+}
+
+// sessionParameter is the query parameter a client reconnecting to the server passes the identifier of its session in.
+const sessionParameter = "session"
+
+// usernameParameter is the query parameter a client passes the username of its player in.
+const usernameParameter = "username"
+
+// roleParameter is the query parameter a client passes RoleSpectator in to watch the match without a player.
+const roleParameter = "role"
+
+// RoleSpectator is the role of a client watching the match without a player.
+const RoleSpectator = "spectator"
+
+// serverConn represents a connection to a client.
+type serverConn struct {
+	conn      *websocket.Conn // The connection to the client.
+	codec     codec           // The encoding negotiated with the client.
+	send      chan []byte     // The messages waiting to be written to the client.
+	metrics   *serverMetrics  // The metrics the traffic of the connection is counted in.
+	heartbeat time.Duration   // How often the client is pinged.
+	timeout   time.Duration   // How long writing a message may take.
+}
+
+// serverClient represents the session of a client, which outlives its connection for the grace period
+// of the server. It is owned by the tick loop and only accessed while holding the lock of the server.
+type serverClient struct {
+	User                     // The user of the session.
+	connection *serverConn   // The current connection to the client, or nil while it is away.
+	awaySince  time.Time     // The time the connection to the client dropped.
+	player     int           // The index of the player of the client in the game information.
+	inputs     []ProtoPlayer // The inputs received from the client and not yet simulated.
+	lastInput  ProtoPlayer   // The last input simulated for the client.
+	acked      uint64        // The sequence number of the last snapshot the client acknowledged.
+	chat       chatLimiter   // The rate limit of the chat messages of the client.
+	spectator  bool          // Whether the client watches the match without a player.
+	ping       time.Duration // The smoothed round-trip time of the heartbeats to the client.
 }
 
 // GameServer represents the server for the multiplayer game.
+// A single tick loop owns the game state: the connection handlers only queue the inputs they read
+// and write the snapshots queued for them, so no goroutine races on the state of the match.
 type GameServer struct {
-	GameInfo ProtoGameInfo             // The game state information sent to the clients.
-	Colors   []color.RGBA              // The colors available for the players.
-	server   *gin.Engine               // The server instance.
-	clients  map[*websocket.Conn]*User // The clients connected to the server.
+	mu         sync.Mutex                 // The lock guarding every field below.
+	info       ProtoGameInfo              // The game state information sent to the clients.
+	colors     []color.RGBA               // The colors still available for the players.
+	clients    map[*serverClient]struct{} // The clients connected to the server.
+	spectators map[*serverClient]struct{} // The spectators connected to the server, which have no player.
+	recorder   *replay.Recorder           // The recorder simulating the running match, or nil before the match starts.
+	rng        *rand.Rand                 // The random source for spawn positions and colors.
+	config     ServerConfig               // The settings of the server.
+	level      []byte                     // The contents of the level file the matches are played on.
+	seq        uint64                     // The sequence number of the last snapshot.
+	history    snapshotRing               // The recent snapshots the deltas sent to the clients are based on.
+
+	entityIDs    map[collider.Shape]uint64 // The network identifiers of the entities in the world, by their collider.
+	lastEntityID uint64                    // The last network identifier assigned to an entity.
+	lastChatID   uint64                    // The identifier of the last chat message.
+	banned       map[string]struct{}       // The banned sessions and IP addresses.
+	metrics      *serverMetrics            // The metrics of the server, which have their own locks.
+	server       *gin.Engine               // The server instance.
 }
 
 // NewGameServer creates a new instance of GameServer and initializes the server settings and routes.
 //
+// Parameters:
+//   - config: The settings of the server.
+//
 // Returns:
 //   - *GameServer: A pointer to the newly created GameServer instance.
-func NewGameServer() *GameServer {
+//   - error: An error if the settings are invalid or the level cannot be loaded.
+func NewGameServer(config ServerConfig) (*GameServer, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	config.Password = sanitizeText(config.Password, MaxPasswordLength)
+
+	level, err := readLevel(config.Level)
+	if err != nil {
+		return nil, err
+	}
+
 	server := GameServer{
-		server: gin.Default(),
-		GameInfo: ProtoGameInfo{
-			GameState:     GameStateLobby,
-			Players:       make([]ProtoPlayer, 1, 10),
-			Monsters:      make([]ProtoEntity, 0, 10),
-			Bombs:         make([]ProtoEntity, 0, 10),
-			Explosions:    make([]ProtoEntity, 0, 30),
-			Boxes:         make([]ProtoEntity, 0, 10),
-			StatusEffects: make([]ProtoEntity, 0, 10),
-		},
-		// Colors for the players Red, Green, Blue, Yellow, Cyan, Magenta, White
-		Colors: []color.RGBA{
-			{R: 255, G: 0, B: 0, A: 255},
-			{R: 0, G: 255, B: 0, A: 255},
-			{R: 0, G: 0, B: 255, A: 255},
-			{R: 255, G: 255, B: 0, A: 255},
-			{R: 0, G: 255, B: 255, A: 255},
-			{R: 255, G: 0, B: 255, A: 255},
-			{R: 255, G: 255, B: 255, A: 255}},
-		clients: make(map[*websocket.Conn]*User),
+		server:     gin.Default(),
+		clients:    make(map[*serverClient]struct{}),
+		spectators: make(map[*serverClient]struct{}),
+		banned:     make(map[string]struct{}),
+		metrics:    newServerMetrics(),
+		config:     config,
+		level:      level,
 	}
+	server.resetLobby()
 
 	server.server.GET("/", server.websocketHandler())
+	server.server.GET(levelPath+":hash", server.levelHandler())
+	server.server.GET(metricsPath, server.metricsHandler())
+	server.registerAdminRoutes()
+
+	return &server, nil
+}
+
+// resetLobby discards the match and opens a new lobby with a new seed.
+func (s *GameServer) resetLobby() {
+	seed := time.Now().UnixNano()
+	s.info = ProtoGameInfo{
+		GameState:  GameStateLobby,
+		Level:      s.config.Level,
+		LevelHash:  LevelHash(s.level),
+		Seed:       seed,
+		MaxPlayers: s.config.MaxPlayers,
+		Players:    make([]ProtoPlayer, 0, s.config.MaxPlayers),
+	}
+	s.colors = PlayerColors()
+	s.rng = rand.New(rand.NewSource(seed))
+	s.recorder = nil
+}
+
+// readLevel reads a level file and checks that a match can be played on it.
+//
+// Parameters:
+//   - path: The path to the level file.
+//
+// Returns:
+//   - []byte: The contents of the level file.
+//   - error: An error if the file cannot be read, is too large to send to the clients or is not a valid level.
+func readLevel(path string) ([]byte, error) {
+	level, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading level: %w", err)
+	}
+	if len(level) > maxLevelSize {
+		return nil, fmt.Errorf("level %s is larger than %d bytes", path, maxLevelSize)
+	}
+	if _, err := sim.LoadLevel(bytes.NewReader(level), 0); err != nil {
+		return nil, fmt.Errorf("invalid level %s: %w", path, err)
+	}
+
+	return level, nil
+}
+
+// levelExtension is the extension of the level files, which may be left out of the name of a level.
+const levelExtension = ".txt"
+
+// resolveLevel returns the path to a level chosen by name, which has to be a file in the level directory.
+//
+// Parameters:
+//   - dir: The directory the levels are chosen from, or empty if levels cannot be chosen by name.
+//   - name: The path to the level file relative to the directory, with or without its extension.
+//
+// Returns:
+//   - string: The path to the level file.
+//   - error: An error if there is no level directory, or the name is absolute or leads out of the directory.
+func resolveLevel(dir, name string) (string, error) {
+	if dir == "" {
+		return "", fmt.Errorf("levels cannot be chosen on this server")
+	}
+	if !filepath.IsLocal(name) || slices.Contains(strings.Split(filepath.ToSlash(name), "/"), "..") {
+		return "", fmt.Errorf("invalid level name %q", name)
+	}
+	if filepath.Ext(name) == "" {
+		name += levelExtension
+	}
 
-	return &server
+	return filepath.Join(dir, name), nil
 }
 
-// Run starts the game server and listens on port 8080 for incoming connections.
+// Run starts the game server listening on its address and starts the tick loop.
 //
 // Returns:
-//   - func(): A function to close the server and all active client connections.
-func (s *GameServer) Run() (Close func()) {
-	srv := &http.Server{
-		Addr:    ":8080",
-		Handler: s.server,
+//   - func(): A function to stop the server and close all active client connections, telling the clients the host left.
+//   - error: An error if the server cannot listen on its address.
+func (s *GameServer) Run() (Close func(), err error) {
+	listener, err := listen(s.config)
+	if err != nil {
+		return nil, err
 	}
 
-	go func() { log.Println(srv.ListenAndServe()) }()
-	log.Println("Server is listening")
+	srv := &http.Server{Handler: s.server}
+	go func() { log.Println(srv.Serve(listener)) }()
+	log.Println("Server is listening on", listener.Addr())
+
+	// a second server on the same machine cannot be discovered, but can still be joined by its address
+	var discovery net.PacketConn
+	if s.config.Discoverable {
+		discovery, err = net.ListenPacket("udp4", net.JoinHostPort("", strconv.Itoa(DiscoveryPort)))
+		if err != nil {
+			log.Println("Failed to listen for discovery probes", err)
+		} else {
+			go s.serveDiscovery(discovery, listener.Addr().(*net.TCPAddr).Port)
+		}
+	}
+
+	stop := s.start()
+
+	var once sync.Once
 
 	return func() {
-		srv.Close()
+		once.Do(func() {
+			stop("the host left the game")
+			srv.Close()
+			if discovery != nil {
+				discovery.Close()
+			}
+		})
+	}, nil
+}
+
+// start starts the tick loop of the server, which is served by the caller.
+//
+// Returns:
+//   - func(string): A function to stop the tick loop and close all active client connections with CloseHostLeft
+//     and the given reason, called once.
+func (s *GameServer) start() func(reason string) {
+	done := make(chan struct{})
+	go s.tickLoop(done)
+
+	return func(reason string) {
+		close(done)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.closeAll(CloseHostLeft, reason)
+	}
+}
+
+// StartMatch loads the level and starts the match with the players in the lobby.
+//
+// Returns:
+//   - error: An error if the match is already running, a player is not ready or the level cannot be loaded.
+func (s *GameServer) StartMatch() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.startMatch()
+}
+
+// startMatch starts the match while holding the lock of the server.
+func (s *GameServer) startMatch() error {
+	if s.info.GameState != GameStateLobby {
+		return fmt.Errorf("match already started")
+	}
+	if !s.allReady() {
+		return errNotReady
+	}
 
-		for conn := range s.clients {
-			conn.Close()
+	world, err := sim.LoadLevel(bytes.NewReader(s.level), s.info.Seed)
+	if err != nil {
+		return err
+	}
+	s.recorder = replay.NewRecorder(world, s.level)
+
+	for i := range s.info.Players {
+		s.addToWorld(i)
+	}
+
+	s.info.GameState = GameStateRunning
+	s.syncGameInfo()
+
+	return nil
+}
+
+// Replay returns the recording of the match, or nil if the match has not started yet.
+func (s *GameServer) Replay() *replay.Replay {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.recorder == nil {
+		return nil
+	}
+
+	return s.recorder.Replay()
+}
+
+// addToWorld adds the player with the given index to the world of the running match,
+// placing it on a spawn point of the level if there is one left.
+func (s *GameServer) addToWorld(i int) {
+	player := &s.info.Players[i]
+
+	if spawnPoints := s.recorder.World().SpawnPoints(); i < len(spawnPoints) {
+		player.X, player.Y = spawnPoints[i].X, spawnPoints[i].Y
+	}
+
+	s.recorder.AddPlayer(player.X, player.Y, &userinfo.UserInfo{Username: player.Username}, player.Color)
+	if player.IsDead {
+		s.recorder.KillPlayer(sim.PlayerID(i))
+	}
+}
+
+// tickLoop simulates the match at a fixed rate and broadcasts its state until done is closed.
+func (s *GameServer) tickLoop(done <-chan struct{}) {
+	ticker := time.NewTicker(time.Second / time.Duration(s.config.TickRate))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			s.tick()
+		}
+	}
+}
+
+// tick advances the running match by the world ticks of a server tick and broadcasts its state.
+// Once enough players are connected and ready, it starts the match on its own if the server is configured to.
+func (s *GameServer) tick() {
+	start := time.Now()
+	defer func() { s.metrics.observeTick(time.Since(start)) }()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expireSessions()
+
+	if s.info.GameState == GameStateLobby && s.config.StartPlayers > 0 &&
+		len(s.clients) >= min(s.config.StartPlayers, s.config.MaxPlayers) && s.allReady() {
+		if err := s.startMatch(); err != nil {
+			log.Println("Failed to start match", err)
+		}
+	}
+
+	for i := 0; i < s.config.stepsPerTick() && s.info.GameState == GameStateRunning; i++ {
+		s.step()
+	}
+
+	s.broadcast()
+}
+
+// step applies the next queued input of every client and advances the running match by a single world tick.
+func (s *GameServer) step() {
+	inputs := make(map[sim.PlayerID]entities.PlayerControls, len(s.clients))
+	for client := range s.clients {
+		if len(client.inputs) > 0 {
+			client.lastInput = client.inputs[0]
+			client.inputs = client.inputs[1:]
+		} else {
+			// a late input repeats the movement of the last one, but never its actions
+			client.lastInput.Control.Ability1 = false
+			client.lastInput.Control.Ability2 = false
+		}
+
+		s.info.Players[client.player].Control = client.lastInput.Control
+		s.info.Players[client.player].InputSeq = client.lastInput.InputSeq
+		inputs[sim.PlayerID(client.player)] = client.lastInput.Control
+	}
+
+	s.recorder.Step(inputs)
+	s.syncGameInfo()
+
+	s.info.GameState = GameStateEnd
+	for _, player := range s.info.Players {
+		if !player.IsDead {
+			s.info.GameState = GameStateRunning
+			break
+		}
+	}
+	if s.info.GameState == GameStateEnd {
+		s.metrics.matchesPlayed.Add(1)
+	}
+}
+
+// broadcast queues a snapshot of the current game state information for every client.
+// Every client receives the changes since the last snapshot it acknowledged, or a full snapshot
+// if it has not acknowledged any yet or that snapshot is too old.
+// A client whose queue is full misses the update instead of holding up the tick loop.
+func (s *GameServer) broadcast() {
+	s.seq++
+	current := cloneGameInfo(s.info)
+	s.history.put(s.seq, current)
+
+	// clients acknowledging the same snapshot in the same encoding receive the same message
+	type messageKey struct {
+		codec       codec
+		baselineSeq uint64
+		player      int
+	}
+	messages := make(map[messageKey][]byte)
+	for client := range s.recipients() {
+		if client.connection == nil {
+			continue
+		}
+
+		baselineSeq := client.acked
+		baseline, ok := s.history.get(baselineSeq)
+		if !ok {
+			baselineSeq = 0
+		}
+
+		key := messageKey{codec: client.connection.codec, baselineSeq: baselineSeq, player: client.player}
+		message, ok := messages[key]
+		if !ok {
+			snapshot := diffGameInfo(s.seq, baselineSeq, baseline, current)
+			snapshot.Player = client.player
+
+			var err error
+			message, err = client.connection.codec.Marshal(snapshot)
+			if err != nil {
+				// the other clients still receive the snapshot
+				log.Println("Failed to encode snapshot for client", client.UserID, err)
+
+				continue
+			}
+			messages[key] = message
+			s.metrics.observeSnapshot(len(message))
+		}
+
+		select {
+		case client.connection.send <- message:
+		default:
+			log.Println("Dropping update for lagging client", client.UserID)
+		}
+	}
+}
+
+// levelHash returns the content hash of the level of the server.
+func (s *GameServer) levelHash() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.info.LevelHash
+}
+
+// recipients returns the clients the snapshots are sent to: the players and the spectators.
+func (s *GameServer) recipients() map[*serverClient]struct{} {
+	recipients := make(map[*serverClient]struct{}, len(s.clients)+len(s.spectators))
+	for _, clients := range []map[*serverClient]struct{}{s.clients, s.spectators} {
+		for client := range clients {
+			recipients[client] = struct{}{}
+		}
+	}
+
+	return recipients
+}
+
+// syncGameInfo copies the state of the simulated match into the game information shared with the clients.
+func (s *GameServer) syncGameInfo() {
+	world := s.recorder.World()
+	info := &s.info
+	info.Tick = world.Tick()
+
+	for _, player := range world.Players() {
+		if int(player.ID) >= len(info.Players) {
+			continue
+		}
+		info.Players[player.ID].X = player.GetCollider().GetPosition().X
+		info.Players[player.ID].Y = player.GetCollider().GetPosition().Y
+		if player.IsDead && !info.Players[player.ID].IsDead {
+			info.Players[player.ID].IsDead = true
+			s.postChat("", s.deathMessage(player), true)
+		}
+	}
+
+	// entities keep their identifier as long as they stay in the world
+	live := make(map[collider.Shape]uint64, len(s.entityIDs))
+	defer func() { s.entityIDs = live }()
+
+	info.Monsters = info.Monsters[:0]
+	for _, monster := range world.Monsters() {
+		info.Monsters = append(info.Monsters, s.protoEntityOf(monster.GetCollider(), sim.MonsterKind(monster), live))
+	}
+
+	info.Bombs = info.Bombs[:0]
+	for _, bomb := range world.Bombs() {
+		info.Bombs = append(info.Bombs, s.protoEntityOf(bomb.GetCollider(), "", live))
+	}
+
+	info.Explosions = info.Explosions[:0]
+	for _, explosion := range world.Explosions() {
+		info.Explosions = append(info.Explosions, s.protoEntityOf(explosion.GetCollider(), "", live))
+	}
+
+	info.Boxes = info.Boxes[:0]
+	for _, box := range world.Boxes() {
+		info.Boxes = append(info.Boxes, s.protoEntityOf(box.GetCollider(), "", live))
+	}
+
+	info.StatusEffects = info.StatusEffects[:0]
+	for _, effect := range world.Effects() {
+		info.StatusEffects = append(info.StatusEffects, s.protoEntityOf(effect.GetCollider(), effect.StatusEffect.GetName(), live))
+	}
+
+	for _, entities := range [][]ProtoEntity{info.Monsters, info.Bombs, info.Explosions, info.Boxes, info.StatusEffects} {
+		slices.SortFunc(entities, func(a, b ProtoEntity) int { return cmp.Compare(a.ID, b.ID) })
+	}
+
+	for _, change := range world.TerrainChanges()[len(info.TerrainChanges):] {
+		info.TerrainChanges = append(info.TerrainChanges, ProtoTerrainChange{X: change.X, Y: change.Y, To: change.To})
+	}
+}
+
+// protoEntityOf converts an entity into its network representation.
+// Entities seen for the first time get a new identifier.
+//
+// Parameters:
+//   - shape: The collider of the entity to convert.
+//   - kind: The type of the entity shared with the clients.
+//   - live: The identifiers of the entities converted in this tick, which the entity is added to.
+//
+// Returns:
+//   - ProtoEntity: The network representation of the entity.
+func (s *GameServer) protoEntityOf(shape collider.Shape, kind string, live map[collider.Shape]uint64) ProtoEntity {
+	id, ok := s.entityIDs[shape]
+	if !ok {
+		s.lastEntityID++
+		id = s.lastEntityID
+	}
+	live[shape] = id
+
+	return ProtoEntity{ID: id, X: shape.GetPosition().X, Y: shape.GetPosition().Y, Type: kind}
+}
+
+// register adds a new client to the server, giving it a player, a color and a spawn position.
+// Clients joining a running match enter it right away.
+//
+// Returns:
+//   - int: The index of the player of the client when it was registered.
+//   - error: An error if the server is full.
+func (s *GameServer) register(client *serverClient) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.clients) >= s.config.MaxPlayers || len(s.colors) == 0 {
+		return 0, errServerFull
+	}
+
+	s.info.Players = append(s.info.Players, ProtoPlayer{
+		Username: client.Username,
+		X:        float64(s.rng.Intn(14))*16 + 20,
+		Y:        float64(s.rng.Intn(14))*16 + 20,
+	})
+	client.player = len(s.info.Players) - 1
+	player := &s.info.Players[client.player]
+
+	randColor := s.rng.Intn(len(s.colors))
+	player.Color = s.colors[randColor]
+	s.colors[randColor] = s.colors[len(s.colors)-1]
+	s.colors = s.colors[:len(s.colors)-1]
+
+	s.clients[client] = struct{}{}
+	s.postChat("", s.nameOf(client.player)+" joined", true)
+
+	if s.info.GameState == GameStateRunning {
+		s.addToWorld(client.player)
+	}
+
+	return client.player, nil
+}
+
+// registerSpectator adds a new spectator to the server. Spectators do not take a player or a color.
+//
+// Returns:
+//   - int: -1, as the spectator has no player.
+//   - error: An error if the server has no room for another spectator.
+func (s *GameServer) registerSpectator(client *serverClient) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.spectators) >= s.config.MaxSpectators {
+		return 0, errServerFull
+	}
+
+	client.player = -1
+	client.spectator = true
+	s.spectators[client] = struct{}{}
+
+	return client.player, nil
+}
+
+// resume hands the session with the given identifier to a new connection, if the server still keeps it.
+// A previous connection the server has not noticed dropping yet is closed. The client starts over from a full snapshot.
+//
+// Parameters:
+//   - userID: The identifier of the session to resume.
+//   - connection: The new connection to the client.
+//
+// Returns:
+//   - *serverClient: The resumed session, or nil if there is none to resume.
+//   - int: The index of the player of the session when it was resumed.
+func (s *GameServer) resume(userID string, connection *serverConn) (*serverClient, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for client := range s.clients {
+		if client.UserID == userID {
+			if client.connection != nil {
+				client.connection.conn.Close()
+			}
+			client.connection = connection
+			client.acked = 0
+
+			return client, client.player
+		}
+	}
+
+	return nil, 0
+}
+
+// disconnect handles a dropped connection. Its session is kept for the grace period of the server while the
+// match can still be played, with the player standing still, and removed right away otherwise.
+//
+// Parameters:
+//   - client: The session of the connection.
+//   - connection: The dropped connection.
+func (s *GameServer) disconnect(client *serverClient, connection *serverConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	close(connection.send)
+
+	// spectators have nothing to resume
+	if client.spectator {
+		delete(s.spectators, client)
+
+		return
+	}
+
+	if _, ok := s.clients[client]; !ok || client.connection != connection {
+		return
+	}
+	client.connection = nil
+
+	if s.config.ResumeGrace > 0 && s.info.GameState != GameStateEnd {
+		client.awaySince = time.Now()
+		client.inputs = nil
+		client.lastInput.Control = entities.PlayerControls{}
+
+		return
+	}
+
+	s.unregister(client)
+}
+
+// expireSessions removes the sessions whose connection dropped longer than the grace period ago.
+func (s *GameServer) expireSessions() {
+	for client := range s.clients {
+		if client.connection == nil && time.Since(client.awaySince) > s.config.ResumeGrace {
+			log.Println("Session expired", client.UserID)
+			s.unregister(client)
+		}
+	}
+}
+
+// unregister removes a session from the server while holding the lock of the server.
+// Its color becomes available again. A player leaving the lobby is removed from the game information,
+// while the player of a match that started dies and keeps its place, as the world knows it by its index.
+func (s *GameServer) unregister(client *serverClient) {
+	if _, ok := s.clients[client]; !ok {
+		return
+	}
+
+	name := s.nameOf(client.player)
+	s.colors = append(s.colors, s.info.Players[client.player].Color)
+	if s.info.GameState == GameStateLobby {
+		s.info.Players = slices.Delete(s.info.Players, client.player, client.player+1)
+		for other := range s.clients {
+			if other.player > client.player {
+				other.player--
+			}
+		}
+	} else {
+		s.info.Players[client.player].IsDead = true
+		if s.recorder != nil {
+			s.recorder.KillPlayer(sim.PlayerID(client.player))
+		}
+	}
+
+	delete(s.clients, client)
+	s.postChat("", name+" left", true)
+
+	// a server left alone after its match is ready for the next one
+	if len(s.clients) == 0 && s.info.GameState == GameStateEnd {
+		s.resetLobby()
+	}
+}
+
+// receive handles a message received from a client through one of its connections.
+// Messages still arriving through a replaced connection are ignored.
+func (s *GameServer) receive(client *serverClient, connection *serverConn, message ProtoClientMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if client.connection != connection {
+		return
+	}
+
+	if message.Ack > client.acked && message.Ack <= s.seq {
+		client.acked = message.Ack
+	}
+
+	if message.Chat != "" {
+		s.chat(client, message.Chat)
+	}
+
+	// spectators have no player to control
+	if client.spectator {
+		return
+	}
+
+	if message.Input != nil {
+		s.queueInput(client, *message.Input)
+	}
+
+	if message.Lobby != nil {
+		s.applyLobbyRequest(client, *message.Lobby)
+	}
+}
+
+// queueInput queues an input received from a client for the next ticks.
+func (s *GameServer) queueInput(client *serverClient, message ProtoPlayer) {
+	// clients announcing their username in the handshake keep it, older ones name their player with the inputs
+	if client.Username == "" {
+		client.Username = sanitizeText(message.Username, MaxUsernameLength)
+		s.info.Players[client.player].Username = client.Username
+	}
+
+	// a server tick consumes several inputs at once when it simulates several world ticks
+	limit := s.config.stepsPerTick() + maxQueuedInputs
+	client.inputs = append(client.inputs, message)
+	if len(client.inputs) > limit {
+		client.inputs = client.inputs[len(client.inputs)-limit:]
+	}
+}
+
+// writeLoop writes the messages queued for a client and pings it every heartbeat interval until its queue is closed.
+// A write taking longer than the heartbeat timeout closes the connection.
+func (c *serverConn) writeLoop() {
+	ticker := time.NewTicker(c.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		var err error
+		select {
+		case message, ok := <-c.send:
+			if !ok {
+				return
+			}
+			c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+			if err = c.conn.WriteMessage(c.codec.MessageType(), message); err == nil {
+				c.metrics.countSent(len(message))
+			}
+		case <-ticker.C:
+			err = sendPing(c.conn, c.timeout)
+		}
+
+		if err != nil {
+			log.Println("Failed to write message to client", err)
+			c.conn.Close()
+
+			// keep draining the queue, so the tick loop is never blocked by a dead client
+			for range c.send {
+			}
+
+			return
 		}
 	}
 }
 
 // websocketHandler handles the WebSocket connections from clients.
+// Every connection opens with the hello of the client, and clients speaking another protocol version are refused.
+// Every connection then reads the inputs and acknowledgements of its client
+// while a separate goroutine writes the queued snapshots and the heartbeats.
+// A client not heard from for the heartbeat timeout is disconnected, and may resume its session within the grace period.
+// A client passing the identifier of a session kept by the server resumes it, otherwise it starts a new one
+// if it gave the password of a private server.
+// A client connecting as a spectator only receives the snapshots and never gets a player.
+// A panic while handling a connection only drops that connection, and is counted in the metrics.
 //
 // Returns:
 //   - gin.HandlerFunc: A Gin handler function to manage WebSocket connections.
 func (s *GameServer) websocketHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		upgrader := websocket.Upgrader{}
-		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 		defer func() {
-			if client, exists := s.clients[conn]; exists && client != nil && client.Player != nil {
-				client.Player.IsDead = true
-				s.Colors = append(s.Colors, client.Player.Color)
+			if r := recover(); r != nil {
+				s.metrics.panics.Add(1)
+				log.Println("Recovered from panic in connection handler", r, string(debug.Stack()))
 			}
-
-			conn.Close()
-			delete(s.clients, conn)
 		}()
 
+		upgrader := websocket.Upgrader{Subprotocols: []string{SubprotocolBinary, SubprotocolJSON}}
+		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 		if err != nil {
 			log.Println("Failed to upgrade connection", err)
 			c.Writer.WriteHeader(http.StatusInternalServerError)
 
 			return
 		}
+		defer conn.Close()
 
-		s.clients[conn] = &User{UserInfo: userinfo.UserInfo{UserID: uuid.New().String()}}
+		connection := &serverConn{
+			conn:      conn,
+			codec:     codecFor(conn.Subprotocol()),
+			send:      make(chan []byte, sendQueueSize),
+			metrics:   s.metrics,
+			heartbeat: s.config.HeartbeatInterval,
+			timeout:   s.config.HeartbeatTimeout,
+		}
 
-		err = conn.WriteJSON(s.clients[conn].UserInfo)
-		if err != nil {
-			log.Println("Failed to send UUID", err)
-			c.Writer.WriteHeader(http.StatusInternalServerError)
+		var hello ProtoHello
+		conn.SetReadDeadline(time.Now().Add(helloTimeout))
+		if err := conn.ReadJSON(&hello); err != nil {
+			log.Println("Failed to read hello", err)
 
 			return
 		}
+		conn.SetReadDeadline(time.Time{})
+		if err := checkVersion(ProtocolVersion, hello.Version); err != nil {
+			log.Println("Rejecting client", c.Request.RemoteAddr, "of build", hello.Build, err)
+			closeConnection(conn, CloseVersionMismatch, err.Error())
 
-		s.clients[conn].IP = c.Request.RemoteAddr
-		s.GameInfo.Players = append(s.GameInfo.Players, ProtoPlayer{
-			X: float64(rand.Intn(14))*16 + 20,
-			Y: float64(rand.Intn(14))*16 + 20,
-		})
-		s.clients[conn].Player = &s.GameInfo.Players[len(s.GameInfo.Players)-1]
+			return
+		}
+
+		session := c.Query(sessionParameter)
+		if s.isBanned(session, c.Request.RemoteAddr) {
+			log.Println("Rejecting banned client", c.Request.RemoteAddr)
+			closeConnection(conn, CloseBanned, "banned from the server")
 
-		log.Println(s.clients[conn].Player, &s.GameInfo.Players[len(s.GameInfo.Players)-1])
-		randColor := rand.Intn(len(s.Colors))
-		s.clients[conn].Player.Color = s.Colors[randColor]
-		s.Colors[randColor] = s.Colors[len(s.Colors)-1]
-		s.Colors = s.Colors[:len(s.Colors)-1]
+			return
+		}
 
-		var receivedMessage ProtoPlayer
+		spectator := c.Query(roleParameter) == RoleSpectator
 
-		// Add panic recovery to handle websocket library panics
-		defer func() {
-			if r := recover(); r != nil {
-				log.Printf("Recovered from panic in websocket handler: %v", r)
+		var client *serverClient
+		var player int
+		if session != "" && !spectator {
+			if client, player = s.resume(session, connection); client != nil {
+				log.Println("Resumed session", client.UserID)
 			}
-		}()
+		}
+		if client == nil {
+			// only new sessions need the password, so a kept session is resumed even after the password changed
+			if err := checkPassword(s.password(), sanitizeText(hello.Password, MaxPasswordLength)); err != nil {
+				log.Println("Rejecting client", c.Request.RemoteAddr, err)
+				closeConnection(conn, CloseWrongPassword, err.Error())
 
-		for {
-			comStart := time.Now()
-
-			// Use a separate function to handle the read operation with panic recovery
-			err = func() (readErr error) {
-				defer func() {
-					if r := recover(); r != nil {
-						log.Printf("Recovered from websocket read panic: %v", r)
-						readErr = &websocket.CloseError{Code: websocket.CloseAbnormalClosure, Text: "Connection failed"}
-					}
-				}()
-				return conn.ReadJSON(&receivedMessage)
-			}()
+				return
+			}
 
-			if err != nil {
-				log.Println("Failed to read from connection", err.Error())
-				// If connection is closed or failed, exit the loop
-				if websocket.IsCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) ||
-					websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-					log.Println("Connection closed, stopping server handler")
-					return
-				}
-				// For other errors, wait a bit and try again
-				time.Sleep(time.Millisecond * 100)
-				continue
+			client = &serverClient{
+				User: User{
+					UserInfo: userinfo.UserInfo{
+						UserID:   uuid.New().String(),
+						Username: sanitizeText(c.Query(usernameParameter), MaxUsernameLength),
+					},
+					IP: c.Request.RemoteAddr,
+				},
+				connection: connection,
+			}
+			register := s.register
+			if spectator {
+				register = s.registerSpectator
 			}
+			if player, err = register(client); err != nil {
+				log.Println("Rejecting client", client.IP, err)
+				closeConnection(conn, CloseServerFull, err.Error())
 
-			s.clients[conn].Username = receivedMessage.Username
-			s.clients[conn].Player.Username = receivedMessage.Username
-			s.clients[conn].Player.Control = receivedMessage.Control
+				return
+			}
+		}
+		defer s.disconnect(client, connection)
 
-			// Use a separate function to handle the write operation with panic recovery
-			err = func() (writeErr error) {
-				defer func() {
-					if r := recover(); r != nil {
-						log.Printf("Recovered from websocket write panic: %v", r)
-						writeErr = &websocket.CloseError{Code: websocket.CloseAbnormalClosure, Text: "Connection failed"}
-					}
-				}()
-				return conn.WriteJSON(s.GameInfo)
-			}()
+		// the welcome is written before the write loop starts, so it is the first message the client reads.
+		// It carries the index of the player read while registering, as players leaving the lobby shift the index of the client.
+		welcome := ProtoWelcome{
+			Version:     ProtocolVersion,
+			Build:       Build,
+			Features:    s.features(),
+			UserID:      client.UserID,
+			Player:      player,
+			Spectator:   spectator,
+			TickRate:    s.config.TickRate,
+			ResumeGrace: s.config.ResumeGrace,
+			LevelHash:   s.levelHash(),
+			Heartbeat:   s.config.HeartbeatInterval,
+			Timeout:     s.config.HeartbeatTimeout,
+		}
+		if err := conn.WriteJSON(welcome); err != nil {
+			log.Println("Failed to send welcome", err)
 
+			return
+		}
+		keepAlive(conn, s.config.HeartbeatTimeout, func(rtt time.Duration) {
+			s.observePing(client, connection, rtt)
+		})
+		go connection.writeLoop()
+
+		for {
+			_, data, err := conn.ReadMessage()
+			if isTimeout(err) {
+				log.Println("Connection to client", client.UserID, "timed out")
+
+				return
+			}
 			if err != nil {
-				log.Println("Failed to write message to client", err)
-				// If we can't write, the connection is likely closed
-				if websocket.IsCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) ||
-					websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-					log.Println("Connection closed, stopping server handler")
-					return
-				}
+				log.Println("Failed to read from connection", err.Error())
+
+				return
 			}
+			conn.SetReadDeadline(time.Now().Add(s.config.HeartbeatTimeout))
+			s.metrics.countReceived(len(data))
 
-			s.clients[conn].Player.Ping = time.Since(comStart)
+			var receivedMessage ProtoClientMessage
+			if err := connection.codec.Unmarshal(data, &receivedMessage); err != nil {
+				log.Println("Failed to decode message from client", err)
+
+				return
+			}
 
-			// Add a small delay to prevent overwhelming the connection
-			time.Sleep(time.Millisecond * 16) // ~60 FPS
+			s.receive(client, connection, receivedMessage)
 		}
 	}
 }
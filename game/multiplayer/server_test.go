@@ -0,0 +1,216 @@
+package multiplayer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/entities"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/userinfo"
+)
+
+// writeTestLevel writes an open level surrounded by walls, with two spawn points and no monsters,
+// and returns its path.
+func writeTestLevel(t *testing.T) string {
+	t.Helper()
+
+	var level strings.Builder
+	for row := range 17 {
+		tiles := make([]string, 17)
+		for col := range tiles {
+			tiles[col] = "GRASS"
+			if row == 0 || row == 16 || col == 0 || col == 16 {
+				tiles[col] = "SOLID"
+			}
+		}
+		level.WriteString(strings.Join(tiles, " ") + "\n")
+	}
+	level.WriteString("PLAYER 1 1\nPLAYER 15 15\n")
+
+	path := filepath.Join(t.TempDir(), "level.txt")
+	if err := os.WriteFile(path, []byte(level.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// newTestServer creates a server on the test level which is not advertised on the local network.
+// The configuration can be adjusted before the server is created.
+func newTestServer(t *testing.T, configure func(config *ServerConfig)) *GameServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	config := DefaultServerConfig(writeTestLevel(t))
+	config.Discoverable = false
+	if configure != nil {
+		configure(&config)
+	}
+
+	s, err := NewGameServer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+// newTestClient registers a player whose snapshots are queued without a connection to write them to.
+func newTestClient(t *testing.T, s *GameServer, userID string) *serverClient {
+	t.Helper()
+
+	client := &serverClient{
+		User:       User{UserInfo: userinfo.UserInfo{Username: userID, UserID: userID}, IP: "192.0.2.1:1234"},
+		connection: &serverConn{codec: jsonCodec{}, send: make(chan []byte, sendQueueSize)},
+	}
+	if _, err := s.register(client); err != nil {
+		t.Fatal(err)
+	}
+
+	return client
+}
+
+// lastSnapshot returns the game state information of the last snapshot queued for a client.
+func lastSnapshot(t *testing.T, client *serverClient) ProtoGameInfo {
+	t.Helper()
+
+	var message []byte
+	for len(client.connection.send) > 0 {
+		message = <-client.connection.send
+	}
+	if message == nil {
+		t.Fatal("Expected a snapshot to be queued")
+	}
+
+	var snapshot ProtoSnapshot
+	if err := client.connection.codec.Unmarshal(message, &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Baseline != 0 {
+		t.Fatalf("Expected a full snapshot, got one relative to %d", snapshot.Baseline)
+	}
+
+	return applySnapshot(ProtoGameInfo{}, snapshot)
+}
+
+// joinTestServer opens a connection to a game server and sends the hello of this build.
+//
+// Returns:
+//   - *websocket.Conn: The connection, or nil if the server refused it.
+//   - ProtoWelcome: The welcome of the server.
+//   - int: The close code the server refused the connection with, or 0 if it was welcomed.
+func joinTestServer(t *testing.T, address string) (*websocket.Conn, ProtoWelcome, int) {
+	t.Helper()
+
+	conn, _, err := websocket.DefaultDialer.Dial(address, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WriteJSON(newHello("")); err != nil {
+		t.Fatal(err)
+	}
+
+	var welcome ProtoWelcome
+	if err := conn.ReadJSON(&welcome); err != nil {
+		conn.Close()
+		var closeErr *websocket.CloseError
+		if !errors.As(err, &closeErr) {
+			t.Fatal(err)
+		}
+
+		return nil, welcome, closeErr.Code
+	}
+
+	return conn, welcome, 0
+}
+
+func TestGameServer_Tick(t *testing.T) {
+	s := newTestServer(t, nil)
+	first, second := newTestClient(t, s, "first"), newTestClient(t, s, "second")
+	for _, client := range []*serverClient{first, second} {
+		s.applyLobbyRequest(client, ProtoLobbyRequest{Ready: true, Color: s.info.Players[client.player].Color})
+	}
+	if err := s.StartMatch(); err != nil {
+		t.Fatal(err)
+	}
+
+	world := s.recorder.World()
+	world.Players()[first.player].NumberOfBombs = 2
+	startX, startY := s.info.Players[first.player].X, s.info.Players[first.player].Y
+
+	s.queueInput(first, ProtoPlayer{InputSeq: 1, Control: entities.PlayerControls{Right: true, Ability1: true}})
+	s.tick()
+
+	info := lastSnapshot(t, first)
+	for _, player := range world.Players() {
+		x, y := player.GetPosition()
+		got := info.Players[player.ID]
+		if got.X != x || got.Y != y {
+			t.Errorf("Expected player %d at (%v, %v) in the broadcast, got (%v, %v)", player.ID, x, y, got.X, got.Y)
+		}
+	}
+	if got := info.Players[first.player]; got.X <= startX || got.Y != startY {
+		t.Errorf("Expected the first player to move right from (%v, %v), got (%v, %v)", startX, startY, got.X, got.Y)
+	}
+	if info.Players[first.player].InputSeq != 1 {
+		t.Errorf("Expected the input 1 to be acknowledged, got %d", info.Players[first.player].InputSeq)
+	}
+	if len(info.Bombs) != 1 {
+		t.Fatalf("Expected 1 bomb after the input, got %d", len(info.Bombs))
+	}
+
+	// no input arrives in time for the next tick
+	movedX := info.Players[first.player].X
+	s.tick()
+
+	info = lastSnapshot(t, first)
+	if info.Players[first.player].X <= movedX {
+		t.Errorf("Expected a late input to repeat the movement from %v, got %v", movedX, info.Players[first.player].X)
+	}
+	if len(info.Bombs) != 1 {
+		t.Errorf("Expected a late input not to place another bomb, got %d bombs", len(info.Bombs))
+	}
+	if control := info.Players[first.player].Control; !control.Right || control.Ability1 {
+		t.Errorf("Expected the repeated input to only move right, got %+v", control)
+	}
+	if spawn, got := world.SpawnPoints()[second.player], info.Players[second.player]; got.X != spawn.X || got.Y != spawn.Y {
+		t.Errorf("Expected the second player to stay at its spawn point (%v, %v), got (%v, %v)", spawn.X, spawn.Y, got.X, got.Y)
+	}
+}
+
+func TestResolveLevel(t *testing.T) {
+	dir := filepath.Join("assets", "levels")
+
+	tests := []struct {
+		name     string
+		dir      string
+		level    string
+		expected string
+		valid    bool
+	}{
+		{name: "File name", dir: dir, level: "level2.txt", expected: filepath.Join(dir, "level2.txt"), valid: true},
+		{name: "Without extension", dir: dir, level: "level2", expected: filepath.Join(dir, "level2.txt"), valid: true},
+		{name: "Subdirectory", dir: dir, level: "custom/arena", expected: filepath.Join(dir, "custom", "arena.txt"), valid: true},
+		{name: "Absolute path", dir: dir, level: "/etc/passwd"},
+		{name: "Parent directory", dir: dir, level: "../../go.mod"},
+		{name: "Parent directory inside", dir: dir, level: "custom/../level2.txt"},
+		{name: "Empty name", dir: dir, level: ""},
+		{name: "No level directory", dir: "", level: "level2.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveLevel(tt.dir, tt.level)
+			if (err == nil) != tt.valid {
+				t.Fatalf("Expected valid: %v, got %v", tt.valid, err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
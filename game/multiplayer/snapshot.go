@@ -0,0 +1,275 @@
+// This file contains the delta compression of the game state snapshots sent to the clients.
+package multiplayer
+
+import (
+	"cmp"
+	"slices"
+)
+
+// snapshotHistory is the number of snapshots kept as baselines for the deltas.
+// A client whose last acknowledged snapshot is older than that receives a full snapshot.
+const snapshotHistory = 64
+
+// chatHistory is the number of the latest chat messages kept in the game state information.
+const chatHistory = 32
+
+// maxListLength limits the length of the lists in a snapshot, so a malformed message cannot exhaust memory.
+const maxListLength = 1 << 16
+
+// snapshotRing stores the most recent snapshots by their sequence number.
+type snapshotRing struct {
+	seqs  [snapshotHistory]uint64        // The sequence numbers of the stored snapshots.
+	infos [snapshotHistory]ProtoGameInfo // The stored snapshots.
+}
+
+// put stores the snapshot with the given sequence number, replacing the oldest one.
+// The snapshot must not be modified afterwards.
+func (r *snapshotRing) put(seq uint64, info ProtoGameInfo) {
+	r.seqs[seq%snapshotHistory] = seq
+	r.infos[seq%snapshotHistory] = info
+}
+
+// get returns the snapshot with the given sequence number.
+//
+// Returns:
+//   - ProtoGameInfo: The stored snapshot.
+//   - bool: Whether the snapshot is still stored.
+func (r *snapshotRing) get(seq uint64) (ProtoGameInfo, bool) {
+	if seq == 0 || r.seqs[seq%snapshotHistory] != seq {
+		return ProtoGameInfo{}, false
+	}
+
+	return r.infos[seq%snapshotHistory], true
+}
+
+// cloneGameInfo returns a copy of the game state information that shares no slices with the original.
+func cloneGameInfo(info ProtoGameInfo) ProtoGameInfo {
+	info.TerrainChanges = slices.Clone(info.TerrainChanges)
+	info.Players = slices.Clone(info.Players)
+	info.Monsters = slices.Clone(info.Monsters)
+	info.Bombs = slices.Clone(info.Bombs)
+	info.Explosions = slices.Clone(info.Explosions)
+	info.Boxes = slices.Clone(info.Boxes)
+	info.StatusEffects = slices.Clone(info.StatusEffects)
+	info.Chat = slices.Clone(info.Chat)
+
+	return info
+}
+
+// diffList returns the changes turning the baseline list into the current one.
+func diffList[T comparable](baseline, current []T) ProtoListDelta[T] {
+	delta := ProtoListDelta[T]{Length: len(current)}
+	for i, value := range current {
+		if i >= len(baseline) || baseline[i] != value {
+			delta.Changed = append(delta.Changed, ProtoListChange[T]{Index: i, Value: value})
+		}
+	}
+
+	return delta
+}
+
+// applyList returns a new list with the changes applied to the baseline list.
+// Changes outside of the list are ignored.
+func applyList[T comparable](baseline []T, delta ProtoListDelta[T]) []T {
+	if delta.Length < 0 || delta.Length > maxListLength {
+		return nil
+	}
+
+	list := make([]T, delta.Length)
+	copy(list, baseline)
+	for _, change := range delta.Changed {
+		if change.Index >= 0 && change.Index < len(list) {
+			list[change.Index] = change.Value
+		}
+	}
+
+	return list
+}
+
+// diffEntities returns the spawns, updates and despawns turning the baseline entities into the current ones.
+// Both lists are in the order of the identifiers of their entities.
+func diffEntities(baseline, current []ProtoEntity) ProtoEntityDelta {
+	var delta ProtoEntityDelta
+
+	j := 0
+	for _, entity := range current {
+		for ; j < len(baseline) && baseline[j].ID < entity.ID; j++ {
+			delta.Despawned = append(delta.Despawned, baseline[j].ID)
+		}
+
+		switch {
+		case j < len(baseline) && baseline[j].ID == entity.ID:
+			if baseline[j] != entity {
+				delta.Updated = append(delta.Updated, entity)
+			}
+			j++
+		default:
+			delta.Spawned = append(delta.Spawned, entity)
+		}
+	}
+
+	for ; j < len(baseline); j++ {
+		delta.Despawned = append(delta.Despawned, baseline[j].ID)
+	}
+
+	return delta
+}
+
+// applyEntities returns a new list of entities with the spawns, updates and despawns applied to the baseline,
+// in the order of the identifiers of the entities. Updates of unknown entities are ignored.
+func applyEntities(baseline []ProtoEntity, delta ProtoEntityDelta) []ProtoEntity {
+	byID := make(map[uint64]ProtoEntity, len(baseline)+len(delta.Spawned))
+	for _, entity := range baseline {
+		byID[entity.ID] = entity
+	}
+	for _, id := range delta.Despawned {
+		delete(byID, id)
+	}
+	for _, entity := range delta.Updated {
+		if _, ok := byID[entity.ID]; ok {
+			byID[entity.ID] = entity
+		}
+	}
+	for _, entity := range delta.Spawned {
+		if len(byID) >= maxListLength {
+			break
+		}
+		byID[entity.ID] = entity
+	}
+
+	list := make([]ProtoEntity, 0, len(byID))
+	for _, entity := range byID {
+		list = append(list, entity)
+	}
+	slices.SortFunc(list, func(a, b ProtoEntity) int { return cmp.Compare(a.ID, b.ID) })
+
+	return list
+}
+
+// diffChat returns the chat messages of the current list the baseline does not have yet.
+// The chat only grows at its end, so these are the messages newer than the last one of the baseline.
+func diffChat(baseline, current []ProtoChatMessage) []ProtoChatMessage {
+	var last uint64
+	if len(baseline) > 0 {
+		last = baseline[len(baseline)-1].ID
+	}
+
+	i := len(current)
+	for i > 0 && current[i-1].ID > last {
+		i--
+	}
+	if i == len(current) {
+		return nil
+	}
+
+	return slices.Clone(current[i:])
+}
+
+// applyChat appends the new chat messages to the baseline, keeping the latest chatHistory messages.
+// The baseline is left untouched.
+func applyChat(baseline, messages []ProtoChatMessage) []ProtoChatMessage {
+	chat := slices.Clone(baseline)
+
+	var last uint64
+	if len(chat) > 0 {
+		last = chat[len(chat)-1].ID
+	}
+	for _, message := range messages {
+		if message.ID > last {
+			chat = append(chat, message)
+			last = message.ID
+		}
+	}
+
+	if len(chat) > chatHistory {
+		chat = slices.Clone(chat[len(chat)-chatHistory:])
+	}
+
+	return chat
+}
+
+// diffGameInfo creates the snapshot turning the baseline game state information into the current one.
+// The strings that rarely change, the level and the usernames of the players, are left empty if the baseline has them.
+//
+// Parameters:
+//   - seq: The sequence number of the current game state information.
+//   - baselineSeq: The sequence number of the baseline, or 0 for a full snapshot.
+//   - baseline: The game state information the client already has, empty for a full snapshot.
+//   - current: The current game state information.
+//
+// Returns:
+//   - ProtoSnapshot: The snapshot to send to the client.
+func diffGameInfo(seq, baselineSeq uint64, baseline, current ProtoGameInfo) ProtoSnapshot {
+	snapshot := ProtoSnapshot{
+		Seq:            seq,
+		Baseline:       baselineSeq,
+		GameState:      current.GameState,
+		Level:          unlessUnchanged(baseline.Level, current.Level),
+		LevelHash:      unlessUnchanged(baseline.LevelHash, current.LevelHash),
+		Seed:           current.Seed,
+		Tick:           current.Tick,
+		MaxPlayers:     current.MaxPlayers,
+		TerrainChanges: diffList(baseline.TerrainChanges, current.TerrainChanges),
+		Players:        diffList(baseline.Players, current.Players),
+		Monsters:       diffEntities(baseline.Monsters, current.Monsters),
+		Bombs:          diffEntities(baseline.Bombs, current.Bombs),
+		Explosions:     diffEntities(baseline.Explosions, current.Explosions),
+		Boxes:          diffEntities(baseline.Boxes, current.Boxes),
+		StatusEffects:  diffEntities(baseline.StatusEffects, current.StatusEffects),
+		Chat:           diffChat(baseline.Chat, current.Chat),
+	}
+
+	for i, change := range snapshot.Players.Changed {
+		if change.Index < len(baseline.Players) {
+			snapshot.Players.Changed[i].Value.Username = unlessUnchanged(baseline.Players[change.Index].Username, change.Value.Username)
+		}
+	}
+
+	return snapshot
+}
+
+// unlessUnchanged returns the current value of a string sent in a snapshot, or an empty string if the baseline has it.
+func unlessUnchanged(baseline string, current string) string {
+	if baseline == current {
+		return ""
+	}
+
+	return current
+}
+
+// applySnapshot reconstructs the game state information of a snapshot from its baseline.
+// The baseline is left untouched.
+//
+// Parameters:
+//   - baseline: The game state information the snapshot is relative to, empty for a full snapshot.
+//   - snapshot: The snapshot received from the server.
+//
+// Returns:
+//   - ProtoGameInfo: The game state information of the snapshot.
+func applySnapshot(baseline ProtoGameInfo, snapshot ProtoSnapshot) ProtoGameInfo {
+	info := ProtoGameInfo{
+		GameState:      snapshot.GameState,
+		Level:          cmp.Or(snapshot.Level, baseline.Level),
+		LevelHash:      cmp.Or(snapshot.LevelHash, baseline.LevelHash),
+		Seed:           snapshot.Seed,
+		Tick:           snapshot.Tick,
+		MaxPlayers:     snapshot.MaxPlayers,
+		TerrainChanges: applyList(baseline.TerrainChanges, snapshot.TerrainChanges),
+		Players:        applyList(baseline.Players, snapshot.Players),
+		Monsters:       applyEntities(baseline.Monsters, snapshot.Monsters),
+		Bombs:          applyEntities(baseline.Bombs, snapshot.Bombs),
+		Explosions:     applyEntities(baseline.Explosions, snapshot.Explosions),
+		Boxes:          applyEntities(baseline.Boxes, snapshot.Boxes),
+		StatusEffects:  applyEntities(baseline.StatusEffects, snapshot.StatusEffects),
+		Chat:           applyChat(baseline.Chat, snapshot.Chat),
+	}
+
+	// the usernames the baseline has are left out of the changed players
+	for _, change := range snapshot.Players.Changed {
+		if change.Value.Username == "" && change.Index >= 0 && change.Index < len(baseline.Players) && change.Index < len(info.Players) {
+			info.Players[change.Index].Username = baseline.Players[change.Index].Username
+		}
+	}
+
+	return info
+}
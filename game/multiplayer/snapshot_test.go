@@ -0,0 +1,143 @@
+package multiplayer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffGameInfo_ApplyRestoresCurrent(t *testing.T) {
+	baseline := ProtoGameInfo{
+		GameState:      GameStateRunning,
+		TerrainChanges: []ProtoTerrainChange{{X: 1, Y: 2, To: "GRASS"}},
+		Players:        []ProtoPlayer{{Username: "a", X: 10}, {Username: "b", X: 20}},
+		Explosions:     []ProtoEntity{{ID: 1, X: 1}, {ID: 2, X: 2}, {ID: 3, X: 3}},
+		Boxes:          []ProtoEntity{{ID: 4, X: 5}, {ID: 5, X: 6}},
+	}
+	current := cloneGameInfo(baseline)
+	current.TerrainChanges = append(current.TerrainChanges, ProtoTerrainChange{X: 3, Y: 4, To: "GRASS"})
+	current.Players[1].X = 21
+	current.Explosions = append(current.Explosions[:1], current.Explosions[2])
+	current.Bombs = []ProtoEntity{{ID: 6, X: 7}}
+
+	snapshot := diffGameInfo(2, 1, baseline, current)
+
+	if len(snapshot.Boxes.Spawned)+len(snapshot.Boxes.Updated)+len(snapshot.Boxes.Despawned) != 0 {
+		t.Errorf("Expected unchanged boxes to be left out, got %+v", snapshot.Boxes)
+	}
+	if len(snapshot.Explosions.Updated) != 0 || len(snapshot.Explosions.Despawned) != 1 || snapshot.Explosions.Despawned[0] != 2 {
+		t.Errorf("Expected only the removed explosion to be despawned, got %+v", snapshot.Explosions)
+	}
+	if len(snapshot.Bombs.Spawned) != 1 || snapshot.Bombs.Spawned[0].ID != 6 {
+		t.Errorf("Expected the new bomb to be spawned, got %+v", snapshot.Bombs)
+	}
+	if len(snapshot.Players.Changed) != 1 || snapshot.Players.Changed[0].Index != 1 {
+		t.Errorf("Expected only the second player to change, got %+v", snapshot.Players.Changed)
+	}
+	if len(snapshot.TerrainChanges.Changed) != 1 {
+		t.Errorf("Expected only the new terrain change, got %+v", snapshot.TerrainChanges.Changed)
+	}
+
+	got := applySnapshot(baseline, snapshot)
+	if !reflect.DeepEqual(got.Players, current.Players) || !reflect.DeepEqual(got.Explosions, current.Explosions) ||
+		!reflect.DeepEqual(got.Bombs, current.Bombs) || !reflect.DeepEqual(got.TerrainChanges, current.TerrainChanges) ||
+		!reflect.DeepEqual(got.Boxes, current.Boxes) {
+		t.Errorf("Expected %+v, got %+v", current, got)
+	}
+	if baseline.Players[1].X != 20 {
+		t.Error("Expected the baseline to be left untouched")
+	}
+}
+
+func TestDiffGameInfo_Full(t *testing.T) {
+	current := ProtoGameInfo{GameState: GameStateLobby, Players: []ProtoPlayer{{Username: "a"}}}
+
+	snapshot := diffGameInfo(1, 0, ProtoGameInfo{}, current)
+
+	got := applySnapshot(ProtoGameInfo{}, snapshot)
+	if got.GameState != GameStateLobby || !reflect.DeepEqual(got.Players, current.Players) {
+		t.Errorf("Expected %+v, got %+v", current, got)
+	}
+}
+
+func TestSnapshotRing(t *testing.T) {
+	var ring snapshotRing
+	ring.put(1, ProtoGameInfo{Level: "first"})
+	ring.put(1+snapshotHistory, ProtoGameInfo{Level: "later"})
+
+	if _, ok := ring.get(1); ok {
+		t.Error("Expected the overwritten snapshot to be gone")
+	}
+	if info, ok := ring.get(1 + snapshotHistory); !ok || info.Level != "later" {
+		t.Errorf("Expected the latest snapshot, got %+v", info)
+	}
+	if _, ok := ring.get(0); ok {
+		t.Error("Expected no snapshot for sequence number 0")
+	}
+}
+
+func TestDiffChat(t *testing.T) {
+	baseline := []ProtoChatMessage{{ID: 1, Text: "a"}, {ID: 2, Text: "b"}}
+	current := append(baseline[1:2:2], ProtoChatMessage{ID: 3, Text: "c"}, ProtoChatMessage{ID: 4, Text: "d"})
+
+	messages := diffChat(baseline, current)
+	if len(messages) != 2 || messages[0].ID != 3 || messages[1].ID != 4 {
+		t.Errorf("Expected the messages 3 and 4, got %+v", messages)
+	}
+	if messages := diffChat(current, current); messages != nil {
+		t.Errorf("Expected no messages, got %+v", messages)
+	}
+
+	// messages the client already has are not appended again
+	got := applyChat(baseline, append([]ProtoChatMessage{{ID: 2, Text: "b"}}, messages...))
+	if len(got) != 4 || got[3].ID != 4 {
+		t.Errorf("Expected the messages 1 to 4, got %+v", got)
+	}
+}
+
+func TestApplyChat_KeepsHistory(t *testing.T) {
+	var messages []ProtoChatMessage
+	for i := range chatHistory + 5 {
+		messages = append(messages, ProtoChatMessage{ID: uint64(i + 1)})
+	}
+
+	got := applyChat(nil, messages)
+	if len(got) != chatHistory || got[0].ID != 6 {
+		t.Errorf("Expected the latest %d messages, got %d starting with %d", chatHistory, len(got), got[0].ID)
+	}
+}
+
+func TestDiffGameInfo_LeavesOutUnchangedStrings(t *testing.T) {
+	baseline := ProtoGameInfo{
+		Level:     "assets/levels/level1.txt",
+		LevelHash: "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03",
+		Players:   []ProtoPlayer{{Username: "a", X: 10}, {Username: "b", X: 20}},
+	}
+	current := cloneGameInfo(baseline)
+	current.Players[0].X = 11
+	current.Players[1] = ProtoPlayer{Username: "c", X: 30}
+
+	snapshot := diffGameInfo(2, 1, baseline, current)
+
+	if snapshot.Level != "" || snapshot.LevelHash != "" {
+		t.Errorf("Expected the unchanged level to be left out, got %q %q", snapshot.Level, snapshot.LevelHash)
+	}
+	if username := snapshot.Players.Changed[0].Value.Username; username != "" {
+		t.Errorf("Expected the unchanged username to be left out, got %q", username)
+	}
+	if username := snapshot.Players.Changed[1].Value.Username; username != "c" {
+		t.Errorf("Expected the changed username to be sent, got %q", username)
+	}
+	if current.Players[0].Username != "a" {
+		t.Error("Expected the current game state to be left untouched")
+	}
+
+	got := applySnapshot(baseline, snapshot)
+	if got.Level != current.Level || got.LevelHash != current.LevelHash || !reflect.DeepEqual(got.Players, current.Players) {
+		t.Errorf("Expected %+v, got %+v", current, got)
+	}
+
+	full := diffGameInfo(2, 0, ProtoGameInfo{}, current)
+	if full.Level != current.Level || full.Players.Changed[0].Value.Username != "a" {
+		t.Errorf("Expected a full snapshot to carry every string, got %+v", full)
+	}
+}
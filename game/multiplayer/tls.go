@@ -0,0 +1,299 @@
+// This file contains the secure connections between the clients and the game server.
+// A server with TLS enabled uses the certificate it is given, or a self-signed one it creates once
+// and keeps in the user config directory. Clients join it by prefixing its address with wss://,
+// and trust a certificate that is not signed by a known authority on first use: its fingerprint is pinned
+// to the address of the server, and a server presenting another certificate later is refused.
+package multiplayer
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// secureScheme is the prefix of the addresses of the servers joined through a secure connection.
+const secureScheme = "wss://"
+
+// selfSignedValidity is how long a self-signed certificate created by the server is valid.
+const selfSignedValidity = 10 * 365 * 24 * time.Hour
+
+// configDir is the directory under the user config directory the certificates and the pinned fingerprints are kept in.
+const configDir = "ninjago"
+
+// The files under configDir holding the self-signed certificate of the server and the fingerprints pinned by the clients.
+const (
+	selfSignedCertFile = "server.crt"
+	selfSignedKeyFile  = "server.key"
+	knownServersFile   = "known_servers.json"
+)
+
+// knownServersMu guards the file of the pinned fingerprints, shared by every client of the process.
+var knownServersMu sync.Mutex
+
+// Fingerprint returns the fingerprint a certificate is pinned by.
+//
+// Parameters:
+//   - der: The certificate in DER form.
+//
+// Returns:
+//   - string: The hexadecimal SHA-256 hash of the certificate.
+func Fingerprint(der []byte) string {
+	hash := sha256.Sum256(der)
+
+	return hex.EncodeToString(hash[:])
+}
+
+// listen opens the listener of a server, wrapped in TLS if the server is configured to use it.
+//
+// Parameters:
+//   - config: The settings of the server.
+//
+// Returns:
+//   - net.Listener: The listener accepting the connections of the clients.
+//   - error: An error if the server cannot listen on its address or its certificate cannot be loaded.
+func listen(config ServerConfig) (net.Listener, error) {
+	listener, err := net.Listen("tcp", config.Address)
+	if err != nil {
+		return nil, fmt.Errorf("error listening: %w", err)
+	}
+	if !config.TLS {
+		return listener, nil
+	}
+
+	certificate, err := serverCertificate(config)
+	if err != nil {
+		listener.Close()
+
+		return nil, err
+	}
+	log.Println("Serving secure connections with certificate", Fingerprint(certificate.Certificate[0]))
+
+	return tls.NewListener(listener, &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}), nil
+}
+
+// serverCertificate loads the certificate of the server from the files it is given,
+// or the self-signed certificate kept in the user config directory, creating it on the first start.
+func serverCertificate(config ServerConfig) (tls.Certificate, error) {
+	if config.CertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("error loading certificate: %w", err)
+		}
+
+		return certificate, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	certFile := filepath.Join(dir, configDir, selfSignedCertFile)
+	keyFile := filepath.Join(dir, configDir, selfSignedKeyFile)
+
+	// the same certificate is used on every start, so the clients that pinned it keep trusting the server
+	if certificate, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil {
+		return certificate, nil
+	}
+
+	certPEM, keyPEM, err := createSelfSigned()
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("error creating certificate: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(certFile), 0o755); err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := os.WriteFile(certFile, certPEM, 0o644); err != nil {
+		return tls.Certificate{}, err
+	}
+	log.Println("Created self-signed certificate", certFile)
+
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// createSelfSigned creates a self-signed certificate and its private key.
+//
+// Returns:
+//   - []byte: The certificate in PEM form.
+//   - []byte: The private key in PEM form.
+//   - error: An error if the key cannot be generated.
+func createSelfSigned() ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "NinjaGo game server"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), nil
+}
+
+// splitScheme removes the secure scheme from an address joined by a client.
+//
+// Parameters:
+//   - address: The address of the game server, optionally prefixed with wss:// or ws://.
+//
+// Returns:
+//   - string: The address without its scheme.
+//   - bool: Whether the server is joined through a secure connection.
+func splitScheme(address string) (string, bool) {
+	if rest, ok := strings.CutPrefix(address, secureScheme); ok {
+		return rest, true
+	}
+
+	return strings.TrimPrefix(address, "ws://"), false
+}
+
+// clientTLSConfig returns the TLS settings of a client joining the server at the given address.
+// Certificates signed by a known authority are trusted as usual, others are pinned on first use.
+//
+// Parameters:
+//   - address: The address of the game server, in host:port form.
+//
+// Returns:
+//   - *tls.Config: The TLS settings of the connections to the server.
+func clientTLSConfig(address string) *tls.Config {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// the certificate is verified below, as self-signed certificates fail the usual verification
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("the server sent no certificate")
+			}
+			if verifyCertificate(rawCerts, host) == nil {
+				return nil
+			}
+
+			return pinCertificate(address, Fingerprint(rawCerts[0]))
+		},
+	}
+}
+
+// verifyCertificate verifies a certificate chain against the authorities trusted by the system.
+func verifyCertificate(rawCerts [][]byte, host string) error {
+	certificates := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		certificate, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certificates[i] = certificate
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, certificate := range certificates[1:] {
+		intermediates.AddCert(certificate)
+	}
+
+	_, err := certificates[0].Verify(x509.VerifyOptions{DNSName: host, Intermediates: intermediates})
+
+	return err
+}
+
+// pinCertificate checks the fingerprint of the certificate of a server against the one pinned for its address,
+// pinning it if the server is joined for the first time.
+//
+// Parameters:
+//   - address: The address of the game server, in host:port form.
+//   - fingerprint: The fingerprint of the certificate presented by the server.
+//
+// Returns:
+//   - error: An error if another certificate is pinned for the server or the fingerprint cannot be stored.
+func pinCertificate(address string, fingerprint string) error {
+	knownServersMu.Lock()
+	defer knownServersMu.Unlock()
+
+	path, err := knownServersPath()
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]string)
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &known); err != nil {
+			return fmt.Errorf("invalid known servers file %s: %w", path, err)
+		}
+	}
+
+	if pinned, ok := known[address]; ok {
+		if pinned != fingerprint {
+			return fmt.Errorf("the certificate of %s changed, remove it from %s if this is expected", address, path)
+		}
+
+		return nil
+	}
+
+	log.Println("Trusting the certificate of", address, "on first use:", fingerprint)
+	known[address] = fingerprint
+	data, err := json.MarshalIndent(known, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o644)
+}
+
+// knownServersPath returns the path of the file of the fingerprints pinned by the clients.
+func knownServersPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, configDir, knownServersFile), nil
+}
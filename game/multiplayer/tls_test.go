@@ -0,0 +1,111 @@
+package multiplayer
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/userinfo"
+)
+
+// useTempConfigDir points the user config directory to a temporary directory for the duration of a test.
+func useTempConfigDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("AppData", dir)
+	t.Setenv("HOME", dir)
+}
+
+func TestCreateSelfSigned(t *testing.T) {
+	certPEM, keyPEM, err := createSelfSigned()
+	if err != nil {
+		t.Fatalf("Expected a certificate, got %v", err)
+	}
+
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		t.Errorf("Expected the certificate to match its key, got %v", err)
+	}
+}
+
+func TestPinCertificate(t *testing.T) {
+	useTempConfigDir(t)
+
+	if err := pinCertificate("10.0.0.1:8080", "aa"); err != nil {
+		t.Fatalf("Expected the certificate to be trusted on first use, got %v", err)
+	}
+	if err := pinCertificate("10.0.0.1:8080", "aa"); err != nil {
+		t.Errorf("Expected the pinned certificate to be trusted, got %v", err)
+	}
+	if err := pinCertificate("10.0.0.1:8080", "bb"); err == nil {
+		t.Errorf("Expected a changed certificate to be refused")
+	}
+	if err := pinCertificate("10.0.0.2:8080", "bb"); err != nil {
+		t.Errorf("Expected the certificate of another server to be trusted on first use, got %v", err)
+	}
+}
+
+func TestSplitScheme(t *testing.T) {
+	tests := []struct {
+		address         string
+		expectedAddress string
+		expectedSecure  bool
+	}{
+		{"localhost:8080", "localhost:8080", false},
+		{"ws://localhost:8080", "localhost:8080", false},
+		{"wss://localhost:8080/finals", "localhost:8080/finals", true},
+	}
+
+	for _, tt := range tests {
+		address, secure := splitScheme(tt.address)
+		if address != tt.expectedAddress || secure != tt.expectedSecure {
+			t.Errorf("Expected %s and %t for %s, got %s and %t", tt.expectedAddress, tt.expectedSecure, tt.address, address, secure)
+		}
+	}
+}
+
+func TestGameClient_PinsCertificate(t *testing.T) {
+	useTempConfigDir(t)
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var hello ProtoHello
+		conn.ReadJSON(&hello)
+		conn.WriteJSON(ProtoWelcome{Version: ProtocolVersion, UserID: "session", TickRate: DefaultTickRate})
+		conn.ReadMessage()
+	}))
+	defer server.Close()
+	address := strings.TrimPrefix(server.URL, "https://")
+
+	client, err := NewGameClient(secureScheme+address, &userinfo.UserInfo{}, "", func(code int, text string) error { return nil })
+	if err != nil {
+		t.Fatalf("Expected the client to join through a secure connection, got %v", err)
+	}
+	client.Close()
+
+	path, err := knownServersPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected the certificate to be pinned, got %v", err)
+	}
+	var known map[string]string
+	if err := json.Unmarshal(data, &known); err != nil {
+		t.Fatal(err)
+	}
+	if expected := Fingerprint(server.Certificate().Raw); known[address] != expected {
+		t.Errorf("Expected fingerprint %s, got %s", expected, known[address])
+	}
+}
@@ -0,0 +1,116 @@
+// Package replay provides recording matches and playing them back.
+package replay
+
+import (
+	"bytes"
+	"fmt"
+
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/entities"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/sim"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/userinfo"
+)
+
+// Playback replays a recorded match by simulating it again from the recorded inputs.
+type Playback struct {
+	replay *Replay    // The replay being played back.
+	world  *sim.World // The world of the replayed match.
+}
+
+// NewPlayback creates a playback of the given replay, positioned at its first tick.
+//
+// Parameters:
+//   - replay: The replay to play back.
+//
+// Returns:
+//   - *Playback: The playback of the replay.
+//   - error: An error if the level stored in the replay cannot be loaded.
+func NewPlayback(replay *Replay) (*Playback, error) {
+	p := &Playback{replay: replay}
+	if err := p.restart(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// restart reloads the level of the replay, going back to its first tick.
+func (p *Playback) restart() error {
+	world, err := sim.LoadLevel(bytes.NewReader(p.replay.Level), p.replay.Seed)
+	if err != nil {
+		return fmt.Errorf("error loading replay level: %w", err)
+	}
+	p.world = world
+
+	return nil
+}
+
+// Replay returns the replay being played back.
+func (p *Playback) Replay() *Replay {
+	return p.replay
+}
+
+// World returns the world of the replayed match.
+func (p *Playback) World() *sim.World {
+	return p.world
+}
+
+// Tick returns the number of ticks played back so far.
+func (p *Playback) Tick() uint64 {
+	return p.world.Tick()
+}
+
+// Done reports whether every tick of the replay has been played back.
+func (p *Playback) Done() bool {
+	return p.Tick() >= p.replay.Length()
+}
+
+// Step plays back the next tick of the replay, doing nothing once the replay is over.
+func (p *Playback) Step() {
+	if p.Done() {
+		return
+	}
+
+	tick := p.Tick()
+
+	for id := len(p.world.Players()); id < len(p.replay.Players) && p.replay.Players[id].JoinTick <= tick; id++ {
+		entry := p.replay.Players[id]
+		p.world.AddPlayer(entry.X, entry.Y, &userinfo.UserInfo{Username: entry.Username}, entry.Color)
+	}
+
+	for id, entry := range p.replay.Players {
+		if entry.Left && entry.LeftTick == tick {
+			p.world.KillPlayer(sim.PlayerID(id))
+		}
+	}
+
+	controls := p.replay.Ticks[tick]
+	inputs := make(map[sim.PlayerID]entities.PlayerControls, len(controls))
+	for id, c := range controls {
+		inputs[sim.PlayerID(id)] = c
+	}
+
+	p.world.Step(inputs)
+}
+
+// Seek moves the playback to the given tick. Seeking backwards replays the match from its start.
+//
+// Parameters:
+//   - tick: The tick to move to, clamped to the length of the replay.
+//
+// Returns:
+//   - error: An error if the level stored in the replay cannot be reloaded.
+func (p *Playback) Seek(tick uint64) error {
+	tick = min(tick, p.replay.Length())
+
+	if tick < p.Tick() {
+		if err := p.restart(); err != nil {
+			return err
+		}
+	}
+
+	for p.Tick() < tick {
+		p.Step()
+	}
+
+	return nil
+}
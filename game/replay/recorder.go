@@ -0,0 +1,93 @@
+// Package replay provides recording matches and playing them back.
+package replay
+
+import (
+	"image/color"
+
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/entities"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/sim"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/userinfo"
+)
+
+// Recorder drives a world and records everything needed to play the match back.
+// The world should only be changed through the recorder while recording.
+type Recorder struct {
+	world  *sim.World // The world of the recorded match.
+	replay Replay     // The replay recorded so far.
+}
+
+// NewRecorder creates a recorder for a world that has just been loaded from the given level.
+//
+// Parameters:
+//   - world: The world of the match to record.
+//   - level: The contents of the level file the world was loaded from.
+//
+// Returns:
+//   - *Recorder: The recorder of the match.
+func NewRecorder(world *sim.World, level []byte) *Recorder {
+	return &Recorder{
+		world:  world,
+		replay: Replay{Level: level, Seed: world.Seed()},
+	}
+}
+
+// World returns the world of the recorded match.
+func (r *Recorder) World() *sim.World {
+	return r.world
+}
+
+// Replay returns the replay recorded so far.
+func (r *Recorder) Replay() *Replay {
+	return &r.replay
+}
+
+// AddPlayer adds a new player to the world at the specified position and records the join.
+//
+// Parameters:
+//   - x: The x coordinate of the player.
+//   - y: The y coordinate of the player.
+//   - userData: The user data associated with the player.
+//   - colorOverlay: The color overlay of the player sprite.
+//
+// Returns:
+//   - *sim.Player: The added player.
+func (r *Recorder) AddPlayer(x, y float64, userData *userinfo.UserInfo, colorOverlay color.Color) *sim.Player {
+	cr, cg, cb, ca := colorOverlay.RGBA()
+	r.replay.Players = append(r.replay.Players, PlayerEntry{
+		Username: userData.Username,
+		Color:    color.RGBA{R: uint8(cr >> 8), G: uint8(cg >> 8), B: uint8(cb >> 8), A: uint8(ca >> 8)},
+		X:        x,
+		Y:        y,
+		JoinTick: r.world.Tick(),
+	})
+
+	return r.world.AddPlayer(x, y, userData, colorOverlay)
+}
+
+// KillPlayer kills a player for a reason outside of the simulation, such as leaving the match, and records it.
+//
+// Parameters:
+//   - id: The ID of the player to kill.
+func (r *Recorder) KillPlayer(id sim.PlayerID) {
+	if p := r.world.Player(id); p == nil || p.IsDead {
+		return
+	}
+
+	r.replay.Players[id].Left = true
+	r.replay.Players[id].LeftTick = r.world.Tick()
+	r.world.KillPlayer(id)
+}
+
+// Step advances the world by a single tick and records the controls of the players.
+//
+// Parameters:
+//   - inputs: The controls of the players for this tick.
+func (r *Recorder) Step(inputs map[sim.PlayerID]entities.PlayerControls) {
+	controls := make([]entities.PlayerControls, len(r.replay.Players))
+	for i := range controls {
+		controls[i] = inputs[sim.PlayerID(i)]
+	}
+	r.replay.Ticks = append(r.replay.Ticks, controls)
+
+	r.world.Step(inputs)
+}
@@ -0,0 +1,302 @@
+// Package replay provides recording matches and playing them back.
+// A match is fully described by its level, the seed of its random source and the
+// controls of every player in every tick, so only those are stored.
+package replay
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/entities"
+)
+
+// Version is the version of the replay file format written by this package.
+const Version = 1
+
+// FileExtension is the extension of replay files.
+const FileExtension = ".ngrp"
+
+// magic identifies replay files.
+var magic = [4]byte{'N', 'G', 'R', 'P'}
+
+// ErrNotReplay is returned when reading data that is not a replay file.
+var ErrNotReplay = errors.New("not a replay file")
+
+// PlayerEntry describes a player of a recorded match.
+type PlayerEntry struct {
+	Username string     // The username of the player.
+	Color    color.RGBA // The color overlay of the player sprite.
+	X, Y     float64    // The position the player joined at.
+	JoinTick uint64     // The tick the player joined at.
+	Left     bool       // Whether the player left the match before it ended.
+	LeftTick uint64     // The tick the player left the match at.
+}
+
+// Replay represents a recorded match.
+type Replay struct {
+	Level   []byte                      // The contents of the level file the match was played on.
+	Seed    int64                       // The seed of the random source of the match.
+	Players []PlayerEntry               // The players of the match, indexed by their ID.
+	Ticks   [][]entities.PlayerControls // The controls of the players joined so far, for every tick.
+}
+
+// Length returns the number of ticks in the replay.
+func (r *Replay) Length() uint64 {
+	return uint64(len(r.Ticks))
+}
+
+// Save writes the replay to the file at the given path.
+//
+// Parameters:
+//   - path: The path of the replay file.
+//
+// Returns:
+//   - error: An error if the file cannot be written.
+func (r *Replay) Save(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating replay file: %w", err)
+	}
+	defer file.Close()
+
+	return r.Write(file)
+}
+
+// Load reads the replay file at the given path.
+//
+// Parameters:
+//   - path: The path of the replay file.
+//
+// Returns:
+//   - *Replay: The replay read from the file.
+//   - error: An error if the file cannot be read or is not a replay file.
+func Load(path string) (*Replay, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening replay file: %w", err)
+	}
+	defer file.Close()
+
+	return Read(file)
+}
+
+// Write encodes the replay in the replay file format.
+// The file starts with the magic "NGRP" and the format version, followed by the seed,
+// the level, the players and finally the controls of every tick, one byte per player.
+//
+// Parameters:
+//   - w: The writer receiving the replay.
+//
+// Returns:
+//   - error: An error if the replay cannot be written.
+func (r *Replay) Write(w io.Writer) error {
+	e := &encoder{w: bufio.NewWriter(w)}
+
+	e.bytes(magic[:])
+	e.uvarint(Version)
+	e.varint(r.Seed)
+	e.uvarint(uint64(len(r.Level)))
+	e.bytes(r.Level)
+
+	e.uvarint(uint64(len(r.Players)))
+	for _, p := range r.Players {
+		e.uvarint(uint64(len(p.Username)))
+		e.bytes([]byte(p.Username))
+		e.bytes([]byte{p.Color.R, p.Color.G, p.Color.B, p.Color.A})
+		e.float(p.X)
+		e.float(p.Y)
+		e.uvarint(p.JoinTick)
+		e.boolean(p.Left)
+		e.uvarint(p.LeftTick)
+	}
+
+	e.uvarint(uint64(len(r.Ticks)))
+	for _, controls := range r.Ticks {
+		e.uvarint(uint64(len(controls)))
+		for _, c := range controls {
+			e.bytes([]byte{c.Bits()})
+		}
+	}
+
+	if e.err != nil {
+		return fmt.Errorf("error writing replay: %w", e.err)
+	}
+
+	return e.w.Flush()
+}
+
+// Read decodes a replay written by Replay.Write.
+//
+// Parameters:
+//   - r: The reader providing the replay.
+//
+// Returns:
+//   - *Replay: The decoded replay.
+//   - error: An error if the data is not a replay file, has an unsupported version or is truncated.
+func Read(r io.Reader) (*Replay, error) {
+	d := &decoder{r: bufio.NewReader(r)}
+
+	var fileMagic [4]byte
+	d.bytes(fileMagic[:])
+	if d.err != nil || fileMagic != magic {
+		return nil, ErrNotReplay
+	}
+
+	if version := d.uvarint(); d.err == nil && version != Version {
+		return nil, fmt.Errorf("unsupported replay version %d, expected %d", version, Version)
+	}
+
+	replay := &Replay{Seed: d.varint()}
+	replay.Level = make([]byte, d.length())
+	d.bytes(replay.Level)
+
+	replay.Players = make([]PlayerEntry, d.length())
+	for i := range replay.Players {
+		username := make([]byte, d.length())
+		d.bytes(username)
+
+		var rgba [4]byte
+		d.bytes(rgba[:])
+
+		replay.Players[i] = PlayerEntry{
+			Username: string(username),
+			Color:    color.RGBA{R: rgba[0], G: rgba[1], B: rgba[2], A: rgba[3]},
+			X:        d.float(),
+			Y:        d.float(),
+			JoinTick: d.uvarint(),
+			Left:     d.boolean(),
+			LeftTick: d.uvarint(),
+		}
+	}
+
+	replay.Ticks = make([][]entities.PlayerControls, d.length())
+	for i := range replay.Ticks {
+		controls := make([]byte, d.length())
+		d.bytes(controls)
+
+		replay.Ticks[i] = make([]entities.PlayerControls, len(controls))
+		for j, bits := range controls {
+			replay.Ticks[i][j] = entities.PlayerControlsFromBits(bits)
+		}
+	}
+
+	if d.err != nil {
+		return nil, fmt.Errorf("error reading replay: %w", d.err)
+	}
+
+	return replay, nil
+}
+
+// encoder writes the primitives of the replay file format, remembering the first error.
+type encoder struct {
+	w   *bufio.Writer // The buffered destination of the replay.
+	err error         // The first error that occurred while writing.
+}
+
+// bytes writes raw bytes.
+func (e *encoder) bytes(b []byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(b)
+	}
+}
+
+// uvarint writes an unsigned variable-length integer.
+func (e *encoder) uvarint(v uint64) {
+	e.bytes(binary.AppendUvarint(nil, v))
+}
+
+// varint writes a signed variable-length integer.
+func (e *encoder) varint(v int64) {
+	e.bytes(binary.AppendVarint(nil, v))
+}
+
+// boolean writes a boolean as a single byte.
+func (e *encoder) boolean(v bool) {
+	if v {
+		e.bytes([]byte{1})
+	} else {
+		e.bytes([]byte{0})
+	}
+}
+
+// float writes a 64-bit floating point number.
+func (e *encoder) float(v float64) {
+	if e.err == nil {
+		e.err = binary.Write(e.w, binary.LittleEndian, v)
+	}
+}
+
+// decoder reads the primitives of the replay file format, remembering the first error.
+type decoder struct {
+	r   *bufio.Reader // The buffered source of the replay.
+	err error         // The first error that occurred while reading.
+}
+
+// maxLength limits the lengths read from a replay, so a corrupt file cannot exhaust memory.
+const maxLength = 1 << 26
+
+// bytes reads exactly len(b) bytes.
+func (d *decoder) bytes(b []byte) {
+	if d.err == nil {
+		_, d.err = io.ReadFull(d.r, b)
+	}
+}
+
+// uvarint reads an unsigned variable-length integer.
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+
+	var v uint64
+	v, d.err = binary.ReadUvarint(d.r)
+
+	return v
+}
+
+// varint reads a signed variable-length integer.
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+
+	var v int64
+	v, d.err = binary.ReadVarint(d.r)
+
+	return v
+}
+
+// length reads the length of a list or byte string.
+func (d *decoder) length() int {
+	v := d.uvarint()
+	if v > maxLength {
+		d.err = fmt.Errorf("length %d exceeds the limit of %d", v, maxLength)
+
+		return 0
+	}
+
+	return int(v)
+}
+
+// boolean reads a boolean stored as a single byte.
+func (d *decoder) boolean() bool {
+	var b [1]byte
+	d.bytes(b[:])
+
+	return b[0] != 0
+}
+
+// float reads a 64-bit floating point number.
+func (d *decoder) float() float64 {
+	var v float64
+	if d.err == nil {
+		d.err = binary.Read(d.r, binary.LittleEndian, &v)
+	}
+
+	return v
+}
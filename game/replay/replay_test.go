@@ -0,0 +1,161 @@
+package replay
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"strings"
+	"testing"
+
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/entities"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/sim"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/userinfo"
+)
+
+// testLevel is a small level surrounded by walls with a few monsters and boxes.
+func testLevel() []byte {
+	var b strings.Builder
+	for row := 0; row < 17; row++ {
+		tiles := make([]string, 17)
+		for col := range tiles {
+			if row == 0 || col == 0 || row == 16 || col == 16 {
+				tiles[col] = "SOLID"
+			} else {
+				tiles[col] = "GRASS"
+			}
+		}
+		b.WriteString(strings.Join(tiles, " ") + "\n")
+	}
+	b.WriteString("SLIME 8 8\nGHOST 12 12\nBOX 6 5\nBOX 4 5\n")
+
+	return []byte(b.String())
+}
+
+// recordTestMatch records a short match in which a second player joins late and the first one leaves.
+func recordTestMatch(t *testing.T) (*Recorder, []sim.Position) {
+	t.Helper()
+
+	level := testLevel()
+	world, err := sim.LoadLevel(bytes.NewReader(level), 7)
+	if err != nil {
+		t.Fatalf("LoadLevel failed: %v", err)
+	}
+
+	recorder := NewRecorder(world, level)
+	recorder.AddPlayer(83, 83, &userinfo.UserInfo{Username: "First"}, color.RGBA{R: 255, A: 255})
+
+	controls := []entities.PlayerControls{{Right: true}, {Down: true}, {Ability1: true}, {Left: true}, {Up: true}}
+	for i := 0; i < 300; i++ {
+		if i == 50 {
+			recorder.AddPlayer(195, 195, &userinfo.UserInfo{Username: "Second"}, color.RGBA{B: 255, A: 255})
+		}
+		if i == 200 {
+			recorder.KillPlayer(0)
+		}
+
+		recorder.Step(map[sim.PlayerID]entities.PlayerControls{0: controls[i/20%len(controls)], 1: controls[i/30%len(controls)]})
+	}
+
+	return recorder, positions(world)
+}
+
+// positions returns the positions of the players and monsters of a world.
+func positions(world *sim.World) []sim.Position {
+	var result []sim.Position
+	for _, p := range world.Players() {
+		x, y := p.GetPosition()
+		result = append(result, sim.Position{X: x, Y: y})
+	}
+	for _, m := range world.Monsters() {
+		x, y := m.GetPosition()
+		result = append(result, sim.Position{X: x, Y: y})
+	}
+
+	return result
+}
+
+func TestReplay_WriteRead(t *testing.T) {
+	recorder, _ := recordTestMatch(t)
+
+	var buf bytes.Buffer
+	if err := recorder.Replay().Write(&buf); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	replay, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	original := recorder.Replay()
+	if replay.Seed != original.Seed || !bytes.Equal(replay.Level, original.Level) {
+		t.Error("Expected the seed and the level to survive the round trip")
+	}
+	if len(replay.Players) != 2 || replay.Players[1] != original.Players[1] || !replay.Players[0].Left {
+		t.Errorf("Expected players %+v, got %+v", original.Players, replay.Players)
+	}
+	if replay.Length() != 300 || len(replay.Ticks[10]) != 1 || len(replay.Ticks[60]) != 2 {
+		t.Errorf("Expected 300 ticks with the second player from tick 50, got %d ticks", replay.Length())
+	}
+	if replay.Ticks[42][0] != original.Ticks[42][0] {
+		t.Errorf("Expected controls %+v, got %+v", original.Ticks[42][0], replay.Ticks[42][0])
+	}
+}
+
+func TestRead_NotReplay(t *testing.T) {
+	if _, err := Read(strings.NewReader("SOLID SOLID")); !errors.Is(err, ErrNotReplay) {
+		t.Errorf("Expected ErrNotReplay, got %v", err)
+	}
+}
+
+func TestPlayback_MatchesRecording(t *testing.T) {
+	recorder, expected := recordTestMatch(t)
+
+	playback, err := NewPlayback(recorder.Replay())
+	if err != nil {
+		t.Fatalf("NewPlayback failed: %v", err)
+	}
+
+	for !playback.Done() {
+		playback.Step()
+	}
+
+	got := positions(playback.World())
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %d positions, got %d", len(expected), len(got))
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Expected entity %d at %v, got %v", i, expected[i], got[i])
+		}
+	}
+	if !playback.World().Player(0).IsDead {
+		t.Error("Expected the player who left to be dead")
+	}
+}
+
+func TestPlayback_Seek(t *testing.T) {
+	recorder, expected := recordTestMatch(t)
+
+	playback, err := NewPlayback(recorder.Replay())
+	if err != nil {
+		t.Fatalf("NewPlayback failed: %v", err)
+	}
+
+	if err := playback.Seek(200); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	if err := playback.Seek(20); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	if playback.Tick() != 20 || len(playback.World().Players()) != 1 {
+		t.Errorf("Expected tick 20 with a single player, got tick %d with %d players", playback.Tick(), len(playback.World().Players()))
+	}
+
+	if err := playback.Seek(1000); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	if !playback.Done() || positions(playback.World())[1] != expected[1] {
+		t.Error("Expected seeking past the end to finish the replay where the recording ended")
+	}
+}
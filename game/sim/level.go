@@ -0,0 +1,173 @@
+// Package sim provides a headless simulation of a match, including loading levels from text files.
+package sim
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/collider"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/entities"
+)
+
+// levelRows is the number of terrain rows at the top of a level file.
+// Every line after the terrain describes a single entity as "TYPE X Y" in tile coordinates.
+const levelRows = 17
+
+// LoadLevelFromFile loads a level from the text file at the given path.
+//
+// Parameters:
+//   - path: The path to the text file containing the level data.
+//   - seed: The seed of the random source of the world.
+//
+// Returns:
+//   - *World: The world initialized with the level.
+//   - error: An error if the file cannot be read.
+func LoadLevelFromFile(path string, seed int64) (*World, error) {
+	textLevel, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening level file: %w", err)
+	}
+	defer textLevel.Close()
+
+	return LoadLevel(textLevel, seed)
+}
+
+// LoadLevel builds a world from level data.
+// It initializes the world with terrain, spawn points, status effects, boxes, and monsters.
+//
+// Parameters:
+//   - r: The reader providing the level data.
+//   - seed: The seed of the random source of the world.
+//
+// Returns:
+//   - *World: The world initialized with the level.
+//   - error: An error if the level data cannot be read or has no terrain.
+func LoadLevel(r io.Reader, seed int64) (*World, error) {
+	w := &World{
+		collisionSpace: collider.NewSpatialHash(TileSize),
+		seed:           seed,
+		rng:            rand.New(rand.NewSource(seed)),
+		clock:          entities.NewClock(),
+	}
+
+	scanner := bufio.NewScanner(r)
+	rowIndex := 0
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		parts := strings.Split(line, " ")
+
+		if rowIndex < levelRows {
+			row := make([]entities.Terrain, len(parts))
+			for colIndex, char := range parts {
+				xPos := float64(colIndex * TileSize)
+				yPos := float64(rowIndex * TileSize)
+
+				if char == "SOLID" {
+					row[colIndex] = entities.NewWall(w.collisionSpace, xPos, yPos, "assets/map/solid_block.png")
+				} else {
+					row[colIndex] = entities.NewGrass(w.collisionSpace, xPos, yPos, "assets/map/grass_block.png")
+				}
+			}
+			w.terrain = append(w.terrain, row)
+			rowIndex++
+
+			continue
+		}
+
+		if len(parts) < 3 {
+			log.Printf("Invalid entity format: %s", line)
+			continue
+		}
+
+		x, errX := strconv.Atoi(parts[1])
+		y, errY := strconv.Atoi(parts[2])
+		if errX != nil || errY != nil {
+			log.Printf("Invalid coordinates in line: %s", line)
+			continue
+		}
+
+		w.addLevelEntity(parts[0], float64(x*TileSize), float64(y*TileSize))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading level: %w", err)
+	}
+
+	if len(w.terrain) == 0 || len(w.terrain[0]) == 0 {
+		return nil, fmt.Errorf("level has no terrain")
+	}
+
+	return w, nil
+}
+
+// The kinds of monsters, named as in the level files.
+const (
+	MonsterGhost   = "GHOST"
+	MonsterSlime   = "SLIME"
+	MonsterBalloon = "BALLOON"
+	MonsterOnion   = "ONION"
+)
+
+// MonsterKind returns the kind of a monster, named as in the level files.
+func MonsterKind(m entities.Monster) string {
+	switch m.(type) {
+	case *entities.Ghost:
+		return MonsterGhost
+	case *entities.Ballon:
+		return MonsterBalloon
+	case *entities.Onion:
+		return MonsterOnion
+	default:
+		return MonsterSlime
+	}
+}
+
+// addLevelEntity adds an entity described by a level file line to the world.
+//
+// Parameters:
+//   - kind: The entity type as written in the level file.
+//   - xPos: The x coordinate of the entity in the game world.
+//   - yPos: The y coordinate of the entity in the game world.
+func (w *World) addLevelEntity(kind string, xPos, yPos float64) {
+	switch kind {
+	case "PLAYER":
+		w.spawnPoints = append(w.spawnPoints, Position{X: xPos, Y: yPos})
+	case "RADIUSINC":
+		w.effects = append(w.effects, entities.NewRadiusEffect(w.collisionSpace, xPos, yPos))
+	case "SKULLDEB":
+		w.effects = append(w.effects, entities.NewSkullDebuff(w.collisionSpace, xPos, yPos, w.rng))
+	case "BOMBINC":
+		w.effects = append(w.effects, entities.NewBombEffect(w.collisionSpace, xPos, yPos))
+	case "INVINC":
+		w.effects = append(w.effects, entities.NewInvincibilityEffect(w.collisionSpace, xPos, yPos))
+	case "DETONATOR":
+		w.effects = append(w.effects, entities.NewDetonatorEffect(w.collisionSpace, xPos, yPos))
+	case "ROLLER":
+		w.effects = append(w.effects, entities.NewRollerEffect(w.collisionSpace, xPos, yPos))
+	case "GHOSTINC":
+		w.effects = append(w.effects, entities.NewGhostEffect(w.collisionSpace, xPos, yPos))
+	case "OBSTACLE":
+		w.effects = append(w.effects, entities.NewObstacleEffect(w.collisionSpace, xPos, yPos))
+	case MonsterGhost:
+		w.monsters = append(w.monsters, entities.NewGhost(w.collisionSpace, xPos, yPos, float64(w.Width()), float64(w.Height()), w.rng, w.clock))
+	case MonsterSlime:
+		w.monsters = append(w.monsters, entities.NewSlime(w.collisionSpace, xPos, yPos, w.rng))
+	case MonsterBalloon:
+		w.monsters = append(w.monsters, entities.NewBallon(w.collisionSpace, xPos, yPos, w.rng))
+	case MonsterOnion:
+		w.monsters = append(w.monsters, entities.NewOnion(w.collisionSpace, xPos, yPos, w.rng))
+	case "BOX":
+		w.boxes = append(w.boxes, entities.NewBox(w.collisionSpace, xPos, yPos, false))
+	default:
+		log.Printf("Unknown entity type: %s", kind)
+	}
+}
@@ -0,0 +1,448 @@
+// Package sim provides a headless simulation of a match. The World owns every entity
+// of a level and advances them by applying the rules of the game, without drawing anything.
+package sim
+
+import (
+	"image/color"
+	"log"
+	"math/rand"
+
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/collider"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/entities"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/userinfo"
+)
+
+// TileSize is the width and height of a single tile in the game world.
+const TileSize = 16
+
+// PlayerID identifies a player within a world.
+type PlayerID int
+
+// Constants representing what killed a player.
+const (
+	DeathExplosion = "EXPLOSION" // The player was caught in an explosion.
+	DeathMonster   = "MONSTER"   // The player ran into a monster.
+)
+
+// Position represents a point in the game world.
+type Position struct {
+	X, Y float64 // The x and y coordinates of the point.
+}
+
+// TerrainChange represents a tile of the terrain that was replaced during the match.
+type TerrainChange struct {
+	X, Y int    // The tile coordinates of the changed terrain.
+	To   string // The new terrain type.
+}
+
+// Player represents a player taking part in the simulated match.
+type Player struct {
+	*entities.Player          // The player entity controlled by the inputs.
+	ID               PlayerID // The identifier of the player within the world.
+	IsDead           bool     // Whether the player has been killed.
+	DeathCause       string   // What killed the player, one of the Death constants, or empty if it was removed from the match.
+	Killer           *Player  // The player whose bomb blew the player up, or nil.
+}
+
+// World represents the state of a match and applies the rules of the game to it.
+type World struct {
+	collisionSpace *collider.SpatialHash // The collision space for the entities of the world.
+	terrain        [][]entities.Terrain  // The terrain of the level, indexed by row and then column.
+	spawnPoints    []Position            // The player spawn points defined by the level.
+	players        []*Player             // The players of the match, indexed by their ID.
+	monsters       []entities.Monster    // The monsters of the match.
+	bombs          []*entities.Bomb      // The bombs placed by the players.
+	explosions     []*entities.Explosion // The explosions of the detonated bombs.
+	boxes          []*entities.Box       // The boxes that can be blown up.
+	effects        []entities.Effect     // The status effects that can be picked up.
+	terrainChanges []TerrainChange       // The terrain changes since the start of the match.
+	clock          *entities.Clock       // The clock counting the steps simulated so far.
+	seed           int64                 // The seed of the random source.
+	rng            *rand.Rand            // The random source of every random decision in the match.
+}
+
+// CollisionSpace returns the collision space of the world.
+func (w *World) CollisionSpace() *collider.SpatialHash {
+	return w.collisionSpace
+}
+
+// Terrain returns the terrain of the level, indexed by row and then column.
+func (w *World) Terrain() [][]entities.Terrain {
+	return w.terrain
+}
+
+// SpawnPoints returns the player spawn points defined by the level.
+func (w *World) SpawnPoints() []Position {
+	return w.spawnPoints
+}
+
+// Players returns the players of the match, indexed by their ID.
+func (w *World) Players() []*Player {
+	return w.players
+}
+
+// Player returns the player with the given ID, or nil if there is no such player.
+func (w *World) Player(id PlayerID) *Player {
+	if id < 0 || int(id) >= len(w.players) {
+		return nil
+	}
+
+	return w.players[id]
+}
+
+// Monsters returns the monsters of the match.
+func (w *World) Monsters() []entities.Monster {
+	return w.monsters
+}
+
+// Bombs returns the bombs currently placed in the world.
+func (w *World) Bombs() []*entities.Bomb {
+	return w.bombs
+}
+
+// Explosions returns the explosions currently in the world.
+func (w *World) Explosions() []*entities.Explosion {
+	return w.explosions
+}
+
+// Boxes returns the boxes currently in the world.
+func (w *World) Boxes() []*entities.Box {
+	return w.boxes
+}
+
+// Effects returns the status effects that can currently be picked up.
+func (w *World) Effects() []entities.Effect {
+	return w.effects
+}
+
+// TerrainChanges returns the terrain changes since the start of the match, in the order they happened.
+func (w *World) TerrainChanges() []TerrainChange {
+	return w.terrainChanges
+}
+
+// Seed returns the seed the random source of the world was created with.
+// Two worlds loaded from the same level with the same seed play out identically given the same inputs.
+func (w *World) Seed() int64 {
+	return w.seed
+}
+
+// Tick returns the number of steps simulated so far.
+func (w *World) Tick() uint64 {
+	return w.clock.Now()
+}
+
+// Clock returns the clock shared by the entities of the world.
+func (w *World) Clock() *entities.Clock {
+	return w.clock
+}
+
+// Width returns the width of the level in the game world.
+func (w *World) Width() int {
+	if len(w.terrain) == 0 {
+		return 0
+	}
+
+	return TileSize * len(w.terrain[0])
+}
+
+// Height returns the height of the level in the game world.
+func (w *World) Height() int {
+	return TileSize * len(w.terrain)
+}
+
+// AddPlayer adds a new player to the match at the specified position.
+//
+// Parameters:
+//   - x: The x coordinate of the player.
+//   - y: The y coordinate of the player.
+//   - userData: The user data associated with the player.
+//   - colorOverlay: The color overlay of the player sprite.
+//
+// Returns:
+//   - *Player: The added player.
+func (w *World) AddPlayer(x, y float64, userData *userinfo.UserInfo, colorOverlay color.Color) *Player {
+	p := &Player{
+		Player: entities.NewPlayer(w.collisionSpace, x, y, userData, colorOverlay),
+		ID:     PlayerID(len(w.players)),
+	}
+	w.players = append(w.players, p)
+
+	return p
+}
+
+// KillPlayer marks the player with the given ID as dead and removes it from the collision space.
+func (w *World) KillPlayer(id PlayerID) {
+	w.kill(w.Player(id), "", nil)
+}
+
+// kill marks the player as dead for the given cause and removes it from the collision space.
+func (w *World) kill(p *Player, cause string, killer *Player) {
+	if p == nil || p.IsDead {
+		return
+	}
+
+	p.IsDead = true
+	p.DeathCause = cause
+	p.Killer = killer
+	w.collisionSpace.Remove(p.GetCollider())
+}
+
+// playerOf returns the player of the match controlling the given entity, or nil.
+func (w *World) playerOf(entity *entities.Player) *Player {
+	for _, p := range w.players {
+		if p.Player == entity {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// AlivePlayers returns the players of the match that are still alive.
+func (w *World) AlivePlayers() []*Player {
+	alive := make([]*Player, 0, len(w.players))
+	for _, p := range w.players {
+		if !p.IsDead {
+			alive = append(alive, p)
+		}
+	}
+
+	return alive
+}
+
+// Step advances the world by a single tick using the given player controls.
+// Players without an entry in inputs are treated as not pressing anything.
+//
+// Parameters:
+//   - inputs: The controls of the players for this tick.
+func (w *World) Step(inputs map[PlayerID]entities.PlayerControls) {
+	w.clock.Advance()
+
+	w.updateMonsters()
+
+	for _, p := range w.players {
+		if p.IsDead {
+			continue
+		}
+
+		p.Control = inputs[p.ID]
+		w.updatePlayer(p)
+	}
+
+	w.updateBombs()
+	w.updateExplosions()
+
+	for i := range w.effects {
+		w.effects[i].Update()
+	}
+
+	for _, box := range w.boxes {
+		box.Update()
+	}
+}
+
+// updateMonsters points the monsters at a random living player and moves them.
+func (w *World) updateMonsters() {
+	alive := w.AlivePlayers()
+
+	for _, monster := range w.monsters {
+		if len(alive) > 0 {
+			monster.SetTarget(alive[w.rng.Intn(len(alive))].Player)
+		}
+		monster.Update()
+	}
+}
+
+// updatePlayer applies the controls of a player and resolves its collisions with the rest of the world.
+func (w *World) updatePlayer(p *Player) {
+	newBomb, newBox, err := p.Update()
+	if err != nil {
+		log.Println(err)
+	}
+
+	if newBomb != nil {
+		w.bombs = append(w.bombs, newBomb)
+	}
+
+	if newBox != nil {
+		w.boxes = append(w.boxes, newBox)
+	}
+
+	for _, collision := range entities.CheckCollisions(p.GetCollider()) {
+		sep := collision.SeparatingVector
+
+		switch collidingEntity := collision.Other.GetParent().(type) {
+		case nil:
+			break
+		case *entities.Bomb, *entities.Box, entities.Terrain:
+			if blocks(p.Player, collidingEntity) {
+				p.GetCollider().Move(sep.X, sep.Y)
+			}
+		case *entities.Explosion:
+			if !hasState(p, "InvincibilityIncrease") {
+				w.kill(p, DeathExplosion, w.playerOf(collidingEntity.Owner))
+				return
+			}
+		case entities.Effect:
+			w.pickUpEffect(p, collision.Other)
+		case entities.Monster:
+			if !hasState(p, "InvincibilityIncrease") {
+				w.kill(p, DeathMonster, nil)
+				return
+			}
+		}
+	}
+}
+
+// hasState reports whether the player is under the status effect with the given name.
+func hasState(p *Player, name string) bool {
+	return p.State != nil && p.State.GetName() == name
+}
+
+// blocks reports whether an entity stops the player from moving through it.
+func blocks(p *entities.Player, other any) bool {
+	switch other := other.(type) {
+	case *entities.Bomb, *entities.Box:
+		return p.State == nil || p.State.GetName() != "GhostIncrease"
+	case entities.Terrain:
+		return other.IsSolid()
+	}
+
+	return false
+}
+
+// PredictMovement moves a player by a single tick of the given controls, pushing it out of the entities
+// blocking its way exactly like Step does. Unlike Step, it never places bombs, picks up effects or kills
+// the player, so clients can use it to predict the movement of their own player ahead of the server.
+//
+// Parameters:
+//   - p: The player to move.
+//   - controls: The controls pressed by the player.
+func PredictMovement(p *entities.Player, controls entities.PlayerControls) {
+	if err := p.Move(controls); err != nil {
+		log.Println(err)
+	}
+
+	for _, collision := range entities.CheckCollisions(p.GetCollider()) {
+		if blocks(p, collision.Other.GetParent()) {
+			p.GetCollider().Move(collision.SeparatingVector.X, collision.SeparatingVector.Y)
+		}
+	}
+}
+
+// pickUpEffect applies the status effect owning the given collider to the player and removes it from the world.
+func (w *World) pickUpEffect(p *Player, shape collider.Shape) {
+	for i, effect := range w.effects {
+		if effect.GetCollider() == shape {
+			p.State = effect.StatusEffect
+			w.collisionSpace.Remove(shape)
+			w.effects = append(w.effects[:i], w.effects[i+1:]...)
+
+			return
+		}
+	}
+}
+
+// updateBombs counts down the bombs and detonates the ones that ran out of time.
+func (w *World) updateBombs() {
+	for i := 0; i < len(w.bombs); i++ {
+		bomb := w.bombs[i]
+		if !bomb.Update() {
+			continue
+		}
+
+		w.detonate(bomb)
+
+		if bomb.Owner != nil {
+			bomb.Owner.NumberOfBombs++
+		}
+		w.collisionSpace.Remove(bomb.GetCollider())
+		w.bombs = append(w.bombs[:i], w.bombs[i+1:]...)
+		i-- // Adjust index since we removed an element
+	}
+}
+
+// detonate spawns the explosions of a bomb in its tile and in the four directions up to its range.
+// The blast stops at the first solid tile that cannot be destroyed.
+func (w *World) detonate(bomb *entities.Bomb) {
+	x, y := bomb.TilePosition()
+	w.explode(bomb, x, y)
+
+	directions := [4][2]int{{0, 1}, {0, -1}, {1, 0}, {-1, 0}}
+	for _, direction := range directions {
+		for j := 1; j <= bomb.ExplosionRange; j++ {
+			tileX, tileY := x+direction[0]*j, y+direction[1]*j
+			if !w.blastPassable(tileX, tileY) {
+				break
+			}
+			w.explode(bomb, tileX, tileY)
+		}
+	}
+}
+
+// explode spawns an explosion of a bomb in the tile at the given coordinates.
+func (w *World) explode(bomb *entities.Bomb, x, y int) {
+	explosion := entities.NewExplosion(w.collisionSpace, float64(x*TileSize), float64(y*TileSize))
+	explosion.Owner = bomb.Owner
+	w.explosions = append(w.explosions, explosion)
+}
+
+// blastPassable reports whether an explosion can reach the tile at the given coordinates.
+func (w *World) blastPassable(x, y int) bool {
+	tile := w.tileAt(x, y)
+
+	return tile != nil && (tile.IsDestroyable() || !tile.IsSolid())
+}
+
+// tileAt returns the terrain at the given tile coordinates, or nil if they are outside of the level.
+func (w *World) tileAt(x, y int) entities.Terrain {
+	if y < 0 || y >= len(w.terrain) || x < 0 || x >= len(w.terrain[y]) {
+		return nil
+	}
+
+	return w.terrain[y][x]
+}
+
+// updateExplosions advances the explosions, blowing up the boxes and destroyable terrain they reach.
+func (w *World) updateExplosions() {
+	for i := 0; i < len(w.explosions); i++ {
+		explosion := w.explosions[i]
+		if explosion.Update() {
+			w.collisionSpace.Remove(explosion.GetCollider())
+			w.explosions = append(w.explosions[:i], w.explosions[i+1:]...)
+			i-- // Adjust index since we removed an element
+
+			continue
+		}
+
+		exploX, exploY := explosion.TilePosition()
+		w.destroyBoxesAt(exploX, exploY)
+
+		if tile := w.tileAt(exploX, exploY); tile != nil && tile.IsDestroyable() {
+			w.collisionSpace.Remove(tile.GetCollider())
+			w.terrain[exploY][exploX] = entities.NewGrass(w.collisionSpace, float64(exploX*TileSize), float64(exploY*TileSize), "assets/map/grass_block.png")
+			w.terrainChanges = append(w.terrainChanges, TerrainChange{X: exploX, Y: exploY, To: "GRASS"})
+		}
+	}
+}
+
+// destroyBoxesAt removes the boxes in the given tile, dropping their status effects.
+func (w *World) destroyBoxesAt(x, y int) {
+	for j := 0; j < len(w.boxes); j++ {
+		box := w.boxes[j]
+		boxX, boxY := box.TilePosition()
+		if boxX != x || boxY != y {
+			continue
+		}
+
+		if !box.IsBlank {
+			newEffect := box.DropRandomStatusEffect(w.rng)
+			if newEffect.StatusEffect != nil {
+				w.effects = append(w.effects, newEffect)
+			}
+		}
+		w.collisionSpace.Remove(box.GetCollider())
+		w.boxes = append(w.boxes[:j], w.boxes[j+1:]...)
+		j-- // Adjust index since we removed an element
+	}
+}
@@ -0,0 +1,242 @@
+package sim
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/entities"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/userinfo"
+)
+
+// testLevel builds a level surrounded by walls, followed by the given entity lines.
+func testLevel(entityLines ...string) string {
+	var b strings.Builder
+	for row := 0; row < levelRows; row++ {
+		tiles := make([]string, levelRows)
+		for col := range tiles {
+			if row == 0 || col == 0 || row == levelRows-1 || col == levelRows-1 {
+				tiles[col] = "SOLID"
+			} else {
+				tiles[col] = "GRASS"
+			}
+		}
+		b.WriteString(strings.Join(tiles, " "))
+		b.WriteString("\n")
+	}
+	for _, line := range entityLines {
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+func loadTestWorld(t *testing.T, entityLines ...string) *World {
+	t.Helper()
+
+	return loadSeededTestWorld(t, 1, entityLines...)
+}
+
+func loadSeededTestWorld(t *testing.T, seed int64, entityLines ...string) *World {
+	t.Helper()
+
+	w, err := LoadLevel(strings.NewReader(testLevel(entityLines...)), seed)
+	if err != nil {
+		t.Fatalf("LoadLevel failed: %v", err)
+	}
+
+	return w
+}
+
+// addTestPlayer adds a player slightly off the origin of the given tile, so it overlaps
+// the bombs and explosions placed in that tile instead of sharing their exact center.
+func addTestPlayer(w *World, tileX, tileY int) *Player {
+	return w.AddPlayer(float64(tileX*TileSize+3), float64(tileY*TileSize+3), &userinfo.UserInfo{Username: "Player"}, color.Black)
+}
+
+func TestLoadLevel(t *testing.T) {
+	w := loadTestWorld(t, "PLAYER 1 1", "BOX 3 3", "BOMBINC 5 5", "GHOST 6 6", "UNKNOWN 1 1")
+
+	if w.Width() != levelRows*TileSize || w.Height() != levelRows*TileSize {
+		t.Errorf("Expected a %dx%d level, got %dx%d", levelRows*TileSize, levelRows*TileSize, w.Width(), w.Height())
+	}
+	if len(w.SpawnPoints()) != 1 || w.SpawnPoints()[0] != (Position{X: 16, Y: 16}) {
+		t.Errorf("Expected a single spawn point at (16, 16), got %v", w.SpawnPoints())
+	}
+	if len(w.Boxes()) != 1 {
+		t.Errorf("Expected 1 box, got %d", len(w.Boxes()))
+	}
+	if len(w.Effects()) != 1 {
+		t.Errorf("Expected 1 status effect, got %d", len(w.Effects()))
+	}
+	if len(w.Monsters()) != 1 {
+		t.Errorf("Expected 1 monster, got %d", len(w.Monsters()))
+	}
+	if !w.Terrain()[0][5].IsSolid() || w.Terrain()[5][5].IsSolid() {
+		t.Error("Expected the border to be solid and the inside to be grass")
+	}
+}
+
+func TestLoadLevel_Empty(t *testing.T) {
+	if _, err := LoadLevel(strings.NewReader(""), 1); err == nil {
+		t.Error("Expected an error for a level without terrain")
+	}
+}
+
+func TestStep_PlayerMoves(t *testing.T) {
+	w := loadTestWorld(t)
+	p := addTestPlayer(w, 5, 5)
+
+	for i := 0; i < 10; i++ {
+		w.Step(map[PlayerID]entities.PlayerControls{p.ID: {Right: true}})
+	}
+
+	x, y := p.GetPosition()
+	if x <= 5*TileSize+3 || y != 5*TileSize+3 {
+		t.Errorf("Expected the player to move right from (83, 83), got (%f, %f)", x, y)
+	}
+	if w.Tick() != 10 {
+		t.Errorf("Expected 10 ticks to be simulated, got %d", w.Tick())
+	}
+}
+
+func TestPredictMovement_MatchesStep(t *testing.T) {
+	stepped := loadTestWorld(t, "BOX 2 5")
+	predicted := loadTestWorld(t, "BOX 2 5")
+	p := addTestPlayer(stepped, 5, 5)
+	q := addTestPlayer(predicted, 5, 5)
+
+	controls := []entities.PlayerControls{{Left: true}, {Left: true, Up: true}, {Down: true}, {Right: true, Down: true}}
+	for i := 0; i < 400; i++ {
+		stepped.Step(map[PlayerID]entities.PlayerControls{p.ID: controls[i/100]})
+		PredictMovement(q.Player, controls[i/100])
+
+		px, py := p.GetPosition()
+		qx, qy := q.GetPosition()
+		if px != qx || py != qy {
+			t.Fatalf("Expected the prediction to match the simulation at tick %d, got (%f, %f) and (%f, %f)", i, qx, qy, px, py)
+		}
+	}
+}
+
+func TestStep_BombKillsOwner(t *testing.T) {
+	w := loadTestWorld(t)
+	p := addTestPlayer(w, 5, 5)
+
+	w.Step(map[PlayerID]entities.PlayerControls{p.ID: {Ability1: true}})
+	if len(w.Bombs()) != 1 {
+		t.Fatalf("Expected 1 bomb to be placed, got %d", len(w.Bombs()))
+	}
+
+	for i := 0; i < 180 && !p.IsDead; i++ {
+		w.Step(nil)
+	}
+
+	if len(w.Bombs()) != 0 {
+		t.Errorf("Expected the bomb to be detonated, got %d bombs", len(w.Bombs()))
+	}
+	if len(w.Explosions()) == 0 {
+		t.Error("Expected the bomb to leave explosions")
+	}
+	if !p.IsDead {
+		t.Error("Expected the player standing on the bomb to die")
+	}
+	if p.DeathCause != DeathExplosion || p.Killer != p {
+		t.Errorf("Expected the player to be blown up by its own bomb, got %q by %v", p.DeathCause, p.Killer)
+	}
+	if len(w.AlivePlayers()) != 0 {
+		t.Errorf("Expected no living players, got %d", len(w.AlivePlayers()))
+	}
+}
+
+func TestStep_ExplosionDestroysBox(t *testing.T) {
+	w := loadTestWorld(t, "BOX 6 5")
+	p := addTestPlayer(w, 5, 5)
+	p.State = entities.NewInvincibilityInc()
+
+	w.Step(map[PlayerID]entities.PlayerControls{p.ID: {Ability1: true}})
+	for i := 0; i < 181; i++ {
+		w.Step(nil)
+	}
+
+	if len(w.Boxes()) != 0 {
+		t.Errorf("Expected the box to be blown up, got %d boxes", len(w.Boxes()))
+	}
+	if p.IsDead {
+		t.Error("Expected the invincible player to survive")
+	}
+}
+
+func TestStep_ExplosionStopsAtWalls(t *testing.T) {
+	w := loadTestWorld(t)
+	p := addTestPlayer(w, 1, 1)
+	p.State = entities.NewInvincibilityInc()
+
+	w.Step(map[PlayerID]entities.PlayerControls{p.ID: {Ability1: true}})
+	for i := 0; i < 180; i++ {
+		w.Step(nil)
+	}
+
+	// The bomb in the corner reaches its own tile and two tiles right and down.
+	if len(w.Explosions()) != 5 {
+		t.Errorf("Expected 5 explosions, got %d", len(w.Explosions()))
+	}
+}
+
+func TestKillPlayer(t *testing.T) {
+	w := loadTestWorld(t)
+	p := addTestPlayer(w, 5, 5)
+
+	w.KillPlayer(p.ID)
+	w.Step(map[PlayerID]entities.PlayerControls{p.ID: {Right: true}})
+
+	if !p.IsDead {
+		t.Error("Expected the player to be dead")
+	}
+	if p.DeathCause != "" || p.Killer != nil {
+		t.Errorf("Expected a player removed from the match to have no cause of death, got %q by %v", p.DeathCause, p.Killer)
+	}
+	if x, _ := p.GetPosition(); x != 5*TileSize+3 {
+		t.Errorf("Expected a dead player not to move, got x = %f", x)
+	}
+	if w.Player(42) != nil {
+		t.Error("Expected no player for an unknown ID")
+	}
+}
+
+func TestStep_SameSeedSameMatch(t *testing.T) {
+	entityLines := []string{"SLIME 8 8", "BALLOON 10 3", "ONION 3 10", "GHOST 12 12", "BOX 6 5", "BOX 5 6", "BOX 4 5"}
+	worlds := []*World{loadSeededTestWorld(t, 42, entityLines...), loadSeededTestWorld(t, 42, entityLines...)}
+
+	for _, w := range worlds {
+		addTestPlayer(w, 5, 5).State = entities.NewInvincibilityInc()
+		addTestPlayer(w, 13, 13)
+	}
+
+	controls := []entities.PlayerControls{{Ability1: true}, {Up: true}, {Left: true}, {Down: true}, {Right: true}}
+	for i := 0; i < 600; i++ {
+		inputs := map[PlayerID]entities.PlayerControls{0: controls[i/40%len(controls)], 1: controls[i/25%len(controls)]}
+		for _, w := range worlds {
+			w.Step(inputs)
+		}
+	}
+
+	for i, m := range worlds[0].Monsters() {
+		x1, y1 := m.GetCollider().GetPosition().X, m.GetCollider().GetPosition().Y
+		x2, y2 := worlds[1].Monsters()[i].GetCollider().GetPosition().X, worlds[1].Monsters()[i].GetCollider().GetPosition().Y
+		if x1 != x2 || y1 != y2 {
+			t.Errorf("Expected monster %d at (%f, %f), got (%f, %f)", i, x1, y1, x2, y2)
+		}
+	}
+	for i, p := range worlds[0].Players() {
+		x1, y1 := p.GetPosition()
+		x2, y2 := worlds[1].Players()[i].GetPosition()
+		if x1 != x2 || y1 != y2 || p.IsDead != worlds[1].Players()[i].IsDead {
+			t.Errorf("Expected player %d at (%f, %f), got (%f, %f)", i, x1, y1, x2, y2)
+		}
+	}
+	if len(worlds[0].Effects()) != len(worlds[1].Effects()) {
+		t.Errorf("Expected %d status effects, got %d", len(worlds[0].Effects()), len(worlds[1].Effects()))
+	}
+}
@@ -4,11 +4,11 @@ go 1.22
 
 require (
 	github.com/ebitenui/ebitenui v0.5.6
+	github.com/gin-gonic/gin v1.9.1
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0
 	github.com/google/uuid v1.6.0
 	github.com/gorilla/websocket v1.5.1
 	github.com/hajimehoshi/ebiten/v2 v2.7.1
-	github.com/vcscsvcscs/ebiten-collider v0.0.0-20240412110634-a7cf22ffbfaf
 	golang.org/x/image v0.15.0
 )
 
@@ -23,7 +23,6 @@ require (
 	github.com/ebitengine/purego v0.7.1 // indirect
 	github.com/gabriel-vasile/mimetype v1.4.3 // indirect
 	github.com/gin-contrib/sse v0.1.0 // indirect
-	github.com/gin-gonic/gin v1.9.1 // indirect
 	github.com/go-playground/locales v0.14.1 // indirect
 	github.com/go-playground/universal-translator v0.18.1 // indirect
 	github.com/go-playground/validator/v10 v10.19.0 // indirect
@@ -34,7 +33,6 @@ require (
 	github.com/klauspost/cpuid/v2 v2.2.7 // indirect
 	github.com/leodido/go-urn v1.4.0 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
-	github.com/melonfunction/ebiten-vector v0.0.0-20220811130512-a1f6614eadea // indirect
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.2 // indirect
 	github.com/pelletier/go-toml/v2 v2.2.1 // indirect
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/multiplayer"
 )
 
 const (
@@ -19,9 +20,11 @@ const (
 func main() {
 	isMulti := false
 	for _, arg := range os.Args[1:] {
-		if strings.ToLower(arg) == "--multi" {
+		switch strings.ToLower(arg) {
+		case "--multi":
 			isMulti = true
-			break
+		case "--json-protocol":
+			multiplayer.PreferJSON = true
 		}
 	}
 
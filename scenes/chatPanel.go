@@ -0,0 +1,202 @@
+// Package scenes provides the implementation of various game scenes,
+// including the chat panel shared by the multiplayer lobby and game scenes.
+package scenes
+
+import (
+	"image/color"
+
+	"github.com/ebitenui/ebitenui"
+	uiimage "github.com/ebitenui/ebitenui/image"
+	"github.com/ebitenui/ebitenui/widget"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/assets"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/multiplayer"
+)
+
+// chatPanel represents the chat of a multiplayer game: the latest messages and the input for sending a new one.
+type chatPanel struct {
+	Container *widget.Container // The container of the panel, added to the user interface of the scene.
+	messages  *widget.Container // The container for the latest messages.
+	input     *widget.TextInput // The text input for the message being written.
+	lines     int               // The number of messages shown.
+	shown     uint64            // The identifier of the latest message shown.
+	send      func(text string) // Sends a message written by the player to the server.
+}
+
+// newChatPanel creates a new chat panel sending the messages of the player with the given function.
+//
+// Parameters:
+//   - send: Sends a message written by the player to the server.
+//   - lines: The number of messages shown.
+//
+// Returns:
+//   - *chatPanel: The initialized chat panel.
+func newChatPanel(send func(text string), lines int) *chatPanel {
+	p := &chatPanel{
+		Container: widget.NewContainer(
+			widget.ContainerOpts.BackgroundImage(uiimage.NewNineSliceColor(color.NRGBA{0x13, 0x1a, 0x22, 0xa0})),
+			widget.ContainerOpts.Layout(widget.NewRowLayout(
+				widget.RowLayoutOpts.Direction(widget.DirectionVertical),
+				widget.RowLayoutOpts.Padding(widget.NewInsetsSimple(5)),
+				widget.RowLayoutOpts.Spacing(5),
+			)),
+		),
+		messages: widget.NewContainer(
+			widget.ContainerOpts.Layout(widget.NewRowLayout(
+				widget.RowLayoutOpts.Direction(widget.DirectionVertical),
+				widget.RowLayoutOpts.Spacing(2),
+			)),
+			widget.ContainerOpts.WidgetOpts(
+				widget.WidgetOpts.MinSize(300, lines*14),
+			),
+		),
+		lines: lines,
+		send:  send,
+	}
+
+	p.input = widget.NewTextInput(
+		widget.TextInputOpts.WidgetOpts(
+			widget.WidgetOpts.LayoutData(widget.RowLayoutData{
+				Stretch:   true,
+				MaxHeight: 25,
+			}),
+		),
+		widget.TextInputOpts.Image(&widget.TextInputImage{
+			Idle:     uiimage.NewNineSliceColor(color.NRGBA{R: 75, G: 105, B: 47, A: 255}),
+			Disabled: uiimage.NewNineSliceColor(color.NRGBA{R: 100, G: 100, B: 100, A: 255}),
+		}),
+
+		widget.TextInputOpts.Face(assets.EbitenUIFont(10)),
+
+		widget.TextInputOpts.Color(&widget.TextInputColor{
+			Idle:          color.NRGBA{255, 255, 255, 255},
+			Disabled:      color.NRGBA{R: 200, G: 200, B: 200, A: 255},
+			Caret:         color.NRGBA{254, 255, 255, 255},
+			DisabledCaret: color.NRGBA{R: 200, G: 200, B: 200, A: 255},
+		}),
+
+		widget.TextInputOpts.Padding(widget.NewInsetsSimple(5)),
+
+		widget.TextInputOpts.ClearOnSubmit(true),
+		widget.TextInputOpts.IgnoreEmptySubmit(true),
+		widget.TextInputOpts.AllowDuplicateSubmit(true),
+
+		widget.TextInputOpts.SubmitHandler(func(args *widget.TextInputChangedEventArgs) {
+			p.send(args.InputText)
+		}),
+
+		widget.TextInputOpts.Validation(func(newInputText string) (bool, *string) {
+			return len([]rune(newInputText)) <= multiplayer.MaxChatLength, nil
+		}),
+
+		widget.TextInputOpts.CaretOpts(
+			widget.CaretOpts.Size(assets.EbitenUIFont(10), 2),
+		),
+
+		widget.TextInputOpts.Placeholder("Say something"),
+	)
+
+	p.Container.AddChild(p.messages)
+	p.Container.AddChild(p.input)
+
+	return p
+}
+
+// update shows the latest messages of the chat if new ones arrived.
+//
+// Parameters:
+//   - chat: The chat received from the server, oldest message first.
+func (p *chatPanel) update(chat []multiplayer.ProtoChatMessage) {
+	if len(chat) == 0 || chat[len(chat)-1].ID == p.shown {
+		return
+	}
+	p.shown = chat[len(chat)-1].ID
+
+	p.messages.RemoveChildren()
+	for _, message := range chat[max(0, len(chat)-p.lines):] {
+		label, textColor := message.From+": "+message.Text, color.NRGBA{254, 255, 255, 255}
+		if message.System {
+			label, textColor = message.Text, color.NRGBA{0xff, 0xd8, 0x4a, 0xff}
+		}
+
+		p.messages.AddChild(widget.NewText(
+			widget.TextOpts.Text(label, assets.EbitenUIFont(10), textColor),
+			widget.TextOpts.MaxWidth(300),
+		))
+	}
+}
+
+// focus moves the keyboard focus to the input of the panel, or away from it.
+func (p *chatPanel) focus(focused bool) {
+	p.input.Focus(focused)
+}
+
+// isTyping returns whether the player is writing a message.
+func (p *chatPanel) isTyping() bool {
+	return p.input.IsFocused()
+}
+
+// chatOverlay represents the chat shown over a running match. It is opened with Enter,
+// which also sends the message being written and closes it again, and closed with Escape.
+type chatOverlay struct {
+	ui    *ebitenui.UI // The user interface holding the chat panel.
+	panel *chatPanel   // The chat panel of the overlay.
+	open  bool         // Whether the overlay is shown.
+}
+
+// newChatOverlay creates a new, closed chat overlay sending the messages of the player with the given function.
+//
+// Parameters:
+//   - send: Sends a message written by the player to the server.
+//
+// Returns:
+//   - *chatOverlay: The initialized chat overlay.
+func newChatOverlay(send func(text string)) *chatOverlay {
+	panel := newChatPanel(send, 6)
+	panel.Container.GetWidget().LayoutData = widget.AnchorLayoutData{
+		HorizontalPosition: widget.AnchorLayoutPositionStart,
+		VerticalPosition:   widget.AnchorLayoutPositionEnd,
+	}
+
+	root := widget.NewContainer(widget.ContainerOpts.Layout(widget.NewAnchorLayout(
+		widget.AnchorLayoutOpts.Padding(widget.NewInsetsSimple(10)),
+	)))
+	root.AddChild(panel.Container)
+
+	return &chatOverlay{ui: &ebitenui.UI{Container: root}, panel: panel}
+}
+
+// update opens or closes the overlay on the key presses of the player and shows the latest messages.
+//
+// Parameters:
+//   - chat: The chat received from the server, oldest message first.
+func (o *chatOverlay) update(chat []multiplayer.ProtoChatMessage) {
+	o.panel.update(chat)
+
+	switch {
+	case !o.open && inpututil.IsKeyJustPressed(ebiten.KeyEnter):
+		o.open = true
+		o.panel.focus(true)
+	case o.open && inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		o.open = false
+		o.panel.focus(false)
+	case o.open:
+		// the text input sends the message on Enter before the overlay closes
+		o.ui.Update()
+		if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+			o.open = false
+			o.panel.focus(false)
+		}
+	}
+}
+
+// draw renders the overlay onto the screen if it is open.
+//
+// Parameters:
+//   - screen: The image to which the overlay is drawn.
+func (o *chatOverlay) draw(screen *ebiten.Image) {
+	if o.open {
+		o.ui.Draw(screen)
+	}
+}
@@ -0,0 +1,74 @@
+// Package scenes provides the implementation of various game scenes,
+// including the indicator of the quality of the connection to the game server shown during a multiplayer match.
+package scenes
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	text "github.com/hajimehoshi/ebiten/v2/text/v2"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/assets"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/multiplayer"
+)
+
+// The thresholds above which the quality of a connection is shown as degraded, and as bad at twice their value.
+const (
+	degradedRTT    = 100 * time.Millisecond
+	degradedJitter = 20 * time.Millisecond
+	degradedLoss   = 0.02
+)
+
+// The colors of the good, degraded and bad connections.
+var (
+	goodConnectionColor     = color.RGBA{0x5c, 0xd6, 0x5c, 0xff}
+	degradedConnectionColor = color.RGBA{0xf2, 0xc9, 0x4c, 0xff}
+	badConnectionColor      = color.RGBA{0xe8, 0x4a, 0x4a, 0xff}
+)
+
+// drawConnectionQuality draws the quality of the connection to the game server at the top right of the screen.
+// While the pings are held, the round-trip times the server measured to every player are listed below it.
+//
+// Parameters:
+//   - screen: The image to which the indicator is drawn.
+//   - quality: The quality of the connection measured by the client.
+//   - players: The players of the match, with the pings measured by the server.
+func drawConnectionQuality(screen *ebiten.Image, quality multiplayer.ConnectionQuality, players []multiplayer.ProtoPlayer) {
+	x := screen.Bounds().Dx() - 10
+	label := fmt.Sprintf("RTT %dms  jitter %dms  loss %d%%",
+		quality.RTT.Milliseconds(), quality.Jitter.Milliseconds(), int(math.Round(quality.Loss*100)))
+	assets.DrawTextWithShadow(screen, label, x, 10, 1, connectionColor(quality), text.AlignEnd, text.AlignStart)
+
+	if !ebiten.IsKeyPressed(ebiten.KeyTab) {
+		return
+	}
+
+	y := 28
+	for _, player := range players {
+		if player.IsDead && player.Ping == 0 {
+			continue
+		}
+		ping := fmt.Sprintf("%s %dms", player.Username, player.Ping.Milliseconds())
+		assets.DrawTextWithShadow(screen, ping, x, y, 1, rttColor(player.Ping), text.AlignEnd, text.AlignStart)
+		y += 18
+	}
+}
+
+// connectionColor returns the color the quality of a connection is shown in, after its worst measurement.
+func connectionColor(quality multiplayer.ConnectionQuality) color.Color {
+	switch {
+	case quality.RTT >= 2*degradedRTT || quality.Jitter >= 2*degradedJitter || quality.Loss >= 2*degradedLoss:
+		return badConnectionColor
+	case quality.RTT >= degradedRTT || quality.Jitter >= degradedJitter || quality.Loss >= degradedLoss:
+		return degradedConnectionColor
+	default:
+		return goodConnectionColor
+	}
+}
+
+// rttColor returns the color a round-trip time is shown in.
+func rttColor(rtt time.Duration) color.Color {
+	return connectionColor(multiplayer.ConnectionQuality{RTT: rtt})
+}
@@ -0,0 +1,67 @@
+// Package scenes provides the implementation of various game scenes,
+// including the mirrors keeping the entities of a multiplayer match in sync with the server.
+package scenes
+
+import (
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/collider"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/multiplayer"
+)
+
+// entityMirror mirrors the entities of a kind received from the server, matching them by their network identifiers.
+type entityMirror[T any] struct {
+	ids      []uint64               // The identifiers of the mirrored entities, in increasing order.
+	entities []T                    // The mirrored entities, in the order of their identifiers.
+	shapeOf  func(T) collider.Shape // Returns the collider of a mirrored entity.
+}
+
+// newEntityMirror creates an empty mirror for a kind of entities.
+//
+// Parameters:
+//   - shapeOf: The function returning the collider of a mirrored entity.
+//
+// Returns:
+//   - entityMirror[T]: The empty mirror.
+func newEntityMirror[T any](shapeOf func(T) collider.Shape) entityMirror[T] {
+	return entityMirror[T]{shapeOf: shapeOf}
+}
+
+// sync spawns the received entities the mirror does not know yet, moves the known ones to their received position
+// and despawns the ones the server removed, taking their colliders out of the collision space.
+//
+// Parameters:
+//   - space: The collision space of the mirrored entities.
+//   - received: The entities received from the server, in the increasing order of their identifiers.
+//   - spawn: The function creating the mirrored entity of a received one.
+func (m *entityMirror[T]) sync(space *collider.SpatialHash, received []multiplayer.ProtoEntity, spawn func(multiplayer.ProtoEntity) T) {
+	ids := make([]uint64, 0, len(received))
+	mirrored := make([]T, 0, len(received))
+
+	j := 0
+	for _, e := range received {
+		for ; j < len(m.ids) && m.ids[j] < e.ID; j++ {
+			space.Remove(m.shapeOf(m.entities[j]))
+		}
+
+		if j < len(m.ids) && m.ids[j] == e.ID {
+			entity := m.entities[j]
+			j++
+
+			if position := m.shapeOf(entity).GetPosition(); position.X != e.X || position.Y != e.Y {
+				m.shapeOf(entity).MoveTo(e.X, e.Y)
+			}
+			ids = append(ids, e.ID)
+			mirrored = append(mirrored, entity)
+
+			continue
+		}
+
+		ids = append(ids, e.ID)
+		mirrored = append(mirrored, spawn(e))
+	}
+
+	for ; j < len(m.ids); j++ {
+		space.Remove(m.shapeOf(m.entities[j]))
+	}
+
+	m.ids, m.entities = ids, mirrored
+}
@@ -2,25 +2,32 @@
 package scenes
 
 import (
+	"bytes"
+	"fmt"
 	"image/color"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
-	collider "github.com/vcscsvcscs/ebiten-collider"
 	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/entities"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/replay"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/sim"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/inputs"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/userinfo"
 )
 
-// GameScene represents a game scene containing all necessary entities and game state.
+// replayDir is the directory the replays of the played matches are saved to.
+const replayDir = "replays"
+
+// GameScene represents a game scene rendering a simulated match.
 type GameScene struct {
-	screenHeight   int                   // The height of the game screen.
-	screenWidth    int                   // The width of the game screen.
-	collisionSpace *collider.SpatialHash // The collision space for the game entities.
-	staticEntities [][]entities.Terrain  // The static entities in the game scene.
-	monsters       []entities.Monster    // The monsters in the game scene.
-	bombs          []*entities.Bomb      // The bombs in the game scene.
-	explosions     []*entities.Explosion // The explosions in the game scene.
-	boxes          []entities.Box        // The boxes in the game scene.
-	statusEffects  []entities.Effect     // The status effects in the game scene.
-	players        []entities.Player     // The players in the game scene.
+	screenHeight int              // The height of the game screen.
+	screenWidth  int              // The width of the game screen.
+	world        *sim.World       // The simulated match shown by the scene.
+	recorder     *replay.Recorder // The recorder of the match, or nil if the match is not recorded.
+	playerID     sim.PlayerID     // The player controlled by the local input.
 }
 
 // NewSinglePlayerGameScene creates a new single-player game scene by loading a level from a text file.
@@ -32,207 +39,144 @@ type GameScene struct {
 // Returns:
 //   - Scene: The initialized single-player game scene.
 func NewSinglePlayerGameScene(filepath string) Scene {
-	s := LoadLevelFromTextFile(filepath)
+	s := newRecordedGameScene(filepath, time.Now().UnixNano())
 
-	s.screenHeight = 16 * len(s.staticEntities)
-	s.screenWidth = 16 * len(s.staticEntities[0])
+	spawn := sim.Position{X: sim.TileSize, Y: sim.TileSize}
+	if spawnPoints := s.world.SpawnPoints(); len(spawnPoints) > 0 {
+		spawn = spawnPoints[0]
+	}
+	s.playerID = s.recorder.AddPlayer(spawn.X, spawn.Y, &userinfo.UserInfo{Username: "Player"}, color.Opaque).ID
 
 	return s
 }
 
-// Update updates the game scene based on the current game state.
-// This handles single-player game logic including player movement, monster updates, and collisions.
+// newGameScene creates a game scene rendering the given world, sized to fit its level.
 //
 // Parameters:
-//   - state: The current game state.
+//   - world: The simulated match shown by the scene.
 //
 // Returns:
-//   - error: An error if the update fails.
-func (s *GameScene) Update(state *GameState) error {
-	// Check if we have a player to control
-	if len(s.players) == 0 {
-		return nil
+//   - *GameScene: The initialized game scene.
+func newGameScene(world *sim.World) *GameScene {
+	return &GameScene{
+		world:        world,
+		screenHeight: world.Height(),
+		screenWidth:  world.Width(),
 	}
+}
 
-	// Update player controls
-	s.players[0].Control.Up = state.Input.StateForUp() > 0
-	s.players[0].Control.Down = state.Input.StateForDown() > 0
-	s.players[0].Control.Left = state.Input.StateForLeft() > 0
-	s.players[0].Control.Right = state.Input.StateForRight() > 0
-	s.players[0].Control.Ability1 = state.Input.IsAbilityOneJustPressed()
-	s.players[0].Control.Ability2 = state.Input.IsAbilityTwoJustPressed()
+// newRecordedGameScene creates a game scene for a recorded match on the level at the given path.
+//
+// Parameters:
+//   - filepath: The path to the text file containing the level data.
+//   - seed: The seed of the random source of the match.
+//
+// Returns:
+//   - *GameScene: The initialized game scene.
+func newRecordedGameScene(filepath string, seed int64) *GameScene {
+	level := readLevel(filepath)
+	s := newGameScene(loadWorld(level, seed))
+	s.recorder = replay.NewRecorder(s.world, level)
 
-	// Update monsters
-	for i := range s.monsters {
-		s.monsters[i].Update()
-		// Set target to player
-		if len(s.players) > 0 {
-			s.monsters[i].SetTarget(&s.players[0])
-		}
-	}
+	return s
+}
 
-	// Update player
-	newBomb, newBox, err := s.players[0].Update()
+// readLevel reads the level file at the given path, exiting the game if it cannot be read.
+//
+// Parameters:
+//   - filepath: The path to the text file containing the level data.
+//
+// Returns:
+//   - []byte: The contents of the level file.
+func readLevel(filepath string) []byte {
+	level, err := os.ReadFile(filepath)
 	if err != nil {
-		return err
-	}
-
-	// Handle new bomb placement
-	if newBomb != nil {
-		s.bombs = append(s.bombs, newBomb)
+		log.Fatal("Error reading level: ", err)
 	}
 
-	// Handle new box placement
-	if newBox != nil {
-		s.boxes = append(s.boxes, *newBox)
-	}
+	return level
+}
 
-	// Handle player collisions
-	playerCollision := s.collisionSpace.CheckCollisions(s.players[0].GetCollider())
-	for _, collision := range playerCollision {
-		sep := collision.SeparatingVector
-		switch collidingEntity := collision.Other.GetParent().(type) {
-		case nil:
-			break
-		case *entities.Bomb:
-			if !(s.players[0].State != nil && (s.players[0].State.GetName() == "GhostIncrease")) {
-				s.players[0].GetCollider().Move(sep.X, sep.Y)
-			}
-		case *entities.Explosion:
-			if !(s.players[0].State != nil && (s.players[0].State.GetName() == "InvincibilityIncrease")) {
-				// Player died - could restart level or go to game over
-				state.SceneManager.GoTo(NewMainMenuScene(s.screenWidth, s.screenHeight))
-				return nil
-			}
-		case *entities.Box:
-			if !(s.players[0].State != nil && (s.players[0].State.GetName() == "GhostIncrease")) {
-				s.players[0].GetCollider().Move(sep.X, sep.Y)
-			}
-		case entities.Effect:
-			s.players[0].State = collidingEntity.StatusEffect
-			// Remove the collected effect
-			var effectsToRemove []int
-			for j, effect := range s.statusEffects {
-				if effect.GetCollider() == collision.Other {
-					s.players[0].State = effect.StatusEffect
-					s.collisionSpace.Remove(effect.GetCollider())
-					effectsToRemove = append(effectsToRemove, j)
-				}
-			}
-			for _, index := range effectsToRemove {
-				if index < len(s.statusEffects)-1 {
-					s.statusEffects[index] = s.statusEffects[len(s.statusEffects)-1]
-				}
-				s.statusEffects = s.statusEffects[:len(s.statusEffects)-1]
-			}
-		case entities.Terrain:
-			if collidingEntity.IsSolid() {
-				s.players[0].GetCollider().Move(sep.X, sep.Y)
-			}
-		case entities.Monster:
-			if !(s.players[0].State != nil && (s.players[0].State.GetName() == "InvincibilityIncrease")) {
-				// Player died - restart level or go to game over
-				state.SceneManager.GoTo(NewMainMenuScene(s.screenWidth, s.screenHeight))
-				return nil
-			}
-		}
+// loadWorld loads a level, exiting the game if it is invalid.
+//
+// Parameters:
+//   - level: The contents of the level file.
+//   - seed: The seed of the random source of the world.
+//
+// Returns:
+//   - *sim.World: The world initialized with the level.
+func loadWorld(level []byte, seed int64) *sim.World {
+	world, err := sim.LoadLevel(bytes.NewReader(level), seed)
+	if err != nil {
+		log.Fatal("Error loading level: ", err)
 	}
 
-	// Update bombs and handle explosions
-	for i, bomb := range s.bombs {
-		if bomb.Update() {
-			x, y := bomb.TilePosition()
-			s.explosions = append(s.explosions, entities.NewExplosion(s.collisionSpace, float64(x*16), float64(y*16)))
-
-			// Create explosion pattern
-			for j := 1; j <= bomb.ExplosionRange; j++ {
-				if y+j < len(s.staticEntities) && (s.staticEntities[x][y+j].IsDestroyable() || !s.staticEntities[x][y+j].IsSolid()) {
-					s.explosions = append(s.explosions, entities.NewExplosion(s.collisionSpace, float64(x*16), float64((y+j)*16)))
-				} else {
-					break
-				}
-			}
-			for j := 1; j <= bomb.ExplosionRange; j++ {
-				if y-j >= 0 && (s.staticEntities[x][y-j].IsDestroyable() || !s.staticEntities[x][y-j].IsSolid()) {
-					s.explosions = append(s.explosions, entities.NewExplosion(s.collisionSpace, float64(x*16), float64((y-j)*16)))
-				} else {
-					break
-				}
-			}
-			for j := 1; j <= bomb.ExplosionRange; j++ {
-				if x+j < len(s.staticEntities) && (s.staticEntities[x+j][y].IsDestroyable() || !s.staticEntities[x+j][y].IsSolid()) {
-					s.explosions = append(s.explosions, entities.NewExplosion(s.collisionSpace, float64((x+j)*16), float64((y)*16)))
-				} else {
-					break
-				}
-			}
-			for j := 1; j <= bomb.ExplosionRange; j++ {
-				if x-j >= 0 && (s.staticEntities[x-j][y].IsDestroyable() || !s.staticEntities[x-j][y].IsSolid()) {
-					s.explosions = append(s.explosions, entities.NewExplosion(s.collisionSpace, float64((x-j)*16), float64(y*16)))
-				} else {
-					break
-				}
-			}
+	return world
+}
 
-			// Restore bomb count to player
-			s.bombs[i].Owner.NumberOfBombs++
-			s.collisionSpace.Remove(bomb.GetCollider())
+// saveReplay saves a recorded match to the replay directory.
+// Failing to save the replay does not interrupt the game, the error is only logged.
+//
+// Parameters:
+//   - r: The replay of the match, or nil if the match was not recorded.
+func saveReplay(r *replay.Replay) {
+	if r == nil {
+		return
+	}
 
-			// Remove bomb from slice
-			if i < len(s.bombs)-1 {
-				s.bombs[i] = s.bombs[len(s.bombs)-1]
-			}
-			s.bombs = s.bombs[:len(s.bombs)-1]
-		}
+	if err := os.MkdirAll(replayDir, 0o755); err != nil {
+		log.Println("Failed to create replay directory", err)
+		return
 	}
 
-	// Update explosions and handle destruction
-	for i := 0; i < len(s.explosions); i++ {
-		if s.explosions[i].Update() {
-			s.collisionSpace.Remove(s.explosions[i].GetCollider())
-			// Remove explosion from slice
-			if i < len(s.explosions)-1 {
-				s.explosions[i] = s.explosions[len(s.explosions)-1]
-			}
-			s.explosions = s.explosions[:len(s.explosions)-1]
-			i-- // Adjust index since we removed an element
-		} else {
-			// Check for box destruction
-			exploX, exploY := s.explosions[i].TilePosition()
-			for j, box := range s.boxes {
-				boxX, boxY := box.TilePosition()
-				if exploX == boxX && exploY == boxY {
-					if !box.IsBlank {
-						newEffect := box.DropRandomStatusEffect()
-						if newEffect.StatusEffect != nil {
-							s.statusEffects = append(s.statusEffects, newEffect)
-						}
-					}
-					s.collisionSpace.Remove(box.GetCollider())
-					// Remove box from slice
-					if j < len(s.boxes)-1 {
-						s.boxes[j] = s.boxes[len(s.boxes)-1]
-					}
-					s.boxes = s.boxes[:len(s.boxes)-1]
-				}
-			}
+	path := filepath.Join(replayDir, fmt.Sprint(time.Now().Format("2006-01-02_15-04-05"), replay.FileExtension))
+	if err := r.Save(path); err != nil {
+		log.Println("Failed to save replay", err)
+		return
+	}
+	log.Println("Replay saved to", path)
+}
 
-			// Check for terrain destruction
-			if s.staticEntities[exploX][exploY].IsDestroyable() {
-				s.collisionSpace.Remove(s.staticEntities[exploX][exploY].GetCollider())
-				s.staticEntities[exploX][exploY] = entities.NewGrass(s.collisionSpace, float64(exploX*16), float64(exploY*16), "assets/map/grass_block.png")
-			}
-		}
+// controlsFromInput reads the player controls from the current input state.
+//
+// Parameters:
+//   - input: The current input state.
+//
+// Returns:
+//   - entities.PlayerControls: The controls pressed by the player.
+func controlsFromInput(input *inputs.Input) entities.PlayerControls {
+	return entities.PlayerControls{
+		Up:       input.StateForUp() > 0,
+		Down:     input.StateForDown() > 0,
+		Left:     input.StateForLeft() > 0,
+		Right:    input.StateForRight() > 0,
+		Ability1: input.IsAbilityOneJustPressed(),
+		Ability2: input.IsAbilityTwoJustPressed(),
 	}
+}
 
-	// Update status effects
-	for i := range s.statusEffects {
-		s.statusEffects[i].Update()
+// Update advances the single-player match using the local input.
+// When the player dies, the scene returns to the main menu.
+//
+// Parameters:
+//   - state: The current game state.
+//
+// Returns:
+//   - error: An error if the update fails.
+func (s *GameScene) Update(state *GameState) error {
+	player := s.world.Player(s.playerID)
+	if player == nil {
+		return nil
 	}
 
-	// Update boxes
-	for i := range s.boxes {
-		s.boxes[i].Update()
+	s.recorder.Step(map[sim.PlayerID]entities.PlayerControls{
+		s.playerID: controlsFromInput(state.Input),
+	})
+
+	if player.IsDead {
+		// Player died - could restart level or go to game over
+		saveReplay(s.recorder.Replay())
+		state.SceneManager.GoTo(NewMainMenuScene(s.screenWidth, s.screenHeight))
 	}
 
 	return nil
@@ -245,29 +189,49 @@ func (s *GameScene) Update(state *GameState) error {
 func (s *GameScene) Draw(screen *ebiten.Image) {
 	screen.Fill(color.RGBA{148, 247, 252, 1})
 
-	for _, rows := range s.staticEntities {
+	drawEntities(screen, s.world.Terrain(), s.world.Effects(), s.world.Boxes(), s.world.Bombs(), s.world.Monsters(), s.world.Explosions())
+
+	for _, player := range s.world.Players() {
+		if !player.IsDead {
+			drawEntity(screen, player.Player)
+		}
+	}
+}
+
+// drawEntities renders the terrain and the entities of a match in their drawing order.
+//
+// Parameters:
+//   - screen: The image to which the entities are drawn.
+//   - terrain: The terrain of the level.
+//   - effects: The status effects lying on the ground.
+//   - boxes: The boxes in the level.
+//   - bombs: The placed bombs.
+//   - monsters: The monsters in the level.
+//   - explosions: The explosions of the detonated bombs.
+func drawEntities(screen *ebiten.Image, terrain [][]entities.Terrain, effects []entities.Effect, boxes []*entities.Box, bombs []*entities.Bomb, monsters []entities.Monster, explosions []*entities.Explosion) {
+	for _, rows := range terrain {
 		for _, entity := range rows {
-			entity.Draw(screen)
+			drawEntity(screen, entity)
 		}
 	}
 
-	for _, effect := range s.statusEffects {
-		effect.Draw(screen)
+	for _, effect := range effects {
+		drawEntity(screen, &effect)
 	}
 
-	for _, box := range s.boxes {
-		box.Draw(screen)
+	for _, box := range boxes {
+		drawEntity(screen, box)
 	}
 
-	for i := range s.bombs {
-		s.bombs[i].Draw(screen)
+	for _, bomb := range bombs {
+		drawEntity(screen, bomb)
 	}
 
-	for _, entity := range s.monsters {
-		entity.Draw(screen)
+	for _, monster := range monsters {
+		drawEntity(screen, monster)
 	}
 
-	for i := range s.explosions {
-		s.explosions[i].Draw(screen)
+	for _, explosion := range explosions {
+		drawEntity(screen, explosion)
 	}
 }
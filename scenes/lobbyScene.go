@@ -4,10 +4,12 @@ package scenes
 
 import (
 	"bytes"
+	"fmt"
 	"image"
 	"image/color"
 	"log"
 	"math"
+	"slices"
 
 	"github.com/ebitenui/ebitenui"
 	uiimage "github.com/ebitenui/ebitenui/image"
@@ -41,10 +43,11 @@ func init() {
 // Parameters:
 //   - username: The username of the player.
 //   - pColor: The color associated with the player.
+//   - actions: The buttons for managing the player, shown after its username.
 //
 // Returns:
 //   - *widget.Container: The container widget displaying the player information.
-func newPlayerWidget(username string, pColor color.Color) *widget.Container {
+func newPlayerWidget(username string, pColor color.Color, actions ...*widget.Button) *widget.Container {
 	playerWidget := widget.NewContainer(
 		widget.ContainerOpts.BackgroundImage(uiimage.NewNineSliceColor(pColor)),
 		widget.ContainerOpts.Layout(widget.NewRowLayout(
@@ -84,22 +87,144 @@ func newPlayerWidget(username string, pColor color.Color) *widget.Container {
 
 		widget.TextOpts.TextLabel(username),
 	))
+
+	for _, action := range actions {
+		playerWidget.AddChild(action)
+	}
+
 	return playerWidget
 }
 
+// newLobbyButton creates a small text button for the lobby.
+//
+// Parameters:
+//   - label: The text of the button.
+//   - clicked: The function called when the button is clicked.
+//
+// Returns:
+//   - *widget.Button: The button.
+func newLobbyButton(label string, clicked func()) *widget.Button {
+	return widget.NewButton(
+		widget.ButtonOpts.WidgetOpts(
+			widget.WidgetOpts.LayoutData(widget.RowLayoutData{
+				Position: widget.RowLayoutPositionCenter,
+			}),
+		),
+		widget.ButtonOpts.Image(assets.LoadButtonImage()),
+		widget.ButtonOpts.Text(label, assets.EbitenUIFont(10), &widget.ButtonTextColor{
+			Idle: color.NRGBA{0xdf, 0xf4, 0xff, 0xff},
+		}),
+		widget.ButtonOpts.TextPadding(widget.NewInsetsSimple(5)),
+		widget.ButtonOpts.ClickedHandler(func(args *widget.ButtonClickedEventArgs) {
+			clicked()
+		}),
+	)
+}
+
+// newLobbyTextInput creates a small text input for the lobby, limited to the length of a password.
+//
+// Parameters:
+//   - placeholder: The text shown while the input is empty.
+//   - changed: The function called with the text whenever it changes.
+//
+// Returns:
+//   - *widget.TextInput: The text input.
+func newLobbyTextInput(placeholder string, changed func(text string)) *widget.TextInput {
+	return widget.NewTextInput(
+		widget.TextInputOpts.WidgetOpts(
+			widget.WidgetOpts.LayoutData(widget.RowLayoutData{
+				Position:  widget.RowLayoutPositionCenter,
+				Stretch:   true,
+				MaxHeight: 25,
+				MaxWidth:  200,
+			}),
+			widget.WidgetOpts.MinSize(150, 0),
+		),
+		widget.TextInputOpts.Image(&widget.TextInputImage{
+			Idle:     uiimage.NewNineSliceColor(color.NRGBA{R: 75, G: 105, B: 47, A: 255}),
+			Disabled: uiimage.NewNineSliceColor(color.NRGBA{R: 100, G: 100, B: 100, A: 255}),
+		}),
+		widget.TextInputOpts.Face(assets.EbitenUIFont(10)),
+		widget.TextInputOpts.Color(&widget.TextInputColor{
+			Idle:          color.NRGBA{255, 255, 255, 255},
+			Disabled:      color.NRGBA{R: 200, G: 200, B: 200, A: 255},
+			Caret:         color.NRGBA{254, 255, 255, 255},
+			DisabledCaret: color.NRGBA{R: 200, G: 200, B: 200, A: 255},
+		}),
+		widget.TextInputOpts.Padding(widget.NewInsetsSimple(5)),
+		widget.TextInputOpts.ChangedHandler(func(args *widget.TextInputChangedEventArgs) {
+			changed(args.InputText)
+		}),
+		widget.TextInputOpts.Validation(func(newInputText string) (bool, *string) {
+			return len([]rune(newInputText)) <= multiplayer.MaxPasswordLength, nil
+		}),
+		widget.TextInputOpts.CaretOpts(
+			widget.CaretOpts.Size(assets.EbitenUIFont(10), 2),
+		),
+		widget.TextInputOpts.Placeholder(placeholder),
+	)
+}
+
+// newColorButton creates a button for picking a player color, disabled if another player has the color.
+//
+// Parameters:
+//   - c: The color of the button.
+//   - taken: Whether another player has the color.
+//   - clicked: The function called when the button is clicked.
+//
+// Returns:
+//   - *widget.Button: The button.
+func newColorButton(c color.RGBA, taken bool, clicked func()) *widget.Button {
+	faded := color.RGBA{R: c.R / 3, G: c.G / 3, B: c.B / 3, A: 255}
+	button := widget.NewButton(
+		widget.ButtonOpts.WidgetOpts(
+			widget.WidgetOpts.MinSize(20, 20),
+			widget.WidgetOpts.LayoutData(widget.RowLayoutData{
+				Position: widget.RowLayoutPositionCenter,
+			}),
+		),
+		widget.ButtonOpts.Image(&widget.ButtonImage{
+			Idle:     uiimage.NewNineSliceColor(c),
+			Hover:    uiimage.NewNineSliceColor(c),
+			Pressed:  uiimage.NewNineSliceColor(faded),
+			Disabled: uiimage.NewNineSliceColor(faded),
+		}),
+		widget.ButtonOpts.ClickedHandler(func(args *widget.ButtonClickedEventArgs) {
+			clicked()
+		}),
+	)
+	button.GetWidget().Disabled = taken
+
+	return button
+}
+
+// lobbyEntry represents a player as shown in the lobby, to tell when the lobby has to be rebuilt.
+type lobbyEntry struct {
+	player   int        // The index of the player in the game information.
+	username string     // The username of the player.
+	color    color.RGBA // The color of the player.
+	ready    bool       // Whether the player is ready.
+}
+
 // lobbyScene represents the scene for the multiplayer lobby, where players can join and prepare for the game.
 type lobbyScene struct {
-	players           *[]multiplayer.ProtoPlayer // The list of players in the lobby.
-	count             int                        // The count for the background animation.
-	screenHeight      int                        // The height of the screen.
-	screenWidth       int                        // The width of the screen.
-	ui                *ebitenui.UI               // The user interface for the lobby scene.
-	playerList        *widget.ScrollContainer    // The scroll container for the player list.
-	content           *widget.Container          // The container for the player list content.
-	playButtonPressed bool                       // Indicates if the play button has been pressed.
-	backButtonPressed bool                       // Indicates if the back button has been pressed.
-	Server            *multiplayer.GameServer    // The game server for hosting the game.
-	Client            *multiplayer.GameClient    // The game client for joining the game.
+	count             int                               // The count for the background animation.
+	screenHeight      int                               // The height of the screen.
+	screenWidth       int                               // The width of the screen.
+	ui                *ebitenui.UI                      // The user interface for the lobby scene.
+	playerList        *widget.ScrollContainer           // The scroll container for the player list.
+	content           *widget.Container                 // The container for the player list content.
+	controls          *widget.Container                 // The container for the ready button and the color buttons.
+	status            *widget.Text                      // The text showing the number of players and whether they are ready.
+	chat              *chatPanel                        // The chat of the lobby.
+	shown             []lobbyEntry                      // The players currently shown in the lobby.
+	shownMax          int                               // The player cap currently shown in the lobby.
+	playerActions     func(player int) []*widget.Button // Returns the buttons for managing a player, or nil for players who cannot.
+	playButtonPressed bool                              // Indicates if the play button has been pressed.
+	backButtonPressed bool                              // Indicates if the back button has been pressed.
+	Server            *multiplayer.GameServer           // The game server for hosting the game.
+	closeServer       func()                            // Stops the game server hosted by this player, or nil if it joined another game.
+	Client            *multiplayer.GameClient           // The game client connected to the game.
 }
 
 // newLobbyScene creates a new lobby scene with the specified screen dimensions.
@@ -181,9 +306,89 @@ func newLobbyScene(ScreenWidth int, ScreenHeight int) *lobbyScene {
 
 	rootContainer.AddChild(vSlider)
 
+	lobby.controls = widget.NewContainer(widget.ContainerOpts.Layout(widget.NewRowLayout(
+		widget.RowLayoutOpts.Direction(widget.DirectionHorizontal),
+		widget.RowLayoutOpts.Padding(widget.NewInsetsSimple(5)),
+		widget.RowLayoutOpts.Spacing(5),
+	)))
+	rootContainer.AddChild(lobby.controls)
+
+	lobby.status = widget.NewText(
+		widget.TextOpts.Text("", assets.EbitenUIFont(10), color.NRGBA{254, 255, 255, 255}),
+		widget.TextOpts.Insets(widget.NewInsetsSimple(5)),
+	)
+	rootContainer.AddChild(lobby.status)
+
+	lobby.chat = newChatPanel(func(text string) { lobby.Client.SendChat(text) }, 8)
+	rootContainer.AddChild(lobby.chat.Container)
+	// keeps the navigation of the host and the joined players below the chat
+	rootContainer.AddChild(widget.NewContainer())
+
 	return lobby
 }
 
+// updatePlayers rebuilds the player list and the lobby controls if the players in the lobby changed.
+// Players who left the lobby are hidden.
+//
+// Parameters:
+//   - info: The game state information received from the server.
+func (s *lobbyScene) updatePlayers(info multiplayer.ProtoGameInfo) {
+	entries := make([]lobbyEntry, 0, len(info.Players))
+	ready := 0
+	for i, player := range info.Players {
+		if player.IsDead {
+			continue
+		}
+		entries = append(entries, lobbyEntry{player: i, username: player.Username, color: player.Color, ready: player.Ready})
+		if player.Ready {
+			ready++
+		}
+	}
+	if s.shown != nil && slices.Equal(entries, s.shown) && info.MaxPlayers == s.shownMax {
+		return
+	}
+	s.shown, s.shownMax = entries, info.MaxPlayers
+
+	s.status.Label = fmt.Sprintf("Players: %d/%d, ready: %d", len(entries), info.MaxPlayers, ready)
+
+	s.content.RemoveChildren()
+	for _, entry := range entries {
+		label := entry.username
+		if entry.ready {
+			label += " (ready)"
+		}
+
+		var actions []*widget.Button
+		if s.playerActions != nil {
+			actions = s.playerActions(entry.player)
+		}
+		s.content.AddChild(newPlayerWidget(label, entry.color, actions...))
+	}
+
+	// spectators watch the lobby without a player to prepare
+	me := s.Client.Player()
+	if me < 0 || me >= len(info.Players) {
+		return
+	}
+	player := info.Players[me]
+
+	s.controls.RemoveChildren()
+	readyLabel := "Ready"
+	if player.Ready {
+		readyLabel = "Not ready"
+	}
+	s.controls.AddChild(newLobbyButton(readyLabel, func() {
+		s.Client.SendLobby(!player.Ready, player.Color)
+	}))
+
+	for _, c := range multiplayer.PlayerColors() {
+		taken := slices.ContainsFunc(entries, func(e lobbyEntry) bool { return e.player != me && e.color == c })
+		s.controls.AddChild(newColorButton(c, taken, func() {
+			s.Client.SendLobby(player.Ready, c)
+		}))
+	}
+}
+
 // Update updates the lobby scene, handling player list updates, server/client interactions,
 // and transitioning to the game scene when the game starts.
 //
@@ -195,31 +400,43 @@ func newLobbyScene(ScreenWidth int, ScreenHeight int) *lobbyScene {
 func (s *lobbyScene) Update(state *GameState) error {
 	s.ui.Update()
 
-	for i, player := range *s.players {
-		if len(s.content.Children()) < i+1 {
-			s.content.AddChild(newPlayerWidget(player.Username, player.Color))
-		}
-	}
-	if len(s.content.Children()) > len(*s.players) {
-		s.content.RemoveChildren()
-		for _, player := range *s.players {
-			s.content.AddChild(newPlayerWidget(player.Username, player.Color))
-		}
+	// the host leaving the lobby closes its own server, which is no reason to tell it
+	if !s.backButtonPressed && leaveDisconnected(state, s.Client, s.screenWidth, s.screenHeight) {
+		return nil
 	}
 
-	if s.Server != nil {
-		s.Server.GameInfo.Players[0].Username = state.UserInfo.Username
-	}
+	info := s.Client.GameInfo()
+	s.updatePlayers(info)
+	s.chat.update(info.Chat)
 
 	if s.playButtonPressed && s.Server != nil {
-		state.SceneManager.GoTo(NewMultiPlayerGameSceneHost(s.Server, s.Server.GameInfo.Level, state.UserInfo))
-
-		return nil
+		if err := s.Server.StartMatch(); err != nil {
+			log.Println("Failed to start match", err)
+		}
+		s.playButtonPressed = false
 	}
 
-	if s.Client != nil && s.Client.GameInfo.GameState == multiplayer.GameStateRunning {
+	if info.GameState == multiplayer.GameStateRunning {
 		log.Println("Game started")
-		state.SceneManager.GoTo(NewMultiPlayerGameSceneJoin(s.Client))
+		var scene Scene
+		var err error
+		switch {
+		case s.Server != nil:
+			scene, err = NewMultiPlayerGameSceneHost(s.Server, s.closeServer, s.Client)
+		case s.Client.Spectator():
+			scene, err = NewSpectatorScene(s.Client)
+		default:
+			scene, err = NewMultiPlayerGameSceneJoin(s.Client)
+		}
+		if err != nil {
+			log.Println("Failed to load the level of the match", err)
+			s.Client.Close()
+			if s.closeServer != nil {
+				s.closeServer()
+			}
+			scene = NewMainMenuScene(s.screenWidth, s.screenHeight)
+		}
+		state.SceneManager.GoTo(scene)
 
 		return nil
 	}
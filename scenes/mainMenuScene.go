@@ -4,10 +4,12 @@ package scenes
 
 import (
 	"bytes"
+	"fmt"
 	"image"
 	"image/color"
 	"log"
 	"os"
+	"slices"
 	"unicode/utf8"
 
 	"github.com/ebitenui/ebitenui"
@@ -39,16 +41,23 @@ func init() {
 
 // mainMenuScene represents the main menu scene of the game, where players can host or join games.
 type mainMenuScene struct {
-	count             int               // The count of the main menu scene.
-	screenHeight      int               // The height of the screen.
-	screenWidth       int               // The width of the screen.
-	ui                *ebitenui.UI      // The user interface for the main menu scene.
-	nameInput         *widget.TextInput // The text input for the player's name.
-	addressToJoin     string            // The address to join a game.
-	map1ButtonPressed bool              // The flag indicating whether the map1 button is pressed.
-	map2ButtonPressed bool              // The flag indicating whether the map2 button is pressed.
-	map3ButtonPressed bool              // The flag indicating whether the map3 button is pressed.
-	joinGame          bool              // The flag indicating whether the player is joining a game.
+	count             int                        // The count of the main menu scene.
+	screenHeight      int                        // The height of the screen.
+	screenWidth       int                        // The width of the screen.
+	ui                *ebitenui.UI               // The user interface for the main menu scene.
+	nameInput         *widget.TextInput          // The text input for the player's name.
+	addressToJoin     string                     // The address to join a game.
+	passwordToJoin    string                     // The password or invite code to join a private game with.
+	map1ButtonPressed bool                       // The flag indicating whether the map1 button is pressed.
+	map2ButtonPressed bool                       // The flag indicating whether the map2 button is pressed.
+	map3ButtonPressed bool                       // The flag indicating whether the map3 button is pressed.
+	joinGame          bool                       // The flag indicating whether the player is joining a game.
+	spectate          bool                       // The flag indicating whether the player joins games as a spectator.
+	secureHost        bool                       // The flag indicating whether hosted games only accept secure connections.
+	replayToWatch     string                     // The path of the replay selected for watching.
+	browser           *multiplayer.ServerBrowser // The browser finding the servers on the local network, while the join window has been opened.
+	serverList        *widget.Container          // The container listing the servers found on the local network.
+	shownServers      []string                   // The labels of the servers currently shown in the list.
 }
 
 // NewMainMenuScene creates a new main menu scene with the specified screen dimensions.
@@ -60,6 +69,29 @@ type mainMenuScene struct {
 // Returns:
 //   - Scene: The initialized main menu scene.
 func NewMainMenuScene(ScreenWidth int, ScreenHeight int) Scene {
+	return newMainMenuScene(ScreenWidth, ScreenHeight)
+}
+
+// NewMainMenuSceneWithMessage creates a new main menu scene showing a message to the player,
+// like the reason a multiplayer game ended for.
+//
+// Parameters:
+//   - ScreenWidth: The width of the screen.
+//   - ScreenHeight: The height of the screen.
+//   - title: The title of the message window.
+//   - message: The message shown in the window.
+//
+// Returns:
+//   - Scene: The initialized main menu scene.
+func NewMainMenuSceneWithMessage(ScreenWidth int, ScreenHeight int, title string, message string) Scene {
+	mainMenu := newMainMenuScene(ScreenWidth, ScreenHeight)
+	showMessage(mainMenu.ui, title, message)
+
+	return mainMenu
+}
+
+// newMainMenuScene creates the main menu scene with its join, host and server list windows.
+func newMainMenuScene(ScreenWidth int, ScreenHeight int) *mainMenuScene {
 	rootContainer := widget.NewContainer(
 		widget.ContainerOpts.Layout(
 			widget.NewGridLayout(
@@ -128,15 +160,44 @@ func NewMainMenuScene(ScreenWidth int, ScreenHeight int) Scene {
 			widget.CaretOpts.Size(assets.EbitenUIFont(10), 2),
 		),
 
-		widget.TextInputOpts.Placeholder("IP Address"),
+		widget.TextInputOpts.Placeholder("IP Address[/Room]"),
 	))
 
+	joinwindowContainer.AddChild(newLobbyTextInput("Password or invite code", func(text string) {
+		mainMenu.passwordToJoin = text
+	}))
+
+	mainMenu.serverList = widget.NewContainer(
+		widget.ContainerOpts.Layout(widget.NewRowLayout(
+			widget.RowLayoutOpts.Direction(widget.DirectionVertical),
+			widget.RowLayoutOpts.Spacing(5),
+		)),
+		widget.ContainerOpts.WidgetOpts(
+			widget.WidgetOpts.LayoutData(widget.RowLayoutData{
+				Position: widget.RowLayoutPositionCenter,
+				Stretch:  true,
+			}),
+		),
+	)
+	mainMenu.updateServerList()
+	var spectateButton *widget.Button
+	spectateButton = newLobbyButton("Join as player", func() {
+		mainMenu.spectate = !mainMenu.spectate
+		spectateButton.Text().Label = "Join as player"
+		if mainMenu.spectate {
+			spectateButton.Text().Label = "Join as spectator"
+		}
+	})
+	joinwindowContainer.AddChild(spectateButton)
+
+	joinwindowContainer.AddChild(mainMenu.serverList)
+
 	joinWindowContent := widget.NewContainer(
 		widget.ContainerOpts.BackgroundImage(uiimage.NewNineSliceColor(color.NRGBA{75, 105, 47, 255})),
 		widget.ContainerOpts.Layout(widget.NewAnchorLayout()),
 	)
 	joinWindowContent.AddChild(widget.NewText(
-		widget.TextOpts.Text("Enter IP Address to join a server", assets.EbitenUIFont(12), color.NRGBA{254, 255, 255, 255}),
+		widget.TextOpts.Text("Enter IP Address or pick a server", assets.EbitenUIFont(12), color.NRGBA{254, 255, 255, 255}),
 		widget.TextOpts.WidgetOpts(widget.WidgetOpts.LayoutData(widget.AnchorLayoutData{
 			HorizontalPosition: widget.AnchorLayoutPositionCenter,
 			VerticalPosition:   widget.AnchorLayoutPositionCenter,
@@ -151,7 +212,7 @@ func NewMainMenuScene(ScreenWidth int, ScreenHeight int) Scene {
 		widget.WindowOpts.Draggable(),
 		widget.WindowOpts.Resizeable(),
 		widget.WindowOpts.MinSize(200, 100),
-		widget.WindowOpts.MaxSize(300, 300),
+		widget.WindowOpts.MaxSize(300, 400),
 	)
 
 	//hostWindow
@@ -338,6 +399,15 @@ func NewMainMenuScene(ScreenWidth int, ScreenHeight int) Scene {
 	hostwindowContainer.AddChild(innerContainer1)
 	hostwindowContainer.AddChild(innerContainer2)
 	hostwindowContainer.AddChild(innerContainer3)
+	var secureButton *widget.Button
+	secureButton = newLobbyButton("Plain connections", func() {
+		mainMenu.secureHost = !mainMenu.secureHost
+		secureButton.Text().Label = "Plain connections"
+		if mainMenu.secureHost {
+			secureButton.Text().Label = "Secure connections"
+		}
+	})
+	hostwindowContainer.AddChild(secureButton)
 
 	hostWindowContent := widget.NewContainer(
 		widget.ContainerOpts.BackgroundImage(uiimage.NewNineSliceColor(color.NRGBA{75, 105, 47, 255})),
@@ -468,6 +538,14 @@ func NewMainMenuScene(ScreenWidth int, ScreenHeight int) Scene {
 		}),
 
 		widget.ButtonOpts.ClickedHandler(func(args *widget.ButtonClickedEventArgs) {
+			if mainMenu.browser == nil {
+				browser, err := multiplayer.NewServerBrowser()
+				if err != nil {
+					log.Println("Failed to search for servers", err)
+				}
+				mainMenu.browser = browser
+			}
+
 			x, y := joinwindow.Contents.PreferredSize()
 
 			r := image.Rect(0, 0, x, y)
@@ -480,6 +558,45 @@ func NewMainMenuScene(ScreenWidth int, ScreenHeight int) Scene {
 		}),
 	))
 
+	rootContainer.AddChild(widget.NewButton(
+		widget.ButtonOpts.WidgetOpts(
+			widget.WidgetOpts.LayoutData(widget.GridLayoutData{
+				MaxWidth:           100,
+				MaxHeight:          50,
+				VerticalPosition:   widget.GridLayoutPositionEnd,
+				HorizontalPosition: widget.GridLayoutPositionCenter,
+			}),
+		),
+
+		widget.ButtonOpts.Image(assets.LoadButtonImage()),
+
+		widget.ButtonOpts.Text("Replays", assets.EbitenUIFont(20), &widget.ButtonTextColor{
+			Idle: color.NRGBA{0xdf, 0xf4, 0xff, 0xff},
+		}),
+
+		widget.ButtonOpts.TextPadding(widget.Insets{
+			Left:   30,
+			Right:  30,
+			Top:    5,
+			Bottom: 5,
+		}),
+
+		widget.ButtonOpts.ClickedHandler(func(args *widget.ButtonClickedEventArgs) {
+			replayWindow := newReplayWindow(func(path string) {
+				mainMenu.replayToWatch = path
+			})
+			x, y := replayWindow.Contents.PreferredSize()
+
+			r := image.Rect(0, 0, x, y)
+
+			r = r.Add(image.Point{100, 50})
+
+			replayWindow.SetLocation(r)
+
+			mainMenu.ui.AddWindow(replayWindow)
+		}),
+	))
+
 	rootContainer.AddChild(widget.NewButton(
 		widget.ButtonOpts.WidgetOpts(
 			widget.WidgetOpts.LayoutData(widget.GridLayoutData{
@@ -542,38 +659,135 @@ func (s *mainMenuScene) Update(state *GameState) error {
 	s.ui.Update()
 	s.count++
 
+	if s.browser != nil && s.count%30 == 0 {
+		s.updateServerList()
+	}
+
 	if s.nameInput.GetText() != "" {
 		state.UserInfo.Username = s.nameInput.GetText()
 	}
 
 	if s.map1ButtonPressed && state.UserInfo.Username != "" {
 		log.Println("Map1 button pressed")
-		state.SceneManager.GoTo(NewHostLobby(400, 300, "assets/levels/level1.txt"))
+		s.goTo(state, NewHostLobby(400, 300, "assets/levels/level1.txt", s.secureHost, state))
 	}
 
 	if s.map2ButtonPressed && state.UserInfo.Username != "" {
 		log.Println("Map2 button pressed")
-		state.SceneManager.GoTo(NewHostLobby(400, 300, "assets/levels/level2.txt"))
+		s.goTo(state, NewHostLobby(400, 300, "assets/levels/level2.txt", s.secureHost, state))
 	}
 
 	if s.map3ButtonPressed && state.UserInfo.Username != "" {
 		log.Println("Map3 button pressed")
-		state.SceneManager.GoTo(NewHostLobby(400, 300, "assets/levels/level3.txt"))
+		s.goTo(state, NewHostLobby(400, 300, "assets/levels/level3.txt", s.secureHost, state))
+	}
+
+	if s.replayToWatch != "" {
+		log.Println("Watching replay:", s.replayToWatch)
+		if scene, err := loadReplayScene(s.replayToWatch); err != nil {
+			log.Println("Failed to open replay", err)
+		} else {
+			s.goTo(state, scene)
+		}
+		s.replayToWatch = ""
 	}
 
 	if s.joinGame && state.UserInfo.Username != "" {
 		log.Println("Join Game at address:", s.addressToJoin)
-		client := multiplayer.NewGameClient(s.addressToJoin, state.UserInfo, func(code int, text string) error {
-			state.SceneManager.GoTo(NewMainMenuScene(400, 300))
+		newClient := multiplayer.NewGameClient
+		if s.spectate {
+			newClient = multiplayer.NewSpectatorClient
+		}
+		// the scenes of the game return to the main menu once the client is disconnected
+		client, err := newClient(s.addressToJoin, state.UserInfo, s.passwordToJoin, nil)
+		s.joinGame = false
+		if err != nil {
+			showMessage(s.ui, "Cannot join the game", err.Error())
 
 			return nil
-		})
-		state.SceneManager.GoTo(NewClientLobby(400, 300, client, state))
+		}
+		s.goTo(state, NewClientLobby(400, 300, client, state))
 	}
 
 	return nil
 }
 
+// goTo stops searching for servers and transitions to the next scene.
+//
+// Parameters:
+//   - state: The current game state.
+//   - scene: The scene to transition to.
+func (s *mainMenuScene) goTo(state *GameState, scene Scene) {
+	if s.browser != nil {
+		s.browser.Close()
+		s.browser = nil
+	}
+	state.SceneManager.GoTo(scene)
+}
+
+// updateServerList rebuilds the list of the servers found on the local network if it changed.
+// Servers speaking another protocol version are shown, but cannot be joined.
+func (s *mainMenuScene) updateServerList() {
+	var servers []multiplayer.DiscoveredServer
+	if s.browser != nil {
+		servers = s.browser.Servers()
+	}
+
+	labels := make([]string, len(servers))
+	for i, server := range servers {
+		labels[i] = fmt.Sprintf("%s  %s  %d/%d  %dms",
+			server.Info.Level, server.Info.GameState, server.Info.Players, server.Info.MaxPlayers, server.Ping.Milliseconds())
+		if !server.Compatible() {
+			labels[i] += "  (other version)"
+		}
+		if server.Info.Private {
+			labels[i] += "  (private)"
+		}
+		if server.Info.TLS {
+			labels[i] += "  (secure)"
+		}
+	}
+	if s.shownServers != nil && slices.Equal(labels, s.shownServers) {
+		return
+	}
+	s.shownServers = labels
+
+	s.serverList.RemoveChildren()
+	if len(servers) == 0 {
+		s.serverList.AddChild(widget.NewText(
+			widget.TextOpts.Text("Searching the local network...", assets.EbitenUIFont(10), color.NRGBA{254, 255, 255, 255}),
+		))
+	}
+
+	for i, server := range servers {
+		button := widget.NewButton(
+			widget.ButtonOpts.WidgetOpts(
+				widget.WidgetOpts.LayoutData(widget.RowLayoutData{
+					Position: widget.RowLayoutPositionCenter,
+					Stretch:  true,
+				}),
+			),
+			widget.ButtonOpts.Image(assets.LoadButtonImage()),
+			widget.ButtonOpts.Text(labels[i], assets.EbitenUIFont(10), &widget.ButtonTextColor{
+				Idle:     color.NRGBA{0xdf, 0xf4, 0xff, 0xff},
+				Disabled: color.NRGBA{R: 200, G: 200, B: 200, A: 255},
+			}),
+			widget.ButtonOpts.TextPadding(widget.Insets{
+				Left:   10,
+				Right:  10,
+				Top:    5,
+				Bottom: 5,
+			}),
+			widget.ButtonOpts.ClickedHandler(func(args *widget.ButtonClickedEventArgs) {
+				s.addressToJoin = server.JoinAddress()
+				s.joinGame = true
+			}),
+		)
+		button.GetWidget().Disabled = !server.Compatible()
+		s.serverList.AddChild(button)
+	}
+}
+
 // Draw renders the main menu scene onto the provided screen image.
 //
 // Parameters:
@@ -0,0 +1,123 @@
+// Package scenes provides the implementation of various game scenes,
+// including the message window telling the player why something failed or why a multiplayer game ended.
+package scenes
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/ebitenui/ebitenui"
+	uiimage "github.com/ebitenui/ebitenui/image"
+	"github.com/ebitenui/ebitenui/widget"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/assets"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/multiplayer"
+)
+
+// newMessageWindow creates a window showing a message to the player, closed with its OK button or by clicking outside of it.
+//
+// Parameters:
+//   - title: The title of the window.
+//   - message: The message shown in the window.
+//
+// Returns:
+//   - *widget.Window: The initialized window.
+func newMessageWindow(title string, message string) *widget.Window {
+	messageContainer := widget.NewContainer(
+		widget.ContainerOpts.BackgroundImage(uiimage.NewNineSliceColor(color.NRGBA{19, 128, 30, 255})),
+		widget.ContainerOpts.Layout(widget.NewRowLayout(
+			widget.RowLayoutOpts.Direction(widget.DirectionVertical),
+			widget.RowLayoutOpts.Padding(widget.NewInsetsSimple(10)),
+			widget.RowLayoutOpts.Spacing(10),
+		)),
+	)
+
+	messageContainer.AddChild(widget.NewText(
+		widget.TextOpts.Text(message, assets.EbitenUIFont(10), color.NRGBA{254, 255, 255, 255}),
+		widget.TextOpts.MaxWidth(260),
+	))
+
+	var window *widget.Window
+	messageContainer.AddChild(widget.NewButton(
+		widget.ButtonOpts.WidgetOpts(
+			widget.WidgetOpts.LayoutData(widget.RowLayoutData{
+				Position: widget.RowLayoutPositionCenter,
+			}),
+		),
+		widget.ButtonOpts.Image(assets.LoadButtonImage()),
+		widget.ButtonOpts.Text("OK", assets.EbitenUIFont(10), &widget.ButtonTextColor{
+			Idle: color.NRGBA{0xdf, 0xf4, 0xff, 0xff},
+		}),
+		widget.ButtonOpts.TextPadding(widget.Insets{
+			Left:   30,
+			Right:  30,
+			Top:    5,
+			Bottom: 5,
+		}),
+		widget.ButtonOpts.ClickedHandler(func(args *widget.ButtonClickedEventArgs) {
+			window.Close()
+		}),
+	))
+
+	titleContainer := widget.NewContainer(
+		widget.ContainerOpts.BackgroundImage(uiimage.NewNineSliceColor(color.NRGBA{75, 105, 47, 255})),
+		widget.ContainerOpts.Layout(widget.NewAnchorLayout()),
+	)
+	titleContainer.AddChild(widget.NewText(
+		widget.TextOpts.Text(title, assets.EbitenUIFont(12), color.NRGBA{254, 255, 255, 255}),
+		widget.TextOpts.WidgetOpts(widget.WidgetOpts.LayoutData(widget.AnchorLayoutData{
+			HorizontalPosition: widget.AnchorLayoutPositionCenter,
+			VerticalPosition:   widget.AnchorLayoutPositionCenter,
+		})),
+	))
+
+	window = widget.NewWindow(
+		widget.WindowOpts.Contents(messageContainer),
+		widget.WindowOpts.TitleBar(titleContainer, 25),
+		widget.WindowOpts.Modal(),
+		widget.WindowOpts.CloseMode(widget.CLICK_OUT),
+		widget.WindowOpts.Draggable(),
+		widget.WindowOpts.MinSize(200, 80),
+		widget.WindowOpts.MaxSize(300, 200),
+	)
+
+	return window
+}
+
+// showMessage opens a message window in the middle of the user interface.
+//
+// Parameters:
+//   - ui: The user interface the window is added to.
+//   - title: The title of the window.
+//   - message: The message shown in the window.
+func showMessage(ui *ebitenui.UI, title string, message string) {
+	window := newMessageWindow(title, message)
+
+	x, y := window.Contents.PreferredSize()
+	r := image.Rect(0, 0, x, y)
+	r = r.Add(image.Point{100, 50})
+	window.SetLocation(r)
+
+	ui.AddWindow(window)
+}
+
+// leaveDisconnected returns to the main menu once the server ended the connection of the client,
+// telling the player why in a message window.
+//
+// Parameters:
+//   - state: The current game state.
+//   - client: The game client of the scene.
+//   - width: The width of the screen.
+//   - height: The height of the screen.
+//
+// Returns:
+//   - bool: Whether the client was disconnected and the scene is left.
+func leaveDisconnected(state *GameState, client *multiplayer.GameClient, width int, height int) bool {
+	reason, ok := client.Disconnected()
+	if !ok {
+		return false
+	}
+
+	state.SceneManager.GoTo(NewMainMenuSceneWithMessage(width, height, "Disconnected", reason))
+
+	return true
+}
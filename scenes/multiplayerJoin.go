@@ -3,21 +3,56 @@
 package scenes
 
 import (
+	"bytes"
 	"fmt"
-	"math"
+	"image/color"
+	"math/rand"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
+	text "github.com/hajimehoshi/ebiten/v2/text/v2"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/assets"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/collider"
 	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/entities"
 	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/multiplayer"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/sim"
 	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/userinfo"
 )
 
+// maxPendingInputs is the number of inputs the scene replays on top of the last position received
+// from the server before dropping the oldest ones.
+const maxPendingInputs = 2 * entities.TicksPerSecond
+
+// pendingInput represents an input of the local player the server has not simulated yet.
+type pendingInput struct {
+	seq      uint64                  // The sequence number of the input.
+	controls entities.PlayerControls // The controls pressed by the player.
+}
+
 // MultiPlayerGameSceneJoin represents a scene for joining a multiplayer game.
-// It includes the game client, game scene, and state for terrain changes.
+// It mirrors the entities received from the host, without simulating the match itself,
+// except for the player of the client, which is predicted from its own inputs.
+// Spectators and dead players watch the match through a spectator camera instead.
 type MultiPlayerGameSceneJoin struct {
-	Client *multiplayer.GameClient
-	GameScene
-	terrainChange int
+	Client         *multiplayer.GameClient           // The game client connected to the host.
+	screenHeight   int                               // The height of the game screen.
+	screenWidth    int                               // The width of the game screen.
+	collisionSpace *collider.SpatialHash             // The collision space for the mirrored entities.
+	staticEntities [][]entities.Terrain              // The terrain of the level, indexed by row and then column.
+	monsters       entityMirror[entities.Monster]    // The mirrored monsters.
+	bombs          entityMirror[*entities.Bomb]      // The mirrored bombs.
+	explosions     entityMirror[*entities.Explosion] // The mirrored explosions.
+	boxes          entityMirror[*entities.Box]       // The mirrored boxes.
+	statusEffects  entityMirror[entities.Effect]     // The mirrored status effects.
+	players        []entities.Player                 // The mirrored players.
+	terrainChange  int                               // The number of terrain changes applied so far.
+	rng            *rand.Rand                        // The random source for the mirrored entities.
+	clock          *entities.Clock                   // The clock of the mirrored monsters, which are never simulated.
+	info           multiplayer.ProtoGameInfo         // The game state information the scene was last updated with.
+	pendingInputs  []pendingInput                    // The inputs of the local player the server has not simulated yet.
+	chat           *chatOverlay                      // The chat shown over the match.
+	camera         *spectatorCamera                  // The camera of the spectator, or nil while the player of the client plays.
+	left           bool                              // Whether the player left the match for the main menu.
 }
 
 // NewMultiPlayerGameSceneJoin creates a new scene for joining a multiplayer game.
@@ -28,16 +63,60 @@ type MultiPlayerGameSceneJoin struct {
 //
 // Returns:
 //   - Scene: The initialized multiplayer game join scene.
-func NewMultiPlayerGameSceneJoin(client *multiplayer.GameClient) Scene {
-	s := MultiPlayerGameSceneJoin{
-		Client: client,
+//   - error: An error if the level of the server cannot be fetched or loaded.
+func NewMultiPlayerGameSceneJoin(client *multiplayer.GameClient) (Scene, error) {
+	return newMultiPlayerGameSceneJoin(client)
+}
+
+// newMultiPlayerGameSceneJoin creates the scene mirroring the match the given client is connected to.
+// The level is the one sent by the server, not a file of the same name on this machine.
+//
+// Parameters:
+//   - client: The game client used to connect to the multiplayer game.
+//
+// Returns:
+//   - *MultiPlayerGameSceneJoin: The initialized multiplayer game join scene.
+//   - error: An error if the level of the server cannot be fetched or loaded.
+func newMultiPlayerGameSceneJoin(client *multiplayer.GameClient) (*MultiPlayerGameSceneJoin, error) {
+	info := client.GameInfo()
+	level, err := client.Level(info.LevelHash)
+	if err != nil {
+		return nil, err
+	}
+	world, err := sim.LoadLevel(bytes.NewReader(level), info.Seed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid level from the server: %w", err)
 	}
-	s.GameScene = *LoadLevelFromTextFile(client.GameInfo.Level)
 
-	s.screenHeight = 16 * len(s.staticEntities)
-	s.screenWidth = 16 * len(s.staticEntities[0])
+	// only the terrain of the level is kept, the server spawns every other entity
+	for _, monster := range world.Monsters() {
+		world.CollisionSpace().Remove(monster.GetCollider())
+	}
+	for _, box := range world.Boxes() {
+		world.CollisionSpace().Remove(box.GetCollider())
+	}
+	for i := range world.Effects() {
+		world.CollisionSpace().Remove(world.Effects()[i].GetCollider())
+	}
+
+	s := MultiPlayerGameSceneJoin{
+		Client:         client,
+		screenHeight:   world.Height(),
+		screenWidth:    world.Width(),
+		collisionSpace: world.CollisionSpace(),
+		staticEntities: world.Terrain(),
+		monsters:       newEntityMirror(entities.Monster.GetCollider),
+		bombs:          newEntityMirror((*entities.Bomb).GetCollider),
+		explosions:     newEntityMirror((*entities.Explosion).GetCollider),
+		boxes:          newEntityMirror((*entities.Box).GetCollider),
+		statusEffects:  newEntityMirror(func(effect entities.Effect) collider.Shape { return effect.GetCollider() }),
+		rng:            rand.New(rand.NewSource(info.Seed)),
+		clock:          entities.NewClock(),
+		info:           info,
+		chat:           newChatOverlay(client.SendChat),
+	}
 
-	return &s
+	return &s, nil
 }
 
 // Update updates the multiplayer game scene based on the game state.
@@ -49,159 +128,240 @@ func NewMultiPlayerGameSceneJoin(client *multiplayer.GameClient) Scene {
 // Returns:
 //   - error: An error if the update fails.
 func (s *MultiPlayerGameSceneJoin) Update(state *GameState) error {
-	if s.Client.GameInfo.GameState == multiplayer.GameStateEnd && state.Input.IsAbilityOneJustPressed() {
-		state.SceneManager.GoTo(NewMainMenuScene(s.screenWidth, s.screenHeight))
+	if leaveDisconnected(state, s.Client, s.screenWidth, s.screenHeight) {
+		s.left = true
+
+		return nil
 	}
 
-	// Update player controls
-	s.Client.Player.Control.Up = state.Input.StateForUp() > 0
-	s.Client.Player.Control.Down = state.Input.StateForDown() > 0
-	s.Client.Player.Control.Left = state.Input.StateForLeft() > 0
-	s.Client.Player.Control.Right = state.Input.StateForRight() > 0
-	s.Client.Player.Control.Ability1 = state.Input.IsAbilityOneJustPressed()
-	s.Client.Player.Control.Ability2 = state.Input.IsAbilityTwoJustPressed()
+	s.info = s.Client.InterpolatedGameInfo(time.Now())
 
-	// update monsters
-	for i, entity := range s.monsters {
-		if i < len(s.Client.GameInfo.Monsters) {
-			entity.GetCollider().MoveTo(s.Client.GameInfo.Monsters[i].X, s.Client.GameInfo.Monsters[i].Y)
-		}
+	if s.info.GameState == multiplayer.GameStateEnd && state.Input.IsAbilityOneJustPressed() {
+		s.left = true
+		s.Client.Close()
+		state.SceneManager.GoTo(NewMainMenuScene(s.screenWidth, s.screenHeight))
+
+		return nil
 	}
 
-	//update effects - only rebuild if there are actual changes
-	if len(s.Client.GameInfo.StatusEffects) != len(s.statusEffects) {
-		// Clear existing effects from collision space
-		for _, effect := range s.statusEffects {
-			s.collisionSpace.Remove(effect.GetCollider())
-		}
+	s.chat.update(s.info.Chat)
 
-		// Rebuild effects list
-		s.statusEffects = []entities.Effect{}
-		for _, effect := range s.Client.GameInfo.StatusEffects {
-			switch effect.Type {
-			case "SkullDebuff":
-				s.statusEffects = append(s.statusEffects, entities.NewSkullDebuff(s.collisionSpace, effect.X, effect.Y))
-			case "BombCountIncrease":
-				s.statusEffects = append(s.statusEffects, entities.NewBombEffect(s.collisionSpace, effect.X, effect.Y))
-			case "RadiusIncrease":
-				s.statusEffects = append(s.statusEffects, entities.NewRadiusEffect(s.collisionSpace, effect.X, effect.Y))
-			case "RollerIncrease":
-				s.statusEffects = append(s.statusEffects, entities.NewRollerEffect(s.collisionSpace, effect.X, effect.Y))
-			case "ObstacleIncrease":
-				s.statusEffects = append(s.statusEffects, entities.NewObstacleEffect(s.collisionSpace, effect.X, effect.Y))
-			case "DetonatorIncrease":
-				s.statusEffects = append(s.statusEffects, entities.NewDetonatorEffect(s.collisionSpace, effect.X, effect.Y))
-			case "GhostIncrease":
-				s.statusEffects = append(s.statusEffects, entities.NewGhostEffect(s.collisionSpace, effect.X, effect.Y))
-			case "InvincibilityIncrease":
-				s.statusEffects = append(s.statusEffects, entities.NewInvincibilityEffect(s.collisionSpace, effect.X, effect.Y))
-			}
+	if s.camera == nil && s.canSpectate() && !s.chat.open && state.Input.IsAbilityTwoJustPressed() {
+		s.spectate()
+	}
+
+	if s.camera != nil {
+		// spectators have no player to control, the inputs move the camera instead
+		if !s.chat.open {
+			s.camera.update(state.Input, s.info.Players)
 		}
 	} else {
-		// If count is the same, just update positions to keep effects stable
-		for i, effect := range s.statusEffects {
-			if i < len(s.Client.GameInfo.StatusEffects) {
-				serverEffect := s.Client.GameInfo.StatusEffects[i]
-				currentPos := effect.GetCollider().GetPosition()
-				// Only update position if there's a significant change to avoid visual jitter
-				if math.Abs(currentPos.X-serverEffect.X) > 0.5 || math.Abs(currentPos.Y-serverEffect.Y) > 0.5 {
-					effect.GetCollider().MoveTo(serverEffect.X, serverEffect.Y)
-				}
-			}
+		// the player stands still while writing a message
+		controls := entities.PlayerControls{}
+		if !s.chat.open {
+			controls = controlsFromInput(state.Input)
+		}
+		s.pendingInputs = append(s.pendingInputs, pendingInput{seq: s.Client.SendControls(controls), controls: controls})
+		if len(s.pendingInputs) > maxPendingInputs {
+			s.pendingInputs = s.pendingInputs[len(s.pendingInputs)-maxPendingInputs:]
 		}
 	}
 
-	// Update status effects for animation
-	for i := range s.statusEffects {
-		s.statusEffects[i].Update()
+	s.monsters.sync(s.collisionSpace, s.info.Monsters, s.newMonster)
+	s.statusEffects.sync(s.collisionSpace, s.info.StatusEffects, s.newStatusEffect)
+	s.boxes.sync(s.collisionSpace, s.info.Boxes, func(box multiplayer.ProtoEntity) *entities.Box {
+		return entities.NewBox(s.collisionSpace, box.X, box.Y, true)
+	})
+	s.bombs.sync(s.collisionSpace, s.info.Bombs, func(bomb multiplayer.ProtoEntity) *entities.Bomb {
+		return entities.NewBomb(s.collisionSpace, nil, 1, bomb.X, bomb.Y)
+	})
+	s.explosions.sync(s.collisionSpace, s.info.Explosions, func(explosion multiplayer.ProtoEntity) *entities.Explosion {
+		return entities.NewExplosion(s.collisionSpace, explosion.X, explosion.Y)
+	})
+
+	// the server despawns the entities, updating them only animates them
+	for i := range s.statusEffects.entities {
+		s.statusEffects.entities[i].Update()
+	}
+	for _, bomb := range s.bombs.entities {
+		bomb.Update()
+	}
+	for _, explosion := range s.explosions.entities {
+		explosion.Update()
 	}
 
-	for i, v := range s.Client.GameInfo.Players {
+	for i, v := range s.info.Players {
 		if !v.IsDead && i < len(s.players) {
 			s.players[i].ColorOverLay = v.Color
 		}
 	}
 
-	for i := len(s.explosions); i < len(s.Client.GameInfo.Explosions); i++ {
-		s.explosions = append(s.explosions, entities.NewExplosion(s.collisionSpace, s.Client.GameInfo.Explosions[i].X, s.Client.GameInfo.Explosions[i].Y))
+	if len(s.players) != len(s.info.Players) {
+		s.players = make([]entities.Player, len(s.info.Players))
+		for i, v := range s.info.Players {
+			s.players[i] = *entities.NewPlayer(s.collisionSpace, v.X, v.Y, &userinfo.UserInfo{Username: v.Username}, v.Color)
+		}
 	}
 
-	// Iterate backwards to safely remove elements during iteration
-	for i := len(s.explosions) - 1; i >= 0; i-- {
-		if s.explosions[i].Update() {
-			s.collisionSpace.Remove(s.explosions[i].GetCollider())
-			if len(s.explosions) > 1 {
-				s.explosions[i] = s.explosions[len(s.explosions)-1]
-				s.explosions = s.explosions[:len(s.explosions)-1]
-			} else {
-				s.explosions = make([]*entities.Explosion, 0)
-			}
+	if s.camera == nil {
+		s.predictLocalPlayer()
+	}
+
+	for s.terrainChange < len(s.info.TerrainChanges) {
+		terrainChange := s.info.TerrainChanges[s.terrainChange]
+
+		// Check bounds to prevent index out of range
+		if terrainChange.Y >= 0 && terrainChange.Y < len(s.staticEntities) &&
+			terrainChange.X >= 0 && terrainChange.X < len(s.staticEntities[terrainChange.Y]) {
+			s.collisionSpace.Remove(s.staticEntities[terrainChange.Y][terrainChange.X].GetCollider())
+			s.staticEntities[terrainChange.Y][terrainChange.X] = entities.NewGrass(s.collisionSpace, float64(terrainChange.X*sim.TileSize), float64(terrainChange.Y*sim.TileSize), "assets/map/grass_block.png")
 		}
+		s.terrainChange++
 	}
 
-	for i := len(s.bombs); i < len(s.Client.GameInfo.Bombs); i++ {
-		s.bombs = append(s.bombs, entities.NewBomb(s.collisionSpace, nil, 1, s.Client.GameInfo.Bombs[i].X, s.Client.GameInfo.Bombs[i].Y))
+	return nil
+}
+
+// newMonster spawns a mirrored monster of the kind received from the server.
+//
+// Parameters:
+//   - monster: The monster received from the server.
+//
+// Returns:
+//   - entities.Monster: The mirrored monster.
+func (s *MultiPlayerGameSceneJoin) newMonster(monster multiplayer.ProtoEntity) entities.Monster {
+	switch monster.Type {
+	case sim.MonsterGhost:
+		return entities.NewGhost(s.collisionSpace, monster.X, monster.Y, float64(s.screenWidth), float64(s.screenHeight), s.rng, s.clock)
+	case sim.MonsterBalloon:
+		return entities.NewBallon(s.collisionSpace, monster.X, monster.Y, s.rng)
+	case sim.MonsterOnion:
+		return entities.NewOnion(s.collisionSpace, monster.X, monster.Y, s.rng)
+	default:
+		return entities.NewSlime(s.collisionSpace, monster.X, monster.Y, s.rng)
 	}
+}
 
-	// Iterate backwards to safely remove elements during iteration
-	for i := len(s.bombs) - 1; i >= 0; i-- {
-		if s.bombs[i].Update() {
-			s.collisionSpace.Remove(s.bombs[i].GetCollider())
-			if len(s.bombs) > 1 {
-				s.bombs[i] = s.bombs[len(s.bombs)-1]
-				s.bombs = s.bombs[:len(s.bombs)-1]
-			} else {
-				s.bombs = make([]*entities.Bomb, 0)
-			}
-		}
+// newStatusEffect spawns a mirrored status effect of the kind received from the server.
+//
+// Parameters:
+//   - effect: The status effect received from the server.
+//
+// Returns:
+//   - entities.Effect: The mirrored status effect.
+func (s *MultiPlayerGameSceneJoin) newStatusEffect(effect multiplayer.ProtoEntity) entities.Effect {
+	switch effect.Type {
+	case "SkullDebuff":
+		return entities.NewSkullDebuff(s.collisionSpace, effect.X, effect.Y, s.rng)
+	case "RadiusIncrease":
+		return entities.NewRadiusEffect(s.collisionSpace, effect.X, effect.Y)
+	case "RollerIncrease":
+		return entities.NewRollerEffect(s.collisionSpace, effect.X, effect.Y)
+	case "ObstacleIncrease":
+		return entities.NewObstacleEffect(s.collisionSpace, effect.X, effect.Y)
+	case "DetonatorIncrease":
+		return entities.NewDetonatorEffect(s.collisionSpace, effect.X, effect.Y)
+	case "GhostIncrease":
+		return entities.NewGhostEffect(s.collisionSpace, effect.X, effect.Y)
+	case "InvincibilityIncrease":
+		return entities.NewInvincibilityEffect(s.collisionSpace, effect.X, effect.Y)
+	default:
+		return entities.NewBombEffect(s.collisionSpace, effect.X, effect.Y)
 	}
+}
 
-	if len(s.players) != len(s.Client.GameInfo.Players) {
-		s.players = make([]entities.Player, len(s.Client.GameInfo.Players))
-		for i, v := range s.Client.GameInfo.Players {
-			s.players[i] = *entities.NewPlayer(s.collisionSpace, v.X, v.Y, &userinfo.UserInfo{Username: v.Username}, v.Color)
-		}
+// predictLocalPlayer moves the player of the client to its last position received from the server
+// and replays the inputs the server has not simulated yet on top of it,
+// so the player reacts to its inputs without waiting for a round trip to the server.
+func (s *MultiPlayerGameSceneJoin) predictLocalPlayer() {
+	i := s.Client.Player()
+	if i < 0 || i >= len(s.info.Players) || i >= len(s.players) {
+		return
 	}
 
-	for s.terrainChange < len(s.Client.GameInfo.TerrainChanges) {
-		terrainChange := s.Client.GameInfo.TerrainChanges[s.terrainChange]
+	authoritative := s.info.Players[i]
+	if s.info.GameState != multiplayer.GameStateRunning || authoritative.IsDead {
+		s.pendingInputs = s.pendingInputs[:0]
+	}
 
-		// Check bounds to prevent index out of range
-		if terrainChange.X >= 0 && terrainChange.X < len(s.staticEntities) &&
-			terrainChange.Y >= 0 && len(s.staticEntities) > 0 && terrainChange.Y < len(s.staticEntities[0]) {
-			s.staticEntities[terrainChange.X][terrainChange.Y] = entities.NewGrass(s.collisionSpace, float64(terrainChange.X*16), float64(terrainChange.Y*16), "assets/map/grass_block.png")
-		}
-		s.terrainChange++
+	simulated := 0
+	for simulated < len(s.pendingInputs) && s.pendingInputs[simulated].seq <= authoritative.InputSeq {
+		simulated++
 	}
+	s.pendingInputs = s.pendingInputs[simulated:]
 
-	return nil
+	player := &s.players[i]
+	player.GetCollider().MoveTo(authoritative.X, authoritative.Y)
+	for _, input := range s.pendingInputs {
+		sim.PredictMovement(player, input.controls)
+	}
 }
 
-// Draw renders the multiplayer game scene onto the screen.
+// canSpectate returns whether the player of the client died in the running match.
+func (s *MultiPlayerGameSceneJoin) canSpectate() bool {
+	i := s.Client.Player()
+
+	return s.info.GameState == multiplayer.GameStateRunning && i >= 0 && i < len(s.info.Players) && s.info.Players[i].IsDead
+}
+
+// spectate switches the scene to the spectator camera, following the first living player.
+func (s *MultiPlayerGameSceneJoin) spectate() {
+	s.camera = newSpectatorCamera(s.screenWidth, s.screenHeight)
+	s.camera.followNext(s.info.Players)
+	s.pendingInputs = nil
+}
+
+// Draw renders the multiplayer game scene onto the screen, through the spectator camera if the client spectates,
+// with the quality of the connection to the host.
 //
 // Parameters:
 //   - screen: The image to which the scene is drawn.
 func (s *MultiPlayerGameSceneJoin) Draw(screen *ebiten.Image) {
-	s.GameScene.Draw(screen)
-
-	for i, v := range s.Client.GameInfo.Players {
-		if v.IsDead {
-			continue
-		}
+	if s.camera != nil {
+		screen.Fill(color.RGBA{148, 247, 252, 1})
+		s.camera.draw(screen, s.drawMatch, s.info.Players)
+	} else {
+		s.drawMatch(screen)
+	}
 
-		if i < len(s.players) {
-			s.players[i].GetCollider().MoveTo(v.X, v.Y)
-			s.players[i].Draw(screen)
-		}
+	if s.canSpectate() && s.camera == nil {
+		assets.DrawTextWithShadow(screen, "You died  Z: spectate", 10, screen.Bounds().Dy()-18, 1, color.White, text.AlignStart, text.AlignStart)
 	}
 
-	if s.Client.GameInfo.GameState == multiplayer.GameStateEnd {
+	if s.info.GameState == multiplayer.GameStateEnd {
 		drawLogo(screen, 400, 30, "Game Over")
 
 		OrientationY := 50
-		for _, player := range s.Client.GameInfo.Players {
+		for _, player := range s.info.Players {
 			drawLogo(screen, 400, OrientationY, fmt.Sprintln(player.Username, player.Score))
 			OrientationY += 30
 		}
 	}
+
+	drawConnectionQuality(screen, s.Client.ConnectionQuality(), s.info.Players)
+	s.chat.draw(screen)
+}
+
+// drawMatch renders the mirrored entities and players onto the image in the coordinates of the world.
+//
+// Parameters:
+//   - screen: The image to which the match is drawn.
+func (s *MultiPlayerGameSceneJoin) drawMatch(screen *ebiten.Image) {
+	screen.Fill(color.RGBA{148, 247, 252, 1})
+
+	drawEntities(screen, s.staticEntities, s.statusEffects.entities, s.boxes.entities, s.bombs.entities, s.monsters.entities, s.explosions.entities)
+
+	for i, v := range s.info.Players {
+		if v.IsDead {
+			continue
+		}
+
+		if i < len(s.players) {
+			// the local player is already at its predicted position
+			if i != s.Client.Player() {
+				s.players[i].GetCollider().MoveTo(v.X, v.Y)
+			}
+			drawEntity(screen, &s.players[i])
+		}
+	}
 }
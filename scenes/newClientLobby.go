@@ -24,7 +24,6 @@ import (
 func NewClientLobby(ScreenWidth int, ScreenHeight int, client *multiplayer.GameClient, State *GameState) Scene {
 	s := newLobbyScene(800, 600)
 	s.Client = client
-	s.players = &s.Client.GameInfo.Players
 
 	navigationButtons := widget.NewContainer(
 		widget.ContainerOpts.Layout(widget.NewRowLayout(
@@ -5,6 +5,8 @@ package scenes
 import (
 	"image/color"
 	"log"
+	"net"
+	"strconv"
 
 	"github.com/ebitenui/ebitenui/widget"
 	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/assets"
@@ -18,24 +20,125 @@ import (
 //   - ScreenWidth: The width of the game screen.
 //   - ScreenHeight: The height of the game screen.
 //   - mapPath: The path to the map file to be used in the game.
+//   - secure: Whether the server only accepts secure connections.
+//   - State: The current game state.
 //
 // Returns:
-//   - Scene: The initialized host lobby scene.
-func NewHostLobby(ScreenWidth int, ScreenHeight int, mapPath string) Scene {
+//   - Scene: The initialized host lobby scene, or the main menu if the server cannot be started.
+func NewHostLobby(ScreenWidth int, ScreenHeight int, mapPath string, secure bool, State *GameState) Scene {
 	s := newLobbyScene(800, 600)
 	log.Println("Init server")
-	s.Server = multiplayer.NewGameServer()
+	config := multiplayer.DefaultServerConfig(mapPath)
+	config.TLS = secure
+	server, err := multiplayer.NewGameServer(config)
+	if err != nil {
+		log.Println("Failed to create server", err)
+
+		return NewMainMenuScene(ScreenWidth, ScreenHeight)
+	}
+	s.Server = server
 	log.Println("Starting server")
-	s.Server.GameInfo.Level = mapPath
-	closeServer := s.Server.Run()
+	closeServer, err := s.Server.Run()
+	if err != nil {
+		log.Println("Failed to start server", err)
+
+		return NewMainMenuScene(ScreenWidth, ScreenHeight)
+	}
 	log.Println("Server started")
-	s.Server.GameInfo.Players[0] = multiplayer.ProtoPlayer{Username: "Host", X: 1, Y: 1, Color: color.RGBA{R: 0, G: 155, B: 150, A: 255}}
-	s.players = &s.Server.GameInfo.Players
+	s.closeServer = closeServer
+
+	// the host plays through its own server like everyone else
+	address := net.JoinHostPort("localhost", strconv.Itoa(multiplayer.DefaultPort))
+	if secure {
+		address = "wss://" + address
+	}
+	s.Client, err = multiplayer.NewGameClient(address, State.UserInfo, "", func(code int, text string) error {
+		return nil
+	})
+	if err != nil {
+		closeServer()
+
+		return NewMainMenuScene(ScreenWidth, ScreenHeight)
+	}
+
+	// the host manages everyone but itself
+	s.playerActions = func(player int) []*widget.Button {
+		user, ok := s.Server.UserOf(player)
+		if !ok || player == s.Client.Player() {
+			return nil
+		}
+
+		return []*widget.Button{
+			newLobbyButton("Kick", func() {
+				if err := s.Server.Kick(user.UserID); err != nil {
+					log.Println("Failed to kick player", err)
+				}
+			}),
+			newLobbyButton("Ban", func() {
+				if err := s.Server.Ban(user.UserID); err != nil {
+					log.Println("Failed to ban player", err)
+				}
+			}),
+		}
+	}
+
+	maxPlayersButtons := widget.NewContainer(
+		widget.ContainerOpts.Layout(widget.NewRowLayout(
+			widget.RowLayoutOpts.Direction(widget.DirectionHorizontal),
+			widget.RowLayoutOpts.Spacing(5),
+		)),
+		widget.ContainerOpts.WidgetOpts(
+			widget.WidgetOpts.LayoutData(widget.RowLayoutData{
+				Position: widget.RowLayoutPositionCenter,
+			}),
+		),
+	)
+	for _, change := range []int{-1, 1} {
+		label := "Max -"
+		if change > 0 {
+			label = "Max +"
+		}
+		maxPlayersButtons.AddChild(newLobbyButton(label, func() {
+			if err := s.Server.SetMaxPlayers(s.Client.GameInfo().MaxPlayers + change); err != nil {
+				log.Println("Failed to change max players", err)
+			}
+		}))
+	}
+
+	// the password only applies to the players joining after it is set, the host is already in
+	passwordInput := newLobbyTextInput("No password", func(text string) {
+		s.Server.SetPassword(text)
+	})
+	privacyControls := widget.NewContainer(
+		widget.ContainerOpts.Layout(widget.NewRowLayout(
+			widget.RowLayoutOpts.Direction(widget.DirectionHorizontal),
+			widget.RowLayoutOpts.Padding(widget.NewInsetsSimple(5)),
+			widget.RowLayoutOpts.Spacing(5),
+		)),
+	)
+	privacyControls.AddChild(widget.NewText(
+		widget.TextOpts.Text("Password:", assets.EbitenUIFont(10), color.NRGBA{254, 255, 255, 255}),
+		widget.TextOpts.WidgetOpts(widget.WidgetOpts.LayoutData(widget.RowLayoutData{
+			Position: widget.RowLayoutPositionCenter,
+		})),
+	))
+	privacyControls.AddChild(passwordInput)
+	privacyControls.AddChild(newLobbyButton("Invite code", func() {
+		passwordInput.SetText(multiplayer.NewInviteCode())
+		s.Server.SetPassword(passwordInput.GetText())
+	}))
+	privacyControls.AddChild(newLobbyButton("Open", func() {
+		passwordInput.SetText("")
+		s.Server.SetPassword("")
+	}))
+	s.ui.Container.AddChild(privacyControls)
+	// keeps the navigation below the password
+	s.ui.Container.AddChild(widget.NewContainer())
 
 	navigationButtons := widget.NewContainer(
 		widget.ContainerOpts.Layout(widget.NewRowLayout(
 			widget.RowLayoutOpts.Direction(widget.DirectionHorizontal),
-			widget.RowLayoutOpts.Spacing(195),
+			widget.RowLayoutOpts.Spacing(100),
 			widget.RowLayoutOpts.Padding(widget.NewInsetsSimple(5)),
 		)),
 	)
@@ -64,10 +167,13 @@ func NewHostLobby(ScreenWidth int, ScreenHeight int, mapPath string) Scene {
 
 		widget.ButtonOpts.ClickedHandler(func(args *widget.ButtonClickedEventArgs) {
 			s.backButtonPressed = true
+			s.Client.Close()
 			closeServer()
 		}),
 	))
 
+	navigationButtons.AddChild(maxPlayersButtons)
+
 	navigationButtons.AddChild(widget.NewButton(
 		widget.ButtonOpts.WidgetOpts(
 			widget.WidgetOpts.LayoutData(widget.RowLayoutData{
@@ -0,0 +1,246 @@
+// Package scenes provides the implementation of various game scenes,
+// including the replay scene for watching recorded matches.
+package scenes
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	uiimage "github.com/ebitenui/ebitenui/image"
+	"github.com/ebitenui/ebitenui/widget"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	text "github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/assets"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/entities"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/replay"
+)
+
+// replaySpeeds are the playback speeds the replay scene can switch between, in ticks per frame.
+var replaySpeeds = []float64{0.25, 0.5, 1, 2, 4, 8}
+
+// maxListedReplays is the number of most recent replays offered in the replay window.
+const maxListedReplays = 5
+
+// replaySeekStep is the number of ticks skipped by a single seek.
+const replaySeekStep = 5 * entities.TicksPerSecond
+
+// ReplayScene represents a scene playing back a recorded match.
+// Space pauses, the left and right arrows seek, the up and down arrows change the speed
+// and escape returns to the main menu.
+type ReplayScene struct {
+	GameScene                   // The game scene rendering the replayed match.
+	playback   *replay.Playback // The playback of the recorded match.
+	paused     bool             // Whether the playback is paused.
+	speedIndex int              // The index of the current speed in replaySpeeds.
+	pending    float64          // The fraction of a tick carried over to the next frame.
+}
+
+// NewReplayScene creates a scene playing back the given replay.
+//
+// Parameters:
+//   - r: The replay to play back.
+//
+// Returns:
+//   - Scene: The initialized replay scene.
+//   - error: An error if the level stored in the replay cannot be loaded.
+func NewReplayScene(r *replay.Replay) (Scene, error) {
+	playback, err := replay.NewPlayback(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReplayScene{
+		GameScene:  *newGameScene(playback.World()),
+		playback:   playback,
+		speedIndex: 2,
+	}, nil
+}
+
+// Update handles the playback controls and advances the replay according to its speed.
+//
+// Parameters:
+//   - state: The current game state.
+//
+// Returns:
+//   - error: An error if the update fails.
+func (s *ReplayScene) Update(state *GameState) error {
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		state.SceneManager.GoTo(NewMainMenuScene(400, 300))
+
+		return nil
+	case inpututil.IsKeyJustPressed(ebiten.KeySpace):
+		s.paused = !s.paused
+	case inpututil.IsKeyJustPressed(ebiten.KeyUp):
+		s.speedIndex = min(s.speedIndex+1, len(replaySpeeds)-1)
+	case inpututil.IsKeyJustPressed(ebiten.KeyDown):
+		s.speedIndex = max(s.speedIndex-1, 0)
+	case inpututil.IsKeyJustPressed(ebiten.KeyRight):
+		s.seek(s.playback.Tick() + replaySeekStep)
+	case inpututil.IsKeyJustPressed(ebiten.KeyLeft):
+		s.seek(s.playback.Tick() - min(s.playback.Tick(), replaySeekStep))
+	}
+
+	if s.paused || s.playback.Done() {
+		return nil
+	}
+
+	s.pending += replaySpeeds[s.speedIndex]
+	for ; s.pending >= 1; s.pending-- {
+		s.playback.Step()
+	}
+
+	return nil
+}
+
+// seek moves the playback to the given tick.
+// Seeking backwards replaces the world of the playback, so the scene follows it.
+func (s *ReplayScene) seek(tick uint64) {
+	if err := s.playback.Seek(tick); err != nil {
+		log.Println("Failed to seek replay", err)
+	}
+	s.world = s.playback.World()
+	s.pending = 0
+}
+
+// Draw renders the replayed match with the playback state and a progress bar below it.
+//
+// Parameters:
+//   - screen: The image to which the scene is drawn.
+func (s *ReplayScene) Draw(screen *ebiten.Image) {
+	s.GameScene.Draw(screen)
+
+	length := s.playback.Replay().Length()
+	status := fmt.Sprintf("%.1fs / %.1fs  x%g",
+		float64(s.playback.Tick())/entities.TicksPerSecond, float64(length)/entities.TicksPerSecond, replaySpeeds[s.speedIndex])
+	if s.paused {
+		status += "  PAUSED"
+	}
+
+	width := float32(screen.Bounds().Dx() - 20)
+	y := float32(screen.Bounds().Dy() - 14)
+	progress := float32(1)
+	if length > 0 {
+		progress = float32(s.playback.Tick()) / float32(length)
+	}
+	vector.DrawFilledRect(screen, 10, y, width, 4, color.RGBA{40, 40, 40, 255}, false)
+	vector.DrawFilledRect(screen, 10, y, width*progress, 4, color.RGBA{0x00, 0x00, 0x80, 0xff}, false)
+
+	assets.DrawTextWithShadow(screen, status, 10, int(y)-14, 1, color.White, text.AlignStart, text.AlignStart)
+}
+
+// loadReplayScene loads the replay file at the given path and creates a scene playing it back.
+//
+// Parameters:
+//   - path: The path of the replay file.
+//
+// Returns:
+//   - Scene: The initialized replay scene.
+//   - error: An error if the replay cannot be read.
+func loadReplayScene(path string) (Scene, error) {
+	r, err := replay.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewReplayScene(r)
+}
+
+// listReplays returns the paths of the most recent replays in the replay directory, newest first.
+func listReplays() []string {
+	files, err := os.ReadDir(replayDir)
+	if err != nil {
+		return nil
+	}
+
+	var paths []string
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), replay.FileExtension) {
+			paths = append(paths, filepath.Join(replayDir, file.Name()))
+		}
+	}
+
+	// replays are named after the time they were saved, so the names sort chronologically
+	slices.Sort(paths)
+	slices.Reverse(paths)
+
+	return paths[:min(len(paths), maxListedReplays)]
+}
+
+// newReplayWindow creates a window listing the most recent replays.
+//
+// Parameters:
+//   - onSelect: The function called with the path of the replay the player selected.
+//
+// Returns:
+//   - *widget.Window: The replay window.
+func newReplayWindow(onSelect func(path string)) *widget.Window {
+	replayContainer := widget.NewContainer(
+		widget.ContainerOpts.BackgroundImage(uiimage.NewNineSliceColor(color.NRGBA{19, 128, 30, 255})),
+		widget.ContainerOpts.Layout(widget.NewRowLayout(
+			widget.RowLayoutOpts.Direction(widget.DirectionVertical),
+			widget.RowLayoutOpts.Padding(widget.NewInsetsSimple(5)),
+			widget.RowLayoutOpts.Spacing(5),
+		)),
+	)
+
+	paths := listReplays()
+	if len(paths) == 0 {
+		replayContainer.AddChild(widget.NewText(
+			widget.TextOpts.Text("No replays yet", assets.EbitenUIFont(10), color.NRGBA{254, 255, 255, 255}),
+		))
+	}
+
+	for _, path := range paths {
+		replayContainer.AddChild(widget.NewButton(
+			widget.ButtonOpts.WidgetOpts(
+				widget.WidgetOpts.LayoutData(widget.RowLayoutData{
+					Position: widget.RowLayoutPositionCenter,
+					Stretch:  true,
+				}),
+			),
+			widget.ButtonOpts.Image(assets.LoadButtonImage()),
+			widget.ButtonOpts.Text(strings.TrimSuffix(filepath.Base(path), replay.FileExtension), assets.EbitenUIFont(10), &widget.ButtonTextColor{
+				Idle: color.NRGBA{0xdf, 0xf4, 0xff, 0xff},
+			}),
+			widget.ButtonOpts.TextPadding(widget.Insets{
+				Left:   30,
+				Right:  30,
+				Top:    5,
+				Bottom: 5,
+			}),
+			widget.ButtonOpts.ClickedHandler(func(args *widget.ButtonClickedEventArgs) {
+				onSelect(path)
+			}),
+		))
+	}
+
+	replayWindowContent := widget.NewContainer(
+		widget.ContainerOpts.BackgroundImage(uiimage.NewNineSliceColor(color.NRGBA{75, 105, 47, 255})),
+		widget.ContainerOpts.Layout(widget.NewAnchorLayout()),
+	)
+	replayWindowContent.AddChild(widget.NewText(
+		widget.TextOpts.Text("Select a replay to watch", assets.EbitenUIFont(12), color.NRGBA{254, 255, 255, 255}),
+		widget.TextOpts.WidgetOpts(widget.WidgetOpts.LayoutData(widget.AnchorLayoutData{
+			HorizontalPosition: widget.AnchorLayoutPositionCenter,
+			VerticalPosition:   widget.AnchorLayoutPositionCenter,
+		})),
+	))
+
+	return widget.NewWindow(
+		widget.WindowOpts.Contents(replayContainer),
+		widget.WindowOpts.TitleBar(replayWindowContent, 25),
+		widget.WindowOpts.Modal(),
+		widget.WindowOpts.CloseMode(widget.CLICK_OUT),
+		widget.WindowOpts.Draggable(),
+		widget.WindowOpts.MinSize(200, 100),
+		widget.WindowOpts.MaxSize(300, 300),
+	)
+}
@@ -0,0 +1,166 @@
+// Package scenes provides the implementation of various game scenes,
+// including the spectator scene for watching multiplayer matches.
+package scenes
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	text "github.com/hajimehoshi/ebiten/v2/text/v2"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/assets"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/multiplayer"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/inputs"
+)
+
+// spectatorZoom is how much the spectator camera magnifies the match.
+const spectatorZoom = 2
+
+// spectatorCameraSpeed is the number of pixels of the world the free camera moves per frame.
+const spectatorCameraSpeed = 3
+
+// spectatorCamera represents the view of a spectator, which either follows a player or is moved freely.
+// The first ability follows the next living player and the second one frees the camera,
+// which is then moved with the directions.
+type spectatorCamera struct {
+	x, y      float64       // The center of the view in the coordinates of the world.
+	following int           // The index of the followed player, or -1 for the free camera.
+	canvas    *ebiten.Image // The image the match is drawn onto before it is scaled onto the screen.
+}
+
+// NewSpectatorScene creates a new scene for watching a multiplayer match without a player.
+//
+// Parameters:
+//   - client: The game client connected to the match, usually as a spectator.
+//
+// Returns:
+//   - Scene: The initialized spectator scene.
+//   - error: An error if the level of the server cannot be fetched or loaded.
+func NewSpectatorScene(client *multiplayer.GameClient) (Scene, error) {
+	s, err := newMultiPlayerGameSceneJoin(client)
+	if err != nil {
+		return nil, err
+	}
+	s.spectate()
+
+	return s, nil
+}
+
+// newSpectatorCamera creates a free camera looking at the center of a world of the given size.
+//
+// Parameters:
+//   - width: The width of the world.
+//   - height: The height of the world.
+//
+// Returns:
+//   - *spectatorCamera: The initialized camera.
+func newSpectatorCamera(width int, height int) *spectatorCamera {
+	return &spectatorCamera{
+		x:         float64(width) / 2,
+		y:         float64(height) / 2,
+		following: -1,
+		canvas:    ebiten.NewImage(width, height),
+	}
+}
+
+// update switches between following a player and the free camera, and moves the camera.
+// The camera stops following a player once it dies.
+//
+// Parameters:
+//   - input: The input of the spectator.
+//   - players: The players of the match.
+func (c *spectatorCamera) update(input *inputs.Input, players []multiplayer.ProtoPlayer) {
+	switch {
+	case input.IsAbilityOneJustPressed():
+		c.followNext(players)
+	case input.IsAbilityTwoJustPressed():
+		c.following = -1
+	}
+
+	if c.following >= len(players) || (c.following >= 0 && players[c.following].IsDead) {
+		c.followNext(players)
+	}
+
+	if c.following >= 0 {
+		c.x, c.y = players[c.following].X, players[c.following].Y
+
+		return
+	}
+
+	if input.StateForLeft() > 0 {
+		c.x -= spectatorCameraSpeed
+	}
+	if input.StateForRight() > 0 {
+		c.x += spectatorCameraSpeed
+	}
+	if input.StateForUp() > 0 {
+		c.y -= spectatorCameraSpeed
+	}
+	if input.StateForDown() > 0 {
+		c.y += spectatorCameraSpeed
+	}
+}
+
+// followNext follows the next living player after the one currently followed,
+// or frees the camera if every player is dead.
+func (c *spectatorCamera) followNext(players []multiplayer.ProtoPlayer) {
+	for i := 1; i <= len(players); i++ {
+		next := (c.following + i) % len(players)
+		if next < 0 {
+			next += len(players)
+		}
+		if !players[next].IsDead {
+			c.following = next
+
+			return
+		}
+	}
+
+	c.following = -1
+}
+
+// draw renders the match through the camera onto the screen, with the mode of the camera below it.
+// The view is kept inside the world, unless the world is smaller than the view.
+//
+// Parameters:
+//   - screen: The image to which the match is drawn.
+//   - drawMatch: Draws the match onto the image it is given, in the coordinates of the world.
+//   - players: The players of the match.
+func (c *spectatorCamera) draw(screen *ebiten.Image, drawMatch func(*ebiten.Image), players []multiplayer.ProtoPlayer) {
+	c.canvas.Clear()
+	drawMatch(c.canvas)
+
+	width, height := float64(c.canvas.Bounds().Dx()), float64(c.canvas.Bounds().Dy())
+	halfWidth := float64(screen.Bounds().Dx()) / 2 / spectatorZoom
+	halfHeight := float64(screen.Bounds().Dy()) / 2 / spectatorZoom
+	c.x = clampView(c.x, halfWidth, width)
+	c.y = clampView(c.y, halfHeight, height)
+
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(-c.x, -c.y)
+	op.GeoM.Scale(spectatorZoom, spectatorZoom)
+	op.GeoM.Translate(float64(screen.Bounds().Dx())/2, float64(screen.Bounds().Dy())/2)
+	screen.DrawImage(c.canvas, op)
+
+	mode := "Free camera"
+	if c.following >= 0 && c.following < len(players) {
+		mode = "Following " + players[c.following].Username
+	}
+	assets.DrawTextWithShadow(screen, mode+"  X: next player  Z: free camera", 10, screen.Bounds().Dy()-18, 1, color.White, text.AlignStart, text.AlignStart)
+}
+
+// clampView returns the center of a view along one axis, moved so the view stays inside the world.
+//
+// Parameters:
+//   - center: The requested center of the view.
+//   - half: Half the size of the view.
+//   - size: The size of the world.
+//
+// Returns:
+//   - float64: The center of the view.
+func clampView(center float64, half float64, size float64) float64 {
+	if size <= 2*half {
+		return size / 2
+	}
+
+	return min(max(center, half), size-half)
+}
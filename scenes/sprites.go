@@ -0,0 +1,78 @@
+// Package scenes provides the implementation of various game scenes,
+// including the drawing of the entities of a match with their sprites.
+package scenes
+
+import (
+	"image"
+	_ "image/png"
+	"log"
+	"sync"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/assets"
+	"szofttech.inf.elte.hu/szofttech-c-2024/group-08/ninjago/game/entities"
+)
+
+// spriteCache holds the sprites read from the embedded assets, by asset path.
+// Sprites are only turned into ebiten images when an entity is first drawn.
+var spriteCache = struct {
+	sync.Mutex
+	images map[string]*ebiten.Image
+}{
+	images: make(map[string]*ebiten.Image),
+}
+
+// loadSprite returns the sprite stored at the given asset path, decoding it on first use.
+func loadSprite(path string) *ebiten.Image {
+	spriteCache.Lock()
+	defer spriteCache.Unlock()
+
+	if sprite, ok := spriteCache.images[path]; ok {
+		return sprite
+	}
+
+	sprite, _, err := ebitenutil.NewImageFromFileSystem(assets.EmbeddedAssets, path)
+	if err != nil {
+		log.Fatalf("Failed to load sprite: %v", err)
+	}
+	spriteCache.images[path] = sprite
+
+	return sprite
+}
+
+// drawable is an entity drawn with a sprite.
+type drawable interface {
+	Sprite() (string, int)           // Sprite returns the asset path of the sprite of the entity and the index of its current frame.
+	GetPosition() (float64, float64) // GetPosition returns the position of the entity in the game world.
+}
+
+// drawEntity draws the current frame of the sprite of an entity at its position.
+// The frames of a sprite are squares as high as the sprite, laid out side by side,
+// and players are tinted with their color.
+//
+// Parameters:
+//   - screen: The image to which the entity is drawn.
+//   - entity: The entity to draw.
+func drawEntity(screen *ebiten.Image, entity drawable) {
+	path, frame := entity.Sprite()
+	if path == "" {
+		return
+	}
+
+	sprite := loadSprite(path)
+	size := sprite.Bounds().Dy()
+	x, y := entity.GetPosition()
+
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(x, y)
+	if player, ok := entity.(*entities.Player); ok {
+		r, g, b, a := player.ColorOverLay.RGBA()
+		op.ColorScale.SetR(float32(r))
+		op.ColorScale.SetG(float32(g))
+		op.ColorScale.SetB(float32(b))
+		op.ColorScale.SetA(float32(a))
+	}
+
+	screen.DrawImage(sprite.SubImage(image.Rect(frame*size, 0, (frame+1)*size, size)).(*ebiten.Image), op)
+}